@@ -0,0 +1,330 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/butlermatt/glox/dap"
+	"github.com/butlermatt/glox/debugger"
+	"github.com/butlermatt/glox/doc"
+	"github.com/butlermatt/glox/format"
+	"github.com/butlermatt/glox/interpreter"
+	"github.com/butlermatt/glox/lexer"
+	"github.com/butlermatt/glox/lint"
+	"github.com/butlermatt/glox/lsp"
+	"github.com/butlermatt/glox/parser"
+)
+
+// commands are the subcommands run as "glox <command> args...", in place of a script.
+var commands = map[string]func(args []string){
+	"ast":   cmdAst,
+	"dap":   cmdDap,
+	"debug": cmdDebug,
+	"doc":   cmdDoc,
+	"fmt":   cmdFmt,
+	"lint":  cmdLint,
+	"lsp":   cmdLsp,
+	"parse": cmdParse,
+}
+
+// parseFile reads and parses the script at path, exiting if it cannot be read or has syntax errors.
+func parseFile(path string) []parser.Stmt {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error reading file: %+v\n", err)
+		os.Exit(1)
+	}
+
+	stmts, err := parse(string(data))
+	if err != nil {
+		os.Exit(65)
+	}
+	return stmts
+}
+
+// cmdAst prints the syntax tree of a script as S-expressions.
+func cmdAst(args []string) {
+	if len(args) != 1 {
+		fmt.Fprintf(os.Stderr, "Usage: %s ast file.lox\n", os.Args[0])
+		os.Exit(64)
+	}
+
+	ap := &parser.AstPrinter{}
+	fmt.Print(ap.PrintStmts(parseFile(args[0])))
+}
+
+// cmdParse checks a script for syntax errors, printing its syntax tree as JSON with -json.
+func cmdParse(args []string) {
+	fs := flag.NewFlagSet("parse", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "print the syntax tree as JSON")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fmt.Fprintf(os.Stderr, "Usage: %s parse [-json] file.lox\n", os.Args[0])
+		os.Exit(64)
+	}
+
+	stmts := parseFile(fs.Arg(0))
+	if !*asJSON {
+		return
+	}
+
+	data, err := parser.EncodeJSON(stmts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(70)
+	}
+	var out bytes.Buffer
+	json.Indent(&out, data, "", "  ")
+	out.WriteByte('\n')
+	out.WriteTo(os.Stdout)
+}
+
+// cmdFmt formats scripts, printing the result, or with -w rewriting files which are not
+// formatted, or with -d printing their differences and failing if there are any.
+func cmdFmt(args []string) {
+	fs := flag.NewFlagSet("fmt", flag.ExitOnError)
+	write := fs.Bool("w", false, "write the result to the source file instead of stdout")
+	diff := fs.Bool("d", false, "print diffs instead of the formatted source, and fail if any file needs formatting")
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fmt.Fprintf(os.Stderr, "Usage: %s fmt [-w | -d] file.lox...\n", os.Args[0])
+		os.Exit(64)
+	}
+
+	status := 0
+	for _, path := range fs.Args() {
+		src, err := ioutil.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error reading file: %+v\n", err)
+			status = 1
+			continue
+		}
+		out, err := format.Source(src)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s:\n%v\n", path, err)
+			status = 1
+			continue
+		}
+
+		switch {
+		case *diff:
+			if d := unifiedDiff(path, src, out); d != "" {
+				fmt.Print(d)
+				status = 1
+			}
+		case *write:
+			if !bytes.Equal(src, out) {
+				if err := ioutil.WriteFile(path, out, 0644); err != nil {
+					fmt.Fprintf(os.Stderr, "error writing file: %+v\n", err)
+					status = 1
+				}
+			}
+		default:
+			os.Stdout.Write(out)
+		}
+	}
+	os.Exit(status)
+}
+
+// cmdDoc writes Markdown and HTML documentation for the scripts in a directory.
+func cmdDoc(args []string) {
+	fs := flag.NewFlagSet("doc", flag.ExitOnError)
+	out := fs.String("o", "doc", "directory to write the pages to")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fmt.Fprintf(os.Stderr, "Usage: %s doc [-o dir] path\n", os.Args[0])
+		os.Exit(64)
+	}
+
+	pkg, err := doc.Load(fs.Arg(0))
+	if err == nil {
+		err = pkg.Write(*out)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+}
+
+// cmdLint checks scripts for suspicious code, failing if any finding is a warning or an error.
+func cmdLint(args []string) {
+	fs := flag.NewFlagSet("lint", flag.ExitOnError)
+	disable := fs.String("disable", "", "comma separated rules to skip, by ID or name")
+	severity := fs.String("severity", "", "comma separated rule=severity overrides, such as L002=warning")
+	list := fs.Bool("rules", false, "list the rules and exit")
+	fs.Parse(args)
+
+	if *list {
+		for _, r := range lint.Rules {
+			fmt.Printf("%s %-24s %-8s %s\n", r.ID, r.Name, r.Severity, r.Doc)
+		}
+		return
+	}
+	if fs.NArg() == 0 {
+		fmt.Fprintf(os.Stderr, "Usage: %s lint [-disable rules] [-severity rule=level,...] file.lox...\n", os.Args[0])
+		os.Exit(64)
+	}
+
+	cfg, err := lintConfig(*disable, *severity)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(64)
+	}
+
+	status := 0
+	for _, path := range fs.Args() {
+		src, err := ioutil.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error reading file: %+v\n", err)
+			status = 1
+			continue
+		}
+		diags, err := lint.Check(string(src), cfg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s:\n%v\n", path, err)
+			status = 1
+			continue
+		}
+		for _, d := range diags {
+			fmt.Printf("%s:%s\n", path, d)
+			if d.Severity >= lint.Warning {
+				status = 1
+			}
+		}
+	}
+	os.Exit(status)
+}
+
+// lintConfig builds the linter configuration from the -disable and -severity flags.
+func lintConfig(disable, severity string) (*lint.Config, error) {
+	cfg := &lint.Config{Disabled: make(map[*lint.Rule]bool), Severity: make(map[*lint.Rule]lint.Severity)}
+	for _, name := range strings.FieldsFunc(disable, isComma) {
+		r := lint.Lookup(name)
+		if r == nil {
+			return nil, fmt.Errorf("unknown lint rule %q", name)
+		}
+		cfg.Disabled[r] = true
+	}
+	for _, setting := range strings.FieldsFunc(severity, isComma) {
+		parts := strings.SplitN(setting, "=", 2)
+		r := lint.Lookup(parts[0])
+		if r == nil || len(parts) != 2 {
+			return nil, fmt.Errorf("invalid severity setting %q", setting)
+		}
+		s, err := lint.ParseSeverity(parts[1])
+		if err != nil {
+			return nil, err
+		}
+		cfg.Severity[r] = s
+	}
+	return cfg, nil
+}
+
+func isComma(r rune) bool { return r == ',' }
+
+// cmdLsp runs a language server for editors, speaking the protocol over stdin and stdout.
+func cmdLsp(args []string) {
+	if len(args) != 0 {
+		fmt.Fprintf(os.Stderr, "Usage: %s lsp\n", os.Args[0])
+		os.Exit(64)
+	}
+	if err := lsp.NewServer(os.Stdin, os.Stdout).Run(); err != nil {
+		fmt.Fprintf(os.Stderr, "lsp: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// cmdDebug runs a script under the terminal debugger, pausing before its first statement.
+func cmdDebug(args []string) {
+	if len(args) < 1 {
+		fmt.Fprintf(os.Stderr, "Usage: %s debug file.lox [args...]\n", os.Args[0])
+		os.Exit(64)
+	}
+	data, err := ioutil.ReadFile(args[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error reading file: %+v\n", err)
+		os.Exit(1)
+	}
+
+	// The debugger needs the parser, which knows where each statement begins.
+	p := parser.New(lexer.New(string(data)))
+	stmts := p.Parse()
+	if errs := p.Errors(); len(errs) > 0 {
+		for _, e := range errs {
+			fmt.Printf("[Syntax Error line %d] Error %s: %s\n", e.Line, e.Where, e.Msg)
+		}
+		os.Exit(65)
+	}
+	prog, err := interpreter.Compile(stmts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(65)
+	}
+
+	console := debugger.NewConsole(stdin, os.Stdout, string(data))
+	d := debugger.New(p, stmts, console)
+	d.StopOnEntry = true
+	console.SetDebugger(d)
+
+	interp := prog.NewInterpreter(capabilities...)
+	interp.SetArgs(args[1:])
+	interp.SetInput(stdin)
+	interp.SetHook(d)
+	err = interp.Interpret(context.Background())
+	if e, ok := err.(*interpreter.ExitError); ok {
+		os.Exit(e.Code)
+	} else if err == debugger.ErrQuit {
+		return
+	} else if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(70)
+	}
+}
+
+// cmdDap serves the Debug Adapter Protocol on stdin and stdout, or to one client connecting to
+// the local address given with -listen.
+func cmdDap(args []string) {
+	fs := flag.NewFlagSet("dap", flag.ExitOnError)
+	listen := fs.String("listen", "", "accept one client on this address, such as 127.0.0.1:4711, instead of using stdio")
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		fmt.Fprintf(os.Stderr, "Usage: %s dap [-listen addr]\n", os.Args[0])
+		os.Exit(64)
+	}
+
+	var server *dap.Server
+	if *listen == "" {
+		server = dap.NewServer(os.Stdin, os.Stdout, capabilities...)
+	} else {
+		host, _, err := net.SplitHostPort(*listen)
+		if ip := net.ParseIP(host); err != nil || host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+			fmt.Fprintf(os.Stderr, "dap: -listen must be a local address, such as 127.0.0.1:4711\n")
+			os.Exit(64)
+		}
+		l, err := net.Listen("tcp", *listen)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "dap: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Fprintf(os.Stderr, "Listening on %s\n", l.Addr())
+		conn, err := l.Accept()
+		l.Close()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "dap: %v\n", err)
+			os.Exit(1)
+		}
+		defer conn.Close()
+		server = dap.NewServer(conn, conn, capabilities...)
+	}
+	if err := server.Run(); err != nil {
+		fmt.Fprintf(os.Stderr, "dap: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,176 @@
+// Package cst builds a lossless concrete syntax tree over the nodes of package parser. Every
+// token of the source, with its whitespace and comments, belongs to exactly one node, so the
+// tree reproduces the original text byte for byte.
+package cst
+
+import (
+	"bytes"
+
+	"github.com/butlermatt/glox/lexer"
+	"github.com/butlermatt/glox/parser"
+)
+
+// Node is either a token leaf, or a syntax node whose children, tokens and nested syntax nodes,
+// cover its source text in order. The root node holds the top level statements and the final
+// EOF token, which carries any trivia at the end of the file.
+type Node struct {
+	Syntax   interface{}  // The parser.Expr or parser.Stmt, or nil for a token or the root
+	Token    *lexer.Token // The token, for a leaf
+	Children []*Node
+}
+
+// Parse parses src, returning the root of its syntax tree and the parsed statements. If there are
+// syntax errors the tree still reproduces the source, but may hold fewer syntax nodes.
+func Parse(src string) (*Node, []parser.Stmt, []parser.ParseError) {
+	l := lexer.NewWithTrivia(src)
+	p := parser.New(l)
+	stmts := p.Parse()
+
+	b := &builder{p: p, toks: l.Tokens(), index: make(map[*lexer.Token]int), spans: make(map[interface{}]span)}
+	for i, tok := range b.toks {
+		b.index[tok] = i
+	}
+
+	var top []interface{}
+	for _, s := range stmts {
+		if s != nil {
+			top = append(top, s)
+		}
+	}
+	root := b.build(nil, top, span{0, len(b.toks) - 1})
+	return root, stmts, p.Errors()
+}
+
+// Text returns the source text of n, including the trivia of its tokens.
+func (n *Node) Text() string {
+	var buf bytes.Buffer
+	n.write(&buf)
+	return buf.String()
+}
+
+func (n *Node) write(buf *bytes.Buffer) {
+	if n.Token != nil {
+		buf.WriteString(n.Token.Leading)
+		buf.WriteString(n.Token.Lexeme)
+		buf.WriteString(n.Token.Trailing)
+		return
+	}
+	for _, c := range n.Children {
+		c.write(buf)
+	}
+}
+
+// Tokens returns the tokens beneath n in source order.
+func (n *Node) Tokens() []*lexer.Token {
+	if n.Token != nil {
+		return []*lexer.Token{n.Token}
+	}
+	var toks []*lexer.Token
+	for _, c := range n.Children {
+		toks = append(toks, c.Tokens()...)
+	}
+	return toks
+}
+
+// Find returns the node for syntax, the parser.Expr or parser.Stmt, if it is in the tree.
+func (n *Node) Find(syntax interface{}) *Node {
+	if n.Syntax == syntax {
+		return n
+	}
+	for _, c := range n.Children {
+		if f := c.Find(syntax); f != nil {
+			return f
+		}
+	}
+	return nil
+}
+
+// span is a range of token indexes, inclusive of both ends.
+type span struct {
+	first, last int
+}
+
+type builder struct {
+	p     *parser.Parser
+	toks  []*lexer.Token
+	index map[*lexer.Token]int
+	spans map[interface{}]span
+}
+
+// spanOf returns the token range of a syntax node: the span recorded by the parser, or else the
+// range covering its own tokens and those of its children.
+func (b *builder) spanOf(node interface{}) (span, bool) {
+	if s, ok := b.spans[node]; ok {
+		return s, s.first >= 0
+	}
+
+	s := span{-1, -1}
+	include := func(first, last int) {
+		if s.first < 0 || first < s.first {
+			s.first = first
+		}
+		if last > s.last {
+			s.last = last
+		}
+	}
+
+	if ps, ok := b.p.Span(node); ok {
+		first, okFirst := b.index[ps.First]
+		last, okLast := b.index[ps.Last]
+		if okFirst && okLast && first <= last {
+			include(first, last)
+		}
+	}
+	for _, tok := range parser.Tokens(node) {
+		if i, ok := b.index[tok]; ok {
+			include(i, i)
+		}
+	}
+	for _, c := range parser.Children(node) {
+		if cs, ok := b.spanOf(c); ok {
+			include(cs.first, cs.last)
+		}
+	}
+
+	b.spans[node] = s
+	return s, s.first >= 0
+}
+
+// build returns the node for syntax covering the tokens of s, nesting the children which fall
+// within it and leaving the remaining tokens as leaves.
+func (b *builder) build(syntax interface{}, children []interface{}, s span) *Node {
+	n := &Node{Syntax: syntax}
+
+	type child struct {
+		node interface{}
+		span span
+	}
+	var nested []child
+	for _, c := range children {
+		if cs, ok := b.spanOf(c); ok && cs.first >= s.first && cs.last <= s.last {
+			nested = append(nested, child{c, cs})
+		}
+	}
+	// Children are usually in source order already, but keep the tree sound if not.
+	for i := 1; i < len(nested); i++ {
+		for j := i; j > 0 && nested[j].span.first < nested[j-1].span.first; j-- {
+			nested[j], nested[j-1] = nested[j-1], nested[j]
+		}
+	}
+
+	i := s.first
+	for _, c := range nested {
+		if c.span.first < i {
+			continue // Overlaps a previous child
+		}
+		for ; i < c.span.first; i++ {
+			n.Children = append(n.Children, &Node{Token: b.toks[i]})
+		}
+		n.Children = append(n.Children, b.build(c.node, parser.Children(c.node), c.span))
+		i = c.span.last + 1
+	}
+	for ; i <= s.last; i++ {
+		n.Children = append(n.Children, &Node{Token: b.toks[i]})
+	}
+	return n
+}
@@ -0,0 +1,67 @@
+package cst
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/butlermatt/glox/parser"
+)
+
+func TestParse_RoundTrip(t *testing.T) {
+	printer, err := ioutil.ReadFile("../parser/testdata/printer.lox")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []string{
+		string(printer),
+		"",
+		"  \n// only a comment\n",
+		"var   a=1 ;  // trailing\r\n\r\n  print a[ 0 ]  ;\n\n",
+		"fun f(a, b = 2) {\n  // body\n  return (a + b) * -a;\n}\n// end",
+		"var s = `raw\nstring`; print s",
+		"/* a\n /* b */ */ var a; /* c\n */ /// doc\nfun f() {}",
+		"var = ; print \"unterminated",
+	}
+
+	for i, tt := range tests {
+		root, _, _ := Parse(tt)
+		if got := root.Text(); got != tt {
+			t.Errorf("test %d: text does not round trip. expected=%q, got=%q", i+1, tt, got)
+		}
+	}
+}
+
+func TestParse_Nodes(t *testing.T) {
+	src := "var a = 1; // one\n\nfun f(x) {\n  print (x + a) * 2;\n}\n"
+	root, stmts, errs := Parse(src)
+	if len(errs) > 0 {
+		t.Fatalf("unexpected parse errors: %+v", errs)
+	}
+
+	tests := []struct {
+		node     interface{}
+		expected string
+	}{
+		{stmts[0], "var a = 1; // one"},
+		{stmts[1], "\n\nfun f(x) {\n  print (x + a) * 2;\n}"},
+		{stmts[1].(*parser.FunctionStmt).Body[0], "\n  print (x + a) * 2;"},
+		{stmts[1].(*parser.FunctionStmt).Body[0].(*parser.PrintStmt).Expression, "(x + a) * 2"},
+	}
+
+	for i, tt := range tests {
+		n := root.Find(tt.node)
+		if n == nil {
+			t.Errorf("test %d: node not found in tree", i+1)
+			continue
+		}
+		if got := n.Text(); got != tt.expected {
+			t.Errorf("test %d: unexpected text. expected=%q, got=%q", i+1, tt.expected, got)
+		}
+	}
+
+	if last := root.Children[len(root.Children)-1]; last.Token == nil || !strings.HasPrefix(last.Token.Leading, "\n") {
+		t.Errorf("expected the final newline to be EOF trivia, got=%+v", last)
+	}
+}
@@ -0,0 +1,460 @@
+// Package dap implements a Debug Adapter Protocol server for Lox, as run by "glox dap". It
+// launches one script for a client, usually an editor, which sets breakpoints, steps through the
+// script and inspects it while it is paused, using the interpreter's debugging hook.
+package dap
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/butlermatt/glox/debugger"
+	"github.com/butlermatt/glox/interpreter"
+	"github.com/butlermatt/glox/lexer"
+	"github.com/butlermatt/glox/parser"
+)
+
+// threadID identifies the script's only thread. Spawned tasks run without the debugger.
+const threadID = 1
+
+var errNotPaused = errors.New("the script is not paused")
+
+// Server debugs a script for one client.
+type Server struct {
+	conn *conn
+	caps []interpreter.Capability
+
+	path     string // the absolute path of the launched script
+	interp   *interpreter.Interpreter
+	debugger *debugger.Debugger
+	nextID   int // the next breakpoint id
+
+	ctx    context.Context // cancelled to stop the script
+	cancel context.CancelFunc
+	done   chan struct{} // closed when the script ends
+
+	mu     sync.Mutex // guards stop and refs, which the script sets as it pauses
+	stop   *debugger.Stop
+	refs   []interface{} // the values expanded by variable references, from 1, until the script resumes
+	resume chan debugger.Action
+}
+
+// NewServer returns a Server which reads requests from r and writes responses and events to w.
+// The script it launches is granted caps, and reads no input.
+func NewServer(r io.Reader, w io.Writer, caps ...interpreter.Capability) *Server {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Server{
+		conn:   newConn(r, w),
+		caps:   caps,
+		ctx:    ctx,
+		cancel: cancel,
+		resume: make(chan debugger.Action),
+	}
+}
+
+// Run serves requests until the client disconnects or closes the input, stopping the script if
+// it is still running.
+func (s *Server) Run() error {
+	defer s.terminate()
+	for {
+		msg, err := s.conn.read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if msg.Type != "request" {
+			continue
+		}
+
+		body, herr := s.handle(msg)
+		if err := s.conn.respond(msg, body, herr); err != nil {
+			return err
+		}
+		if herr != nil {
+			continue
+		}
+		// Whatever follows a request happens after its response, as clients expect.
+		switch msg.Command {
+		case "launch":
+			err = s.conn.event("initialized", nil)
+		case "configurationDone":
+			s.start()
+		case "continue":
+			s.resumeWith(debugger.Continue)
+		case "next":
+			s.resumeWith(debugger.StepOver)
+		case "stepIn":
+			s.resumeWith(debugger.StepInto)
+		case "stepOut":
+			s.resumeWith(debugger.StepOut)
+		case "terminate":
+			s.terminate()
+		case "disconnect":
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) handle(msg *message) (interface{}, error) {
+	switch msg.Command {
+	case "initialize":
+		return Capabilities{
+			SupportsConfigurationDoneRequest: true,
+			SupportsEvaluateForHovers:        true,
+			SupportsTerminateRequest:         true,
+		}, nil
+	case "launch":
+		var args LaunchArguments
+		if err := unmarshal(msg, &args); err != nil {
+			return nil, err
+		}
+		return nil, s.launch(args)
+	case "setBreakpoints":
+		var args SetBreakpointsArguments
+		if err := unmarshal(msg, &args); err != nil {
+			return nil, err
+		}
+		return s.setBreakpoints(args), nil
+	case "setExceptionBreakpoints":
+		return SetBreakpointsResponse{Breakpoints: []Breakpoint{}}, nil
+	case "configurationDone":
+		if s.interp == nil {
+			return nil, errors.New("no script has been launched")
+		}
+		return nil, nil
+	case "threads":
+		return ThreadsResponse{Threads: []Thread{{ID: threadID, Name: "main"}}}, nil
+	case "stackTrace":
+		var args StackTraceArguments
+		if err := unmarshal(msg, &args); err != nil {
+			return nil, err
+		}
+		return s.stackTrace(args)
+	case "scopes":
+		var args ScopesArguments
+		if err := unmarshal(msg, &args); err != nil {
+			return nil, err
+		}
+		return s.scopes(args)
+	case "variables":
+		var args VariablesArguments
+		if err := unmarshal(msg, &args); err != nil {
+			return nil, err
+		}
+		return s.variables(args)
+	case "evaluate":
+		var args EvaluateArguments
+		if err := unmarshal(msg, &args); err != nil {
+			return nil, err
+		}
+		return s.evaluate(args)
+	case "continue", "next", "stepIn", "stepOut":
+		if s.paused() == nil {
+			return nil, errNotPaused
+		}
+		if msg.Command == "continue" {
+			return ContinueResponse{AllThreadsContinued: true}, nil
+		}
+		return nil, nil
+	case "pause":
+		if s.debugger == nil {
+			return nil, errors.New("no script has been launched")
+		}
+		s.debugger.Pause()
+		return nil, nil
+	case "terminate", "disconnect":
+		return nil, nil
+	}
+	return nil, fmt.Errorf("unsupported request %q", msg.Command)
+}
+
+func unmarshal(msg *message, v interface{}) error {
+	if len(msg.Arguments) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(msg.Arguments, v); err != nil {
+		return fmt.Errorf("invalid arguments: %v", err)
+	}
+	return nil
+}
+
+// launch prepares the script to run once the client has finished its configuration.
+func (s *Server) launch(args LaunchArguments) error {
+	if s.interp != nil {
+		return errors.New("a script has already been launched")
+	}
+	path, err := filepath.Abs(args.Program)
+	if err != nil {
+		return err
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	p := parser.New(lexer.New(string(data)))
+	stmts := p.Parse()
+	if errs := p.Errors(); len(errs) > 0 {
+		var msgs []string
+		for _, e := range errs {
+			msgs = append(msgs, fmt.Sprintf("[Syntax Error line %d] Error %s: %s", e.Line, e.Where, e.Msg))
+		}
+		return errors.New(strings.Join(msgs, "\n"))
+	}
+	prog, err := interpreter.Compile(stmts)
+	if err != nil {
+		return err
+	}
+
+	s.path = path
+	s.interp = prog.NewInterpreter(s.caps...)
+	s.interp.SetArgs(args.Args)
+	s.interp.SetInput(strings.NewReader(""))
+	s.interp.SetOutput(&output{conn: s.conn, category: CategoryStdout})
+	s.debugger = debugger.New(p, stmts, s)
+	s.debugger.StopOnEntry = args.StopOnEntry
+	if !args.NoDebug {
+		s.interp.SetHook(s.debugger)
+	}
+	return nil
+}
+
+// start runs the script, reporting when it ends.
+func (s *Server) start() {
+	s.done = make(chan struct{})
+	go func() {
+		defer close(s.done)
+		err := s.interp.Interpret(s.ctx)
+		if s.ctx.Err() == nil {
+			code := 0
+			if e, ok := err.(*interpreter.ExitError); ok {
+				code = e.Code
+			} else if err != nil {
+				s.conn.event("output", OutputEvent{Category: CategoryStderr, Output: err.Error() + "\n"})
+				code = 70
+			}
+			s.conn.event("exited", ExitedEvent{ExitCode: code})
+		}
+		s.conn.event("terminated", nil)
+	}()
+}
+
+// terminate stops the script, if it is running, and waits for it to end.
+func (s *Server) terminate() {
+	s.cancel()
+	if s.done != nil {
+		<-s.done
+	}
+}
+
+// Paused implements debugger.Frontend, waiting for the client to resume the script.
+func (s *Server) Paused(stop *debugger.Stop) (debugger.Action, error) {
+	s.mu.Lock()
+	s.stop, s.refs = stop, nil
+	s.mu.Unlock()
+
+	s.conn.event("stopped", StoppedEvent{Reason: stop.Reason, ThreadID: threadID, AllThreadsStopped: true})
+	select {
+	case action := <-s.resume:
+		return action, nil
+	case <-s.ctx.Done():
+		return debugger.Continue, debugger.ErrQuit
+	}
+}
+
+// paused returns the paused script, or nil if it is running.
+func (s *Server) paused() *debugger.Stop {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stop
+}
+
+func (s *Server) resumeWith(action debugger.Action) {
+	s.mu.Lock()
+	s.stop, s.refs = nil, nil
+	s.mu.Unlock()
+	s.resume <- action
+}
+
+func (s *Server) setBreakpoints(args SetBreakpointsArguments) SetBreakpointsResponse {
+	lines := args.Lines
+	if args.Breakpoints != nil {
+		lines = nil
+		for _, bp := range args.Breakpoints {
+			lines = append(lines, bp.Line)
+		}
+	}
+
+	var message string
+	path, _ := filepath.Abs(args.Source.Path)
+	switch {
+	case s.debugger == nil:
+		message = "No script has been launched."
+	case path != s.path:
+		message = "Breakpoints can only be set in the launched script."
+	default:
+		s.debugger.ClearBreakpoints()
+	}
+
+	res := SetBreakpointsResponse{Breakpoints: []Breakpoint{}}
+	for _, line := range lines {
+		bp := Breakpoint{Message: message}
+		if message == "" {
+			if l, ok := s.debugger.SetBreakpoint(line); ok {
+				s.nextID++
+				bp = Breakpoint{ID: s.nextID, Verified: true, Source: s.source(), Line: l}
+			} else {
+				bp.Message = "No statement on or after line " + strconv.Itoa(line) + "."
+			}
+		}
+		res.Breakpoints = append(res.Breakpoints, bp)
+	}
+	return res
+}
+
+func (s *Server) source() *Source {
+	return &Source{Name: filepath.Base(s.path), Path: s.path}
+}
+
+// Frames are numbered from 1, innermost first.
+func (s *Server) stackTrace(args StackTraceArguments) (interface{}, error) {
+	stop := s.paused()
+	if stop == nil {
+		return nil, errNotPaused
+	}
+
+	frames := stop.Frames()
+	res := StackTraceResponse{StackFrames: []StackFrame{}, TotalFrames: len(frames)}
+	for n := args.StartFrame; n < len(frames); n++ {
+		if args.Levels > 0 && len(res.StackFrames) == args.Levels {
+			break
+		}
+		f := frames[n]
+		res.StackFrames = append(res.StackFrames, StackFrame{ID: n + 1, Name: f.Name, Source: s.source(), Line: f.Line, Column: 1})
+	}
+	return res, nil
+}
+
+func (s *Server) scopes(args ScopesArguments) (interface{}, error) {
+	stop := s.paused()
+	if stop == nil {
+		return nil, errNotPaused
+	}
+	scopes := stop.Scopes(args.FrameID - 1)
+	if scopes == nil {
+		return nil, fmt.Errorf("no frame %d", args.FrameID)
+	}
+
+	res := ScopesResponse{Scopes: []Scope{}}
+	for _, scope := range scopes {
+		sc := Scope{Name: scope.Name, VariablesReference: s.reference(scope.Vars)}
+		switch scope.Name {
+		case "Locals":
+			sc.PresentationHint = "locals"
+		case "Globals":
+			sc.Expensive = true
+		}
+		res.Scopes = append(res.Scopes, sc)
+	}
+	return res, nil
+}
+
+// reference returns a new variable reference which expands v.
+func (s *Server) reference(v interface{}) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.refs = append(s.refs, v)
+	return len(s.refs)
+}
+
+func (s *Server) variables(args VariablesArguments) (interface{}, error) {
+	if s.paused() == nil {
+		return nil, errNotPaused
+	}
+	s.mu.Lock()
+	var v interface{}
+	if n := args.VariablesReference; n >= 1 && n <= len(s.refs) {
+		v = s.refs[n-1]
+	}
+	s.mu.Unlock()
+
+	res := VariablesResponse{Variables: []Variable{}}
+	switch v := v.(type) {
+	case []debugger.Var:
+		for _, vr := range v {
+			res.Variables = append(res.Variables, s.variable(vr.Name, vr.Value))
+		}
+	case *interpreter.LoxArray:
+		for n, el := range v.Elements {
+			res.Variables = append(res.Variables, s.variable("["+strconv.Itoa(n)+"]", el))
+		}
+	case *interpreter.LoxInstance:
+		for _, name := range v.FieldNames() {
+			field, _ := v.Field(name)
+			res.Variables = append(res.Variables, s.variable(name, field))
+		}
+	default:
+		return nil, fmt.Errorf("no variables for reference %d", args.VariablesReference)
+	}
+	return res, nil
+}
+
+func (s *Server) variable(name string, v interface{}) Variable {
+	return Variable{Name: name, Value: debugger.Format(v), VariablesReference: s.expand(v)}
+}
+
+// expand returns a reference to the elements or fields of v, or 0 if it has none.
+func (s *Server) expand(v interface{}) int {
+	switch v := v.(type) {
+	case *interpreter.LoxArray:
+		if len(v.Elements) > 0 {
+			return s.reference(v)
+		}
+	case *interpreter.LoxInstance:
+		if len(v.FieldNames()) > 0 {
+			return s.reference(v)
+		}
+	}
+	return 0
+}
+
+func (s *Server) evaluate(args EvaluateArguments) (interface{}, error) {
+	stop := s.paused()
+	if stop == nil {
+		return nil, errNotPaused
+	}
+	frame := 0
+	if args.FrameID != nil {
+		frame = *args.FrameID - 1
+	}
+	v, err := stop.Eval(frame, args.Expression)
+	if err != nil {
+		return nil, err
+	}
+	return EvaluateResponse{Result: debugger.Format(v), VariablesReference: s.expand(v)}, nil
+}
+
+// output sends what the script prints to the client.
+type output struct {
+	conn     *conn
+	category string
+}
+
+func (o *output) Write(p []byte) (int, error) {
+	if err := o.conn.event("output", OutputEvent{Category: o.category, Output: string(p)}); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,348 @@
+package dap
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// client drives a Server over pipes, as an editor would.
+type client struct {
+	t      *testing.T
+	conn   *conn
+	events []*message // events read while waiting for a response
+	done   chan error
+}
+
+func newClient(t *testing.T) *client {
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	c := &client{t: t, conn: newConn(outR, inW), done: make(chan error, 1)}
+	go func() {
+		c.done <- NewServer(inR, outW).Run()
+		outW.Close()
+	}()
+	return c
+}
+
+// call sends a request and decodes the body of its response, returning the message of a failure.
+func (c *client) call(command string, args, body interface{}) string {
+	c.t.Helper()
+	req := &message{Type: "request", Command: command, Arguments: mustMarshal(c.t, args)}
+	if err := c.conn.write(req); err != nil {
+		c.t.Fatal(err)
+	}
+
+	for {
+		msg := c.read()
+		if msg.Type == "event" {
+			c.events = append(c.events, msg)
+			continue
+		}
+		if msg.Type != "response" || msg.RequestSeq != req.Seq || msg.Command != command {
+			c.t.Fatalf("%s: expected the response to request %d, got %+v", command, req.Seq, msg)
+		}
+		if msg.Success == nil || !*msg.Success {
+			return msg.Message
+		}
+		if body != nil {
+			if err := json.Unmarshal(msg.Body, body); err != nil {
+				c.t.Fatalf("%s: %v", command, err)
+			}
+		}
+		return ""
+	}
+}
+
+// mustCall is call, failing the test if the request fails.
+func (c *client) mustCall(command string, args, body interface{}) {
+	c.t.Helper()
+	if msg := c.call(command, args, body); msg != "" {
+		c.t.Fatalf("%s: unexpected failure: %s", command, msg)
+	}
+}
+
+// event returns the body of the next event with the given name, skipping output events, which
+// are collected instead.
+func (c *client) event(name string, body interface{}) {
+	c.t.Helper()
+	for {
+		var msg *message
+		if len(c.events) > 0 {
+			msg, c.events = c.events[0], c.events[1:]
+		} else {
+			msg = c.read()
+		}
+		if msg.Type == "event" && msg.Event == "output" && name != "output" {
+			continue
+		}
+		if msg.Type != "event" || msg.Event != name {
+			c.t.Fatalf("expected a %s event, got %+v", name, msg)
+		}
+		if body != nil {
+			if err := json.Unmarshal(msg.Body, body); err != nil {
+				c.t.Fatalf("%s: %v", name, err)
+			}
+		}
+		return
+	}
+}
+
+func (c *client) read() *message {
+	c.t.Helper()
+	msg, err := c.conn.read()
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	return msg
+}
+
+func mustMarshal(t *testing.T, v interface{}) json.RawMessage {
+	if v == nil {
+		return nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+const source = `fun add(a, b) {
+  var sum = a + b;
+  return sum;
+}
+
+class Point {
+  init(x, y) { this.x = x; this.y = y; }
+}
+
+var p = Point(1, [2, 3]);
+var total = add(p.x, 10);
+print total;
+exit(3);
+`
+
+func writeScript(t *testing.T, src string) (string, func()) {
+	dir, err := ioutil.TempDir("", "glox")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "script.lox")
+	if err := ioutil.WriteFile(path, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path, func() { os.RemoveAll(dir) }
+}
+
+func TestServer(t *testing.T) {
+	path, cleanup := writeScript(t, source)
+	defer cleanup()
+
+	c := newClient(t)
+	var caps Capabilities
+	c.mustCall("initialize", map[string]interface{}{"adapterID": "glox"}, &caps)
+	if !caps.SupportsConfigurationDoneRequest {
+		t.Errorf("expected configurationDone to be supported")
+	}
+	c.mustCall("launch", LaunchArguments{Program: path, StopOnEntry: true}, nil)
+	c.event("initialized", nil)
+
+	var bps SetBreakpointsResponse
+	c.mustCall("setBreakpoints", SetBreakpointsArguments{
+		Source:      Source{Path: path},
+		Breakpoints: []SourceBreakpoint{{Line: 3}, {Line: 4}, {Line: 20}},
+	}, &bps)
+	var lines []int
+	for _, bp := range bps.Breakpoints {
+		lines = append(lines, bp.Line)
+		if bp.Verified != (bp.Line != 0) {
+			t.Errorf("unexpected breakpoint %+v", bp)
+		}
+	}
+	if expected := []int{3, 6, 0}; !reflect.DeepEqual(lines, expected) {
+		t.Errorf("breakpoints: expected lines %v, got %v", expected, lines)
+	}
+	c.mustCall("setBreakpoints", SetBreakpointsArguments{Source: Source{Path: path}, Lines: []int{3}}, &bps)
+	c.mustCall("setExceptionBreakpoints", map[string]interface{}{"filters": []string{}}, nil)
+	c.mustCall("configurationDone", nil, nil)
+
+	var stopped StoppedEvent
+	c.event("stopped", &stopped)
+	if stopped.Reason != "entry" || stopped.ThreadID != threadID {
+		t.Errorf("unexpected stop %+v", stopped)
+	}
+	var threads ThreadsResponse
+	c.mustCall("threads", nil, &threads)
+	if len(threads.Threads) != 1 {
+		t.Errorf("unexpected threads %+v", threads)
+	}
+
+	c.mustCall("continue", map[string]int{"threadId": threadID}, nil)
+	c.event("stopped", &stopped)
+	if stopped.Reason != "breakpoint" {
+		t.Errorf("expected to stop at the breakpoint, got %+v", stopped)
+	}
+
+	var trace StackTraceResponse
+	c.mustCall("stackTrace", StackTraceArguments{ThreadID: threadID}, &trace)
+	var frames []string
+	for _, f := range trace.StackFrames {
+		frames = append(frames, f.Name+":"+strconv.Itoa(f.Line))
+		if f.Source == nil || f.Source.Path != path {
+			t.Errorf("unexpected source for frame %+v", f)
+		}
+	}
+	if got := strings.Join(frames, " "); got != "add:3 <script>:11" || trace.TotalFrames != 2 {
+		t.Errorf("unexpected stack trace %q (%d frames)", got, trace.TotalFrames)
+	}
+
+	var scopes ScopesResponse
+	c.mustCall("scopes", ScopesArguments{FrameID: trace.StackFrames[0].ID}, &scopes)
+	if len(scopes.Scopes) != 2 || scopes.Scopes[0].Name != "Locals" || scopes.Scopes[1].Name != "Globals" {
+		t.Fatalf("unexpected scopes %+v", scopes)
+	}
+	if got := variables(c, scopes.Scopes[0].VariablesReference); got != "a=1 b=10 sum=11" {
+		t.Errorf("unexpected locals %q", got)
+	}
+
+	var vars VariablesResponse
+	c.mustCall("variables", VariablesArguments{VariablesReference: scopes.Scopes[1].VariablesReference}, &vars)
+	var p Variable
+	for _, v := range vars.Variables {
+		if v.Name == "p" {
+			p = v
+		}
+	}
+	if p.Value != "Point instance" || p.VariablesReference == 0 {
+		t.Fatalf("unexpected global p %+v", p)
+	}
+	c.mustCall("variables", VariablesArguments{VariablesReference: p.VariablesReference}, &vars)
+	if len(vars.Variables) != 2 || vars.Variables[1].Name != "y" || vars.Variables[1].VariablesReference == 0 {
+		t.Fatalf("unexpected fields %+v", vars)
+	}
+	if got := variables(c, vars.Variables[1].VariablesReference); got != "[0]=2 [1]=3" {
+		t.Errorf("unexpected elements %q", got)
+	}
+
+	var eval EvaluateResponse
+	c.mustCall("evaluate", EvaluateArguments{Expression: "sum * 2", FrameID: &trace.StackFrames[0].ID}, &eval)
+	if eval.Result != "22" {
+		t.Errorf("unexpected evaluation %+v", eval)
+	}
+	c.mustCall("evaluate", EvaluateArguments{Expression: "p.y", FrameID: &trace.StackFrames[1].ID}, &eval)
+	if eval.Result != "[2 3]" || eval.VariablesReference == 0 {
+		t.Errorf("unexpected evaluation %+v", eval)
+	}
+	if msg := c.call("evaluate", EvaluateArguments{Expression: "nope"}, nil); !strings.Contains(msg, "Undefined variable 'nope'") {
+		t.Errorf("unexpected evaluation failure %q", msg)
+	}
+	for _, src := range []string{"", "// x"} {
+		if msg := c.call("evaluate", EvaluateArguments{Expression: src}, nil); msg == "" {
+			t.Errorf("expected evaluating %q to fail", src)
+		}
+	}
+
+	c.mustCall("stepOut", map[string]int{"threadId": threadID}, nil)
+	c.event("stopped", &stopped)
+	c.mustCall("stackTrace", StackTraceArguments{ThreadID: threadID}, &trace)
+	if stopped.Reason != "step" || len(trace.StackFrames) != 1 || trace.StackFrames[0].Line != 12 {
+		t.Errorf("unexpected step out to %+v %+v", stopped, trace)
+	}
+	c.mustCall("next", map[string]int{"threadId": threadID}, nil)
+	var out OutputEvent
+	c.event("output", &out)
+	if out.Category != CategoryStdout || out.Output != "11\n" {
+		t.Errorf("unexpected output %+v", out)
+	}
+	c.event("stopped", &stopped)
+
+	c.mustCall("continue", map[string]int{"threadId": threadID}, nil)
+	var exited ExitedEvent
+	c.event("exited", &exited)
+	if exited.ExitCode != 3 {
+		t.Errorf("expected exit code 3, got %d", exited.ExitCode)
+	}
+	c.event("terminated", nil)
+	if msg := c.call("stackTrace", StackTraceArguments{ThreadID: threadID}, nil); msg != errNotPaused.Error() {
+		t.Errorf("expected a failure once the script ended, got %q", msg)
+	}
+
+	c.mustCall("disconnect", nil, nil)
+	if err := <-c.done; err != nil {
+		t.Errorf("unexpected error from Run: %v", err)
+	}
+}
+
+func TestServer_Terminate(t *testing.T) {
+	path, cleanup := writeScript(t, "var i = 0;\nwhile (true) {\n  i = i + 1;\n}\n")
+	defer cleanup()
+
+	c := newClient(t)
+	c.mustCall("initialize", nil, nil)
+	if msg := c.call("launch", LaunchArguments{Program: path + ".missing"}, nil); msg == "" {
+		t.Errorf("expected launching a missing script to fail")
+	}
+	c.mustCall("launch", LaunchArguments{Program: path}, nil)
+	c.event("initialized", nil)
+	c.mustCall("configurationDone", nil, nil)
+
+	c.mustCall("pause", map[string]int{"threadId": threadID}, nil)
+	var stopped StoppedEvent
+	c.event("stopped", &stopped)
+	if stopped.Reason != "pause" {
+		t.Errorf("unexpected stop %+v", stopped)
+	}
+	var eval EvaluateResponse
+	c.mustCall("evaluate", EvaluateArguments{Expression: "2 * 21"}, &eval)
+	if eval.Result != "42" {
+		t.Errorf("unexpected evaluation %+v", eval)
+	}
+
+	c.mustCall("continue", map[string]int{"threadId": threadID}, nil)
+	c.mustCall("terminate", nil, nil)
+	c.event("terminated", nil)
+	c.mustCall("disconnect", nil, nil)
+	if err := <-c.done; err != nil {
+		t.Errorf("unexpected error from Run: %v", err)
+	}
+}
+
+func TestServer_SyntaxError(t *testing.T) {
+	path, cleanup := writeScript(t, "var x = ;\n")
+	defer cleanup()
+
+	c := newClient(t)
+	c.mustCall("initialize", nil, nil)
+	if msg := c.call("launch", LaunchArguments{Program: path}, nil); !strings.Contains(msg, "[Syntax Error line 1]") {
+		t.Errorf("unexpected launch failure %q", msg)
+	}
+	if msg := c.call("stepIn", nil, nil); msg != errNotPaused.Error() {
+		t.Errorf("unexpected failure %q", msg)
+	}
+	if msg := c.call("bogus", nil, nil); msg == "" {
+		t.Errorf("expected an unsupported request to fail")
+	}
+	c.mustCall("disconnect", nil, nil)
+	if err := <-c.done; err != nil {
+		t.Errorf("unexpected error from Run: %v", err)
+	}
+}
+
+// variables returns the variables of a reference as name=value pairs.
+func variables(c *client, ref int) string {
+	c.t.Helper()
+	var vars VariablesResponse
+	c.mustCall("variables", VariablesArguments{VariablesReference: ref}, &vars)
+	var pairs []string
+	for _, v := range vars.Variables {
+		pairs = append(pairs, v.Name+"="+v.Value)
+	}
+	return strings.Join(pairs, " ")
+}
@@ -0,0 +1,160 @@
+package debugger
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const consoleHelp = `Commands:
+  c, continue        run until the next breakpoint
+  n, next            step to the next line, over calls
+  s, step            step to the next line, into calls
+  o, out             step out of the current function
+  b, break [line]    set a breakpoint, or list them
+  d, delete line     delete a breakpoint
+  bt, backtrace      show the call stack
+  f, frame n         select frame n of the call stack
+  v, vars            show the variables visible from the selected frame
+  p, print expr      evaluate an expression in the selected frame
+  l, list            show the source around the selected frame
+  q, quit            stop the script
+An empty line repeats the previous command.
+`
+
+// Console is a Frontend which reads commands from a terminal.
+type Console struct {
+	d      *Debugger
+	in     *bufio.Reader
+	out    io.Writer
+	source []string
+
+	last  string // the previous command, repeated by an empty line
+	frame int    // the selected frame
+}
+
+// NewConsole returns a Console reading commands from in and writing to out, which debugs the
+// script with the given source text. It must be attached with SetDebugger before the script runs.
+func NewConsole(in *bufio.Reader, out io.Writer, source string) *Console {
+	return &Console{in: in, out: out, source: strings.Split(source, "\n")}
+}
+
+// SetDebugger sets the Debugger whose breakpoints the console manages.
+func (c *Console) SetDebugger(d *Debugger) {
+	c.d = d
+}
+
+// Paused implements Frontend, reading commands until one resumes the script.
+func (c *Console) Paused(s *Stop) (Action, error) {
+	c.frame = 0
+	fmt.Fprintf(c.out, "Paused at line %d (%s)\n", s.Line, s.Reason)
+	c.showLine(s.Line)
+
+	for {
+		fmt.Fprint(c.out, "(glox) ")
+		line, err := c.in.ReadString('\n')
+		if line == "" && err != nil {
+			fmt.Fprintln(c.out)
+			return Continue, ErrQuit
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			line = c.last
+		}
+		c.last = line
+
+		cmd, arg := line, ""
+		if i := strings.IndexAny(line, " \t"); i >= 0 {
+			cmd, arg = line[:i], strings.TrimSpace(line[i+1:])
+		}
+		switch cmd {
+		case "":
+		case "c", "continue":
+			return Continue, nil
+		case "n", "next":
+			return StepOver, nil
+		case "s", "step":
+			return StepInto, nil
+		case "o", "out":
+			return StepOut, nil
+		case "q", "quit":
+			return Continue, ErrQuit
+		case "b", "break":
+			c.breakCmd(arg)
+		case "d", "delete":
+			if n, err := strconv.Atoi(arg); err == nil {
+				c.d.ClearBreakpoint(n)
+			} else {
+				fmt.Fprintln(c.out, "Usage: delete line")
+			}
+		case "bt", "backtrace":
+			for i, f := range s.Frames() {
+				marker := " "
+				if i == c.frame {
+					marker = "*"
+				}
+				fmt.Fprintf(c.out, "%s#%d %s at line %d\n", marker, i, f.Name, f.Line)
+			}
+		case "f", "frame":
+			n, err := strconv.Atoi(arg)
+			if err != nil || n < 0 || n >= len(s.Frames()) {
+				fmt.Fprintln(c.out, "Usage: frame n, with n from the backtrace")
+				continue
+			}
+			c.frame = n
+			f := s.Frames()[n]
+			fmt.Fprintf(c.out, "#%d %s at line %d\n", n, f.Name, f.Line)
+		case "v", "vars":
+			for _, scope := range s.Scopes(c.frame) {
+				fmt.Fprintf(c.out, "%s:\n", scope.Name)
+				for _, v := range scope.Vars {
+					fmt.Fprintf(c.out, "  %s = %s\n", v.Name, Format(v.Value))
+				}
+			}
+		case "p", "print":
+			v, err := s.Eval(c.frame, arg)
+			if err != nil {
+				fmt.Fprintln(c.out, err)
+			} else {
+				fmt.Fprintln(c.out, Format(v))
+			}
+		case "l", "list":
+			line := s.Frames()[c.frame].Line
+			for n := line - 3; n <= line+3; n++ {
+				c.showLine(n)
+			}
+		case "h", "help":
+			fmt.Fprint(c.out, consoleHelp)
+		default:
+			fmt.Fprintf(c.out, "Unknown command %q. Type help for a list.\n", cmd)
+		}
+	}
+}
+
+func (c *Console) breakCmd(arg string) {
+	if arg == "" {
+		for _, l := range c.d.Breakpoints() {
+			fmt.Fprintf(c.out, "Breakpoint at line %d\n", l)
+		}
+		return
+	}
+	n, err := strconv.Atoi(arg)
+	if err != nil {
+		fmt.Fprintln(c.out, "Usage: break line")
+		return
+	}
+	if l, ok := c.d.SetBreakpoint(n); ok {
+		fmt.Fprintf(c.out, "Breakpoint at line %d\n", l)
+	} else {
+		fmt.Fprintf(c.out, "No statement at or after line %d\n", n)
+	}
+}
+
+// showLine prints a numbered line of the source, if it exists.
+func (c *Console) showLine(n int) {
+	if n >= 1 && n <= len(c.source) {
+		fmt.Fprintf(c.out, "%4d | %s\n", n, c.source[n-1])
+	}
+}
@@ -0,0 +1,302 @@
+// Package debugger pauses a running script at line breakpoints and steps through it, using the
+// interpreter's Hook. A Frontend decides what to do while the script is paused: Console reads
+// commands from a terminal, as run by "glox debug".
+package debugger
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/butlermatt/glox/interpreter"
+	"github.com/butlermatt/glox/lexer"
+	"github.com/butlermatt/glox/parser"
+)
+
+// ErrQuit is returned from a Frontend to stop the script.
+var ErrQuit = errors.New("debugger quit")
+
+// Action resumes a paused script.
+type Action int
+
+const (
+	Continue Action = iota // Run until a breakpoint
+	StepOver               // Pause at the next line of this frame or a caller
+	StepInto               // Pause at the next line, in any frame
+	StepOut                // Pause at the next line of a caller
+)
+
+// Frontend is told when the script pauses. Paused is called on the script's goroutine, which
+// waits for it to return the action with which to resume, or an error to stop the script.
+type Frontend interface {
+	Paused(s *Stop) (Action, error)
+}
+
+// Debugger is the Hook which decides where a script pauses.
+type Debugger struct {
+	// StopOnEntry pauses the script before its first statement.
+	StopOnEntry bool
+
+	p        *parser.Parser
+	frontend Frontend
+	lines    map[int]bool // the lines on which statements begin
+
+	mu          sync.Mutex // guards breakpoints and pause, which may be changed while the script runs
+	breakpoints map[int]bool
+	pause       bool
+
+	started   bool
+	action    Action
+	depth     int // the depth of the frame in which the action began
+	lastLine  int // where the previous statement began
+	lastDepth int
+}
+
+// New returns a Debugger for stmts, parsed by p, which reports pauses to f.
+func New(p *parser.Parser, stmts []parser.Stmt, f Frontend) *Debugger {
+	d := &Debugger{p: p, frontend: f, lines: make(map[int]bool), breakpoints: make(map[int]bool)}
+	for _, s := range stmts {
+		parser.Inspect(s, func(node interface{}) bool {
+			if stmt, ok := node.(parser.Stmt); ok {
+				if _, block := stmt.(*parser.BlockStmt); !block {
+					d.lines[d.line(stmt)] = true
+				}
+			}
+			return true
+		})
+	}
+	return d
+}
+
+// SetBreakpoint sets a breakpoint on the first line from line on which a statement begins,
+// returning that line, or false if there is none.
+func (d *Debugger) SetBreakpoint(line int) (int, bool) {
+	max := 0
+	for l := range d.lines {
+		if l > max {
+			max = l
+		}
+	}
+	for ; line <= max; line++ {
+		if d.lines[line] {
+			d.mu.Lock()
+			d.breakpoints[line] = true
+			d.mu.Unlock()
+			return line, true
+		}
+	}
+	return 0, false
+}
+
+// ClearBreakpoint removes the breakpoint on line, if any.
+func (d *Debugger) ClearBreakpoint(line int) {
+	d.mu.Lock()
+	delete(d.breakpoints, line)
+	d.mu.Unlock()
+}
+
+// ClearBreakpoints removes every breakpoint.
+func (d *Debugger) ClearBreakpoints() {
+	d.mu.Lock()
+	d.breakpoints = make(map[int]bool)
+	d.mu.Unlock()
+}
+
+// Breakpoints returns the lines with breakpoints, in order.
+func (d *Debugger) Breakpoints() []int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var lines []int
+	for l := range d.breakpoints {
+		lines = append(lines, l)
+	}
+	sort.Ints(lines)
+	return lines
+}
+
+// Pause asks the running script to pause before its next statement.
+func (d *Debugger) Pause() {
+	d.mu.Lock()
+	d.pause = true
+	d.mu.Unlock()
+}
+
+// pauseRequested reports whether Pause was called since the script last paused.
+func (d *Debugger) pauseRequested() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	pause := d.pause
+	d.pause = false
+	return pause
+}
+
+func (d *Debugger) hasBreakpoint(line int) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.breakpoints[line]
+}
+
+// line returns the line on which node begins.
+func (d *Debugger) line(node interface{}) int {
+	if s, ok := d.p.Span(node); ok {
+		return s.First.Line
+	}
+	line := 0
+	for _, tok := range parser.Tokens(node) {
+		if line == 0 || tok.Line < line {
+			line = tok.Line
+		}
+	}
+	return line
+}
+
+// Statement implements interpreter.Hook. The script pauses at most once per line: when it
+// reaches a line with a breakpoint, or the line at which a step ends.
+func (d *Debugger) Statement(interp *interpreter.Interpreter, stmt parser.Stmt) error {
+	if _, ok := stmt.(*parser.BlockStmt); ok {
+		return nil // The statements in the block pause instead.
+	}
+
+	line, depth := d.line(stmt), len(interp.Frames())
+	moved := line != d.lastLine || depth != d.lastDepth
+	d.lastLine, d.lastDepth = line, depth
+	first := !d.started
+	d.started = true
+
+	var reason string
+	switch {
+	case first && d.StopOnEntry:
+		reason = "entry"
+	case d.pauseRequested():
+		reason = "pause"
+	case !moved && !first:
+	case d.hasBreakpoint(line):
+		reason = "breakpoint"
+	case d.action == StepInto,
+		d.action == StepOver && depth <= d.depth,
+		d.action == StepOut && depth < d.depth:
+		reason = "step"
+	}
+	if reason == "" {
+		return nil
+	}
+
+	action, err := d.frontend.Paused(&Stop{Reason: reason, Line: line, d: d, interp: interp})
+	if err != nil {
+		return err
+	}
+	d.action, d.depth = action, depth
+	return nil
+}
+
+// Stop describes a paused script, and inspects it while it stays paused.
+type Stop struct {
+	Reason string // Why the script paused: entry, pause, breakpoint or step
+	Line   int
+
+	d      *Debugger
+	interp *interpreter.Interpreter
+}
+
+// StackFrame is a function call in progress, or the top level of the script.
+type StackFrame struct {
+	Name string
+	Line int
+	Env  *interpreter.Environment
+}
+
+// Frames returns the call stack, innermost first.
+func (s *Stop) Frames() []StackFrame {
+	var frames []StackFrame
+	for _, f := range s.interp.Frames() {
+		line := 0
+		if f.Stmt != nil {
+			line = s.d.line(f.Stmt)
+		}
+		frames = append(frames, StackFrame{Name: f.Name, Line: line, Env: f.Env})
+	}
+	return frames
+}
+
+// Scope is an environment visible from a frame.
+type Scope struct {
+	Name string // Locals, Enclosing or Globals
+	Vars []Var
+}
+
+// Var is a variable and its value.
+type Var struct {
+	Name  string
+	Value interface{}
+}
+
+// Scopes returns the environments visible from the frame at index, from the innermost to the
+// globals. Built-in functions are left out of the globals.
+func (s *Stop) Scopes(index int) []Scope {
+	frames := s.interp.Frames()
+	if index < 0 || index >= len(frames) {
+		return nil
+	}
+
+	var scopes []Scope
+	for env := frames[index].Env; env != nil; env = env.Enclosing() {
+		name := "Enclosing"
+		switch {
+		case env == s.interp.Globals():
+			name = "Globals"
+		case len(scopes) == 0:
+			name = "Locals"
+		}
+
+		scope := Scope{Name: name}
+		for _, n := range env.Names() {
+			v, _ := env.Value(n)
+			if _, builtin := v.(*interpreter.BuiltIn); !builtin {
+				scope.Vars = append(scope.Vars, Var{Name: n, Value: v})
+			}
+		}
+		scopes = append(scopes, scope)
+	}
+	return scopes
+}
+
+// Eval evaluates the expression src in the frame at index.
+func (s *Stop) Eval(index int, src string) (interface{}, error) {
+	frames := s.interp.Frames()
+	if index < 0 || index >= len(frames) {
+		return nil, fmt.Errorf("no frame %d", index)
+	}
+
+	// The semicolon makes src a statement. Errors leave out where they occur, which may be at it.
+	p := parser.New(lexer.New(src + ";"))
+	stmts := p.Parse()
+	if errs := p.Errors(); len(errs) > 0 {
+		return nil, fmt.Errorf("syntax error: %s", errs[0].Msg)
+	}
+	if len(stmts) != 1 {
+		return nil, fmt.Errorf("not an expression: %s", src)
+	}
+	expr, ok := stmts[0].(*parser.ExpressionStmt)
+	if !ok {
+		return nil, fmt.Errorf("not an expression: %s", src)
+	}
+	return s.interp.Eval(expr.Expression, frames[index].Env)
+}
+
+// Format returns the text shown for a value, with strings quoted.
+func Format(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return strconv.Quote(v)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case fmt.Stringer:
+		return v.String()
+	}
+	return strings.TrimSpace(fmt.Sprintf("%v", v))
+}
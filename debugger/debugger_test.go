@@ -0,0 +1,299 @@
+package debugger
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/butlermatt/glox/interpreter"
+	"github.com/butlermatt/glox/lexer"
+	"github.com/butlermatt/glox/parser"
+)
+
+const source = `fun add(a, b) {
+  var sum = a + b;
+  return sum;
+}
+
+class Point {
+  init(x) { this.x = x; }
+  double() {
+    return add(this.x, this.x);
+  }
+}
+
+var p = Point(3);
+var d = p.double();
+var e = d + 1;
+`
+
+// script is a Frontend which records each pause and resumes with the next of its actions.
+type script struct {
+	actions []Action
+	stops   []string
+	onStop  func(s *Stop)
+}
+
+func (f *script) Paused(s *Stop) (Action, error) {
+	var names []string
+	for _, fr := range s.Frames() {
+		names = append(names, fmt.Sprintf("%s:%d", fr.Name, fr.Line))
+	}
+	f.stops = append(f.stops, fmt.Sprintf("%s %d [%s]", s.Reason, s.Line, strings.Join(names, " ")))
+	if f.onStop != nil {
+		f.onStop(s)
+	}
+	if len(f.actions) == 0 {
+		return Continue, nil
+	}
+	a := f.actions[0]
+	f.actions = f.actions[1:]
+	return a, nil
+}
+
+func debug(t *testing.T, src string, f Frontend) (*Debugger, func() error) {
+	t.Helper()
+	p := parser.New(lexer.New(src))
+	stmts := p.Parse()
+	if errs := p.Errors(); len(errs) > 0 {
+		t.Fatalf("unexpected parse errors: %+v", errs)
+	}
+	prog, err := interpreter.Compile(stmts)
+	if err != nil {
+		t.Fatalf("unexpected compile error: %v", err)
+	}
+
+	d := New(p, stmts, f)
+	interp := prog.NewInterpreter()
+	interp.SetHook(d)
+	return d, func() error { return interp.Interpret(context.Background()) }
+}
+
+func TestDebugger_Stepping(t *testing.T) {
+	tests := []struct {
+		name        string
+		breakpoints []int
+		entry       bool
+		actions     []Action
+		expected    []string
+	}{
+		{
+			name:        "breakpoint",
+			breakpoints: []int{2},
+			expected:    []string{"breakpoint 2 [add:2 double:9 <script>:14]"},
+		},
+		{
+			name:        "snaps to the next statement",
+			breakpoints: []int{4},
+			expected:    []string{"breakpoint 6 [<script>:6]"},
+		},
+		{
+			name:     "step over",
+			entry:    true,
+			actions:  []Action{StepOver, StepOver, StepOver, StepOver, StepOver},
+			expected: []string{"entry 1 [<script>:1]", "step 6 [<script>:6]", "step 13 [<script>:13]", "step 14 [<script>:14]", "step 15 [<script>:15]"},
+		},
+		{
+			name:        "step into",
+			breakpoints: []int{14},
+			actions:     []Action{StepInto, StepInto, StepInto, StepInto},
+			expected: []string{
+				"breakpoint 14 [<script>:14]",
+				"step 9 [double:9 <script>:14]",
+				"step 2 [add:2 double:9 <script>:14]",
+				"step 3 [add:3 double:9 <script>:14]",
+				"step 15 [<script>:15]",
+			},
+		},
+		{
+			name:        "step out",
+			breakpoints: []int{7},
+			actions:     []Action{StepOut},
+			expected:    []string{"breakpoint 7 [init:7 <script>:13]", "step 14 [<script>:14]"},
+		},
+	}
+
+	for _, tt := range tests {
+		f := &script{actions: tt.actions}
+		d, run := debug(t, source, f)
+		d.StopOnEntry = tt.entry
+		for _, l := range tt.breakpoints {
+			d.SetBreakpoint(l)
+		}
+		if err := run(); err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+		}
+		if got, expected := strings.Join(f.stops, "\n"), strings.Join(tt.expected, "\n"); got != expected {
+			t.Errorf("%s: expected stops\n%s\ngot\n%s", tt.name, expected, got)
+		}
+	}
+}
+
+func TestDebugger_Breakpoints(t *testing.T) {
+	d, _ := debug(t, source, &script{})
+	if l, ok := d.SetBreakpoint(5); !ok || l != 6 {
+		t.Errorf("expected a breakpoint on line 6, got %d %v", l, ok)
+	}
+	if _, ok := d.SetBreakpoint(16); ok {
+		t.Errorf("expected no breakpoint after the last statement")
+	}
+	d.SetBreakpoint(2)
+	if got := fmt.Sprint(d.Breakpoints()); got != "[2 6]" {
+		t.Errorf("unexpected breakpoints %s", got)
+	}
+	d.ClearBreakpoint(6)
+	if got := fmt.Sprint(d.Breakpoints()); got != "[2]" {
+		t.Errorf("unexpected breakpoints %s", got)
+	}
+	d.ClearBreakpoints()
+	if got := d.Breakpoints(); len(got) != 0 {
+		t.Errorf("unexpected breakpoints %v", got)
+	}
+}
+
+func TestStop_Inspect(t *testing.T) {
+	var scopes, evals []string
+	f := &script{onStop: func(s *Stop) {
+		for _, scope := range s.Scopes(0) {
+			var vars []string
+			for _, v := range scope.Vars {
+				vars = append(vars, v.Name+"="+Format(v.Value))
+			}
+			scopes = append(scopes, scope.Name+": "+strings.Join(vars, " "))
+		}
+		for _, e := range []struct {
+			frame int
+			src   string
+		}{{0, "a * 10"}, {0, "sum = 7"}, {1, "this.x"}, {2, "p.x"}, {0, "nope"}, {0, "1 +"}, {0, "var x = 1"}, {0, ""}, {0, "// x"}, {0, "1; 2"}} {
+			v, err := s.Eval(e.frame, e.src)
+			if err != nil {
+				evals = append(evals, "error: "+err.Error())
+			} else {
+				evals = append(evals, Format(v))
+			}
+		}
+	}}
+	d, run := debug(t, source, f)
+	d.SetBreakpoint(3)
+	if err := run(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []string{
+		"Locals: a=3 b=3 sum=6",
+		"Globals: Point=Point add=<fn add> args=[] p=Point instance",
+	}
+	if strings.Join(scopes, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected scopes\n%s\ngot\n%s", strings.Join(expected, "\n"), strings.Join(scopes, "\n"))
+	}
+	expected = []string{
+		"30",
+		"7",
+		"3",
+		"3",
+		"error: [Runtime Error line 1] Undefined variable 'nope'.",
+		"error: syntax error: Expect expression.",
+		"error: not an expression: var x = 1",
+		"error: syntax error: Expect expression.",
+		"error: not an expression: // x",
+		"error: not an expression: 1; 2",
+	}
+	if strings.Join(evals, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected evaluations\n%s\ngot\n%s", strings.Join(expected, "\n"), strings.Join(evals, "\n"))
+	}
+}
+
+func TestStop_EvalChecks(t *testing.T) {
+	src := `const limit = 2;
+fun* count() {
+  const step = 1;
+  for (var i = 0; i < limit; i = i + step) {
+    yield i;
+  }
+}
+for (var n in count()) print n;
+`
+	var evals []string
+	f := &script{onStop: func(s *Stop) {
+		for _, e := range []string{"yield 1", "step = 5", "limit = 3", "[1, (limit = 3)]", "this", "super.x", "step + limit"} {
+			v, err := s.Eval(0, e)
+			if err != nil {
+				evals = append(evals, "error: "+err.Error())
+			} else {
+				evals = append(evals, Format(v))
+			}
+		}
+	}}
+	d, run := debug(t, src, f)
+	d.SetBreakpoint(5)
+	if err := run(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []string{
+		"error: [Runtime Error line 1] Cannot yield outside of a generator.",
+		"error: [Runtime Error line 1] Cannot assign to constant 'step'.",
+		"error: [Runtime Error line 1] Cannot assign to constant 'limit'.",
+		"error: [Runtime Error line 1] Cannot assign to constant 'limit'.",
+		"error: [Runtime Error line 1] Cannot use 'this' outside of a class.",
+		"error: [Runtime Error line 1] Cannot use 'super' outside of a class.",
+		"3",
+	}
+	// The breakpoint is hit once for each value, so evaluating left the generator and loop alone.
+	if len(f.stops) != 2 {
+		t.Errorf("expected two stops, got %v", f.stops)
+	}
+	expected = append(expected, expected...)
+	if strings.Join(evals, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected evaluations\n%s\ngot\n%s", strings.Join(expected, "\n"), strings.Join(evals, "\n"))
+	}
+}
+
+func TestDebugger_Quit(t *testing.T) {
+	f := &script{onStop: func(*Stop) {}}
+	d, run := debug(t, source, quitter{f})
+	d.StopOnEntry = true
+	if err := run(); err != ErrQuit {
+		t.Errorf("expected ErrQuit, got %v", err)
+	}
+}
+
+type quitter struct{ *script }
+
+func (q quitter) Paused(s *Stop) (Action, error) {
+	q.script.Paused(s)
+	return Continue, ErrQuit
+}
+
+func TestConsole(t *testing.T) {
+	in := "n\n\nb 3\nc\nbt\nv\np a + b\nf 1\np this.x\nbogus\nq\n"
+	var out bytes.Buffer
+	c := NewConsole(bufio.NewReader(strings.NewReader(in)), &out, source)
+	d, run := debug(t, source, c)
+	c.SetDebugger(d)
+	d.StopOnEntry = true
+	if err := run(); err != ErrQuit {
+		t.Fatalf("expected ErrQuit, got %v", err)
+	}
+
+	for _, want := range []string{
+		"Paused at line 1 (entry)\n   1 | fun add(a, b) {",
+		"Paused at line 6 (step)",
+		"Paused at line 13 (step)",
+		"Breakpoint at line 3",
+		"Paused at line 3 (breakpoint)\n   3 |   return sum;",
+		"*#0 add at line 3\n #1 double at line 9\n #2 <script> at line 14",
+		"Locals:\n  a = 3\n  b = 3\n  sum = 6",
+		"(glox) 6\n",
+		"#1 double at line 9",
+		"(glox) 3\n",
+		"Unknown command \"bogus\"",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("expected the output to contain %q, got\n%s", want, out.String())
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines shown around each change.
+const diffContext = 3
+
+// unifiedDiff returns the differences between the lines of a and b in unified diff format, or an
+// empty string if they are the same.
+func unifiedDiff(name string, a, b []byte) string {
+	edits := diffLines(splitLines(a), splitLines(b))
+
+	var out bytes.Buffer
+	for start := 0; start < len(edits); {
+		// Find the next change, then extend the hunk until diffContext*2 unchanged lines pass.
+		for start < len(edits) && edits[start].op == ' ' {
+			start++
+		}
+		if start == len(edits) {
+			break
+		}
+		end, same := start, 0
+		for k := start; k < len(edits) && same <= diffContext*2; k++ {
+			if edits[k].op == ' ' {
+				same++
+			} else {
+				same = 0
+				end = k + 1
+			}
+		}
+
+		from := start - diffContext
+		if from < 0 {
+			from = 0
+		}
+		to := end + diffContext
+		if to > len(edits) {
+			to = len(edits)
+		}
+
+		if out.Len() == 0 {
+			fmt.Fprintf(&out, "--- %s.orig\n+++ %s\n", name, name)
+		}
+		var countX, countY int
+		for _, e := range edits[from:to] {
+			if e.op != '+' {
+				countX++
+			}
+			if e.op != '-' {
+				countY++
+			}
+		}
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(edits[from].i, countX), hunkRange(edits[from].j, countY))
+		for _, e := range edits[from:to] {
+			out.WriteByte(e.op)
+			out.WriteString(e.line)
+			if !strings.HasSuffix(e.line, "\n") {
+				out.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		start = to
+	}
+	return out.String()
+}
+
+// splitLines splits b into lines, each keeping its newline.
+func splitLines(b []byte) []string {
+	lines := strings.SplitAfter(string(b), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// hunkRange formats the range of count lines after position pos. An empty range names the line
+// before it, as diff and patch expect.
+func hunkRange(pos, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", pos)
+	}
+	return fmt.Sprintf("%d,%d", pos+1, count)
+}
+
+// edit is a line of a diff prefixed with ' ', '-' or '+'.
+type edit struct {
+	op   byte
+	line string
+	i, j int // positions in x and y before this line
+}
+
+// diffLines returns the edits turning x into y, with the deletions of each change before its
+// insertions. It uses Myers' linear space algorithm, finding the middle of an optimal path and
+// then the paths either side of it, so memory grows with the length of the inputs rather than
+// the product of their lengths.
+func diffLines(x, y []string) []edit {
+	n := 2*((len(x)+len(y)+1)/2) + 3 // the size middle needs for the whole of x and y
+	d := &differ{x: x, y: y, vf: make([]int, n), vb: make([]int, n)}
+	d.diff(0, len(x), 0, len(y))
+
+	// Move the deletions of each change ahead of its insertions, then number the lines.
+	edits := d.edits
+	for start := 0; start < len(edits); {
+		if edits[start].op == ' ' {
+			start++
+			continue
+		}
+		end := start
+		for end < len(edits) && edits[end].op != ' ' {
+			end++
+		}
+		sort.SliceStable(edits[start:end], func(a, b int) bool {
+			return edits[start+a].op == '-' && edits[start+b].op == '+'
+		})
+		start = end
+	}
+	i, j := 0, 0
+	for k := range edits {
+		edits[k].i, edits[k].j = i, j
+		if edits[k].op != '+' {
+			i++
+		}
+		if edits[k].op != '-' {
+			j++
+		}
+	}
+	return edits
+}
+
+// differ holds the state of diffLines. vf and vb are reused by each search for a middle snake.
+type differ struct {
+	x, y   []string
+	vf, vb []int
+	edits  []edit
+}
+
+// diff appends the edits turning x[i0:i1] into y[j0:j1].
+func (d *differ) diff(i0, i1, j0, j1 int) {
+	for i0 < i1 && j0 < j1 && d.x[i0] == d.y[j0] {
+		d.edits = append(d.edits, edit{op: ' ', line: d.x[i0]})
+		i0, j0 = i0+1, j0+1
+	}
+	same := 0
+	for i1 > i0 && j1 > j0 && d.x[i1-1] == d.y[j1-1] {
+		i1, j1, same = i1-1, j1-1, same+1
+	}
+
+	switch {
+	case i0 == i1:
+		for _, line := range d.y[j0:j1] {
+			d.edits = append(d.edits, edit{op: '+', line: line})
+		}
+	case j0 == j1:
+		for _, line := range d.x[i0:i1] {
+			d.edits = append(d.edits, edit{op: '-', line: line})
+		}
+	default:
+		// With the common ends removed at least two edits remain, so both halves are smaller.
+		im, jm := d.middle(i0, i1, j0, j1)
+		d.diff(i0, im, j0, jm)
+		d.diff(im, i1, jm, j1)
+	}
+
+	for _, line := range d.x[i1 : i1+same] {
+		d.edits = append(d.edits, edit{op: ' ', line: line})
+	}
+}
+
+// middle returns a point on a shortest edit path from (i0, j0) to (i1, j1) which splits it into
+// two paths of at least one edit each. It searches forward from the start and backward from the
+// end, one edit at a time, until the furthest paths on a diagonal overlap.
+func (d *differ) middle(i0, i1, j0, j1 int) (int, int) {
+	n, m := i1-i0, j1-j0
+	max := (n + m + 1) / 2
+	delta := n - m
+	off := max + 1
+	// vf[off+k] is how far along x the furthest forward path on diagonal k = x-y reaches, and
+	// vb[off+k] how far back from the end the furthest backward path on diagonal k reaches.
+	vf, vb := d.vf[:2*off+1], d.vb[:2*off+1]
+	for k := range vf {
+		vf[k], vb[k] = 0, 0
+	}
+
+	for e := 0; e <= max; e++ {
+		for k := -e; k <= e; k += 2 {
+			var x int
+			if k == -e || (k != e && vf[off+k-1] < vf[off+k+1]) {
+				x = vf[off+k+1]
+			} else {
+				x = vf[off+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && d.x[i0+x] == d.y[j0+y] {
+				x, y = x+1, y+1
+			}
+			vf[off+k] = x
+			if kb := delta - k; delta%2 != 0 && kb >= -(e-1) && kb <= e-1 && x+vb[off+kb] >= n {
+				return i0 + x, j0 + y
+			}
+		}
+		for k := -e; k <= e; k += 2 {
+			var x int
+			if k == -e || (k != e && vb[off+k-1] < vb[off+k+1]) {
+				x = vb[off+k+1]
+			} else {
+				x = vb[off+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && d.x[i1-1-x] == d.y[j1-1-y] {
+				x, y = x+1, y+1
+			}
+			vb[off+k] = x
+			if kf := delta - k; delta%2 == 0 && kf >= -e && kf <= e && x+vf[off+kf] >= n {
+				return i1 - x, j1 - y
+			}
+		}
+	}
+	// The paths always meet within max edits.
+	panic("diff: no middle snake")
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	lines := func(n int) string {
+		var b strings.Builder
+		for i := 1; i <= n; i++ {
+			fmt.Fprintf(&b, "%d\n", i)
+		}
+		return b.String()
+	}
+	ten := lines(10)
+
+	tests := []struct {
+		a, b     string
+		expected string
+	}{
+		{"", "", ""},
+		{ten, ten, ""},
+		{"a\n", "", "--- f.orig\n+++ f\n@@ -1,1 +0,0 @@\n-a\n"},
+		{"", "a\n", "--- f.orig\n+++ f\n@@ -0,0 +1,1 @@\n+a\n"},
+		// Changes at the start and end of a file have context on one side only.
+		{ten, "0\n" + ten, "--- f.orig\n+++ f\n@@ -1,3 +1,4 @@\n+0\n 1\n 2\n 3\n"},
+		{ten, strings.Replace(ten, "1\n", "one\n", 1), "--- f.orig\n+++ f\n@@ -1,4 +1,4 @@\n-1\n+one\n 2\n 3\n 4\n"},
+		{ten, ten + "11\n", "--- f.orig\n+++ f\n@@ -8,3 +8,4 @@\n 8\n 9\n 10\n+11\n"},
+		{ten, strings.TrimSuffix(ten, "10\n"), "--- f.orig\n+++ f\n@@ -7,4 +7,3 @@\n 7\n 8\n 9\n-10\n"},
+		{ten, strings.TrimSuffix(ten, "\n"), "--- f.orig\n+++ f\n@@ -7,4 +7,4 @@\n 7\n 8\n 9\n-10\n+10\n\\ No newline at end of file\n"},
+		// Deletions come before insertions, and nearby changes share a hunk.
+		{ten, strings.NewReplacer("\n5\n6\n", "\nfive\nsix\n").Replace(ten),
+			"--- f.orig\n+++ f\n@@ -2,8 +2,8 @@\n 2\n 3\n 4\n-5\n-6\n+five\n+six\n 7\n 8\n 9\n"},
+		{ten, strings.NewReplacer("\n2\n", "\n", "\n8\n", "\neight\n").Replace(ten),
+			"--- f.orig\n+++ f\n@@ -1,10 +1,9 @@\n 1\n-2\n 3\n 4\n 5\n 6\n 7\n-8\n+eight\n 9\n 10\n"},
+		// Changes further apart get hunks of their own.
+		{lines(20), strings.NewReplacer("\n2\n", "\n", "\n18\n", "\n").Replace(lines(20)),
+			"--- f.orig\n+++ f\n@@ -1,5 +1,4 @@\n 1\n-2\n 3\n 4\n 5\n@@ -15,6 +14,5 @@\n 15\n 16\n 17\n-18\n 19\n 20\n"},
+	}
+
+	for i, tt := range tests {
+		got := unifiedDiff("f", []byte(tt.a), []byte(tt.b))
+		if got != tt.expected {
+			t.Errorf("test %d: unexpected diff. expected=%q, got=%q", i+1, tt.expected, got)
+		}
+	}
+}
+
+func TestUnifiedDiff_Large(t *testing.T) {
+	// Two long files with nothing in common would need a table of their lengths multiplied.
+	var a, b strings.Builder
+	for i := 0; i < 5000; i++ {
+		fmt.Fprintf(&a, "a%d\n", i)
+		fmt.Fprintf(&b, "b%d\n", i)
+	}
+	d := unifiedDiff("f", []byte(a.String()), []byte(b.String()))
+	if n := strings.Count(d, "\n-a"); n != 5000 {
+		t.Errorf("expected 5000 deletions, got %d", n)
+	}
+	if n := strings.Count(d, "\n+b"); n != 5000 {
+		t.Errorf("expected 5000 insertions, got %d", n)
+	}
+}
+
+func TestDiffLines(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	random := func() []string {
+		lines := make([]string, r.Intn(12))
+		for i := range lines {
+			lines[i] = string('a' + rune(r.Intn(3)))
+		}
+		return lines
+	}
+
+	for n := 0; n < 2000; n++ {
+		x, y := random(), random()
+		var gotX, gotY []string
+		changes := 0
+		for _, e := range diffLines(x, y) {
+			if e.i != len(gotX) || e.j != len(gotY) {
+				t.Fatalf("%q to %q: edit at %d,%d after %d,%d lines", x, y, e.i, e.j, len(gotX), len(gotY))
+			}
+			if e.op != '+' {
+				gotX = append(gotX, e.line)
+			}
+			if e.op != '-' {
+				gotY = append(gotY, e.line)
+			}
+			if e.op != ' ' {
+				changes++
+			}
+		}
+		if strings.Join(gotX, ",") != strings.Join(x, ",") || strings.Join(gotY, ",") != strings.Join(y, ",") {
+			t.Fatalf("%q to %q: edits give %q to %q", x, y, gotX, gotY)
+		}
+
+		// lcs[i][j] is the length of the longest common subsequence of x[i:] and y[j:].
+		lcs := make([][]int, len(x)+1)
+		for i := range lcs {
+			lcs[i] = make([]int, len(y)+1)
+		}
+		for i := len(x) - 1; i >= 0; i-- {
+			for j := len(y) - 1; j >= 0; j-- {
+				switch {
+				case x[i] == y[j]:
+					lcs[i][j] = lcs[i+1][j+1] + 1
+				case lcs[i+1][j] > lcs[i][j+1]:
+					lcs[i][j] = lcs[i+1][j]
+				default:
+					lcs[i][j] = lcs[i][j+1]
+				}
+			}
+		}
+		if min := len(x) + len(y) - 2*lcs[0][0]; changes != min {
+			t.Fatalf("%q to %q: %d changes, expected %d", x, y, changes, min)
+		}
+	}
+}
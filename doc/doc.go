@@ -0,0 +1,283 @@
+// Package doc extracts the documentation of Lox scripts from their /// doc comments, and renders
+// it as Markdown or HTML pages, one per script, cross-linked by name.
+package doc
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/butlermatt/glox/lexer"
+	"github.com/butlermatt/glox/parser"
+)
+
+// Package is the documentation of a set of scripts.
+type Package struct {
+	Files []*File
+}
+
+// File is the documentation of the top level declarations of one script.
+type File struct {
+	Path      string // The path of the script, relative to the documented root
+	Classes   []*Class
+	Functions []*Func
+	Vars      []*Var
+}
+
+// Class documents a class and its methods.
+type Class struct {
+	Name       string
+	Superclass string
+	Doc        string
+	Methods    []*Func
+}
+
+// Func documents a function or method.
+type Func struct {
+	Name      string
+	Params    []string // Parameter names, with the rest parameter last as "...name"
+	Generator bool
+	Doc       string
+}
+
+// Var documents a variable or constant.
+type Var struct {
+	Name     string
+	Constant bool
+	Doc      string
+}
+
+// Load documents root, which is a script or a directory searched for scripts ending in ".lox".
+func Load(root string) (*Package, error) {
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	if info.IsDir() {
+		err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err == nil && !info.IsDir() && filepath.Ext(path) == ".lox" {
+				paths = append(paths, path)
+			}
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		root = filepath.Dir(root)
+		paths = []string{filepath.Join(root, info.Name())}
+	}
+
+	pkg := &Package{}
+	for _, path := range paths {
+		src, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			rel = path
+		}
+		f, err := ParseFile(filepath.ToSlash(rel), string(src))
+		if err != nil {
+			return nil, err
+		}
+		pkg.Files = append(pkg.Files, f)
+	}
+	return pkg, nil
+}
+
+// ParseFile documents the script src, which is named path in the generated pages.
+func ParseFile(path, src string) (*File, error) {
+	p := parser.New(lexer.New(src))
+	stmts := p.Parse()
+	if errs := p.Errors(); len(errs) > 0 {
+		e := errs[0]
+		return nil, fmt.Errorf("%s: [Syntax Error line %d] Error %s: %s", path, e.Line, e.Where, e.Msg)
+	}
+
+	f := &File{Path: path}
+	for _, stmt := range stmts {
+		switch s := stmt.(type) {
+		case *parser.ClassStmt:
+			c := &Class{Name: s.Name.Lexeme, Doc: s.Doc}
+			if s.Superclass != nil {
+				c.Superclass = s.Superclass.Name.Lexeme
+			}
+			for _, m := range s.Methods {
+				c.Methods = append(c.Methods, newFunc(m))
+			}
+			f.Classes = append(f.Classes, c)
+		case *parser.FunctionStmt:
+			f.Functions = append(f.Functions, newFunc(s))
+		case *parser.VarStmt:
+			f.Vars = append(f.Vars, &Var{Name: s.Name.Lexeme, Constant: s.Constant, Doc: s.Doc})
+		}
+	}
+	return f, nil
+}
+
+func newFunc(stmt *parser.FunctionStmt) *Func {
+	fn := &Func{Name: stmt.Name.Lexeme, Generator: stmt.Generator, Doc: stmt.Doc}
+	for _, p := range stmt.Parameters {
+		fn.Params = append(fn.Params, p.Lexeme)
+	}
+	if stmt.Rest != nil {
+		fn.Params = append(fn.Params, "..."+stmt.Rest.Lexeme)
+	}
+	return fn
+}
+
+// Signature returns the declaration of fn as written in Lox, such as "fun* range(start, end)".
+// A method has no "fun" keyword.
+func (fn *Func) Signature(method bool) string {
+	var prefix string
+	switch {
+	case method && fn.Generator:
+		prefix = "*"
+	case fn.Generator:
+		prefix = "fun* "
+	case !method:
+		prefix = "fun "
+	}
+	return prefix + fn.Name + "(" + strings.Join(fn.Params, ", ") + ")"
+}
+
+// Page returns the name of the page documenting f, without its extension. Directories are
+// flattened so that every page is in the same output directory. A name already taken by the
+// index or an earlier file, as "a/b.lox" and "a_b.lox" would share, gets a numbered suffix.
+// Names differing only in case are taken to be the same, as they are on some file systems.
+func (pkg *Package) Page(f *File) string {
+	taken := map[string]bool{"index": true}
+	for _, other := range pkg.Files {
+		base := strings.Replace(strings.TrimSuffix(other.Path, ".lox"), "/", "_", -1)
+		page := base
+		for n := 2; taken[strings.ToLower(page)]; n++ {
+			page = fmt.Sprintf("%s_%d", base, n)
+		}
+		if other == f {
+			return page
+		}
+		taken[strings.ToLower(page)] = true
+	}
+	return ""
+}
+
+// target is a documented declaration which may be linked to.
+type target struct {
+	name   string // "Name" for a class, function or variable, and "Class.method" for a method
+	file   *File
+	anchor string
+	doc    string
+}
+
+// targets lists each documented declaration in file order. Names are only unique within a
+// file, so different files may declare the same one.
+func (pkg *Package) targets() []target {
+	var t []target
+	for _, f := range pkg.Files {
+		for _, c := range f.Classes {
+			t = append(t, target{c.Name, f, c.Name, c.Doc})
+			for _, m := range c.Methods {
+				name := c.Name + "." + m.Name
+				t = append(t, target{name, f, name, m.Doc})
+			}
+		}
+		for _, fn := range f.Functions {
+			t = append(t, target{fn.Name, f, fn.Name, fn.Doc})
+		}
+		for _, v := range f.Vars {
+			t = append(t, target{v.Name, f, v.Name, v.Doc})
+		}
+	}
+	return t
+}
+
+// lookup returns the declaration which a reference to name on the page for file from links to:
+// the one in from if it declares name, or else the first in the package.
+func (pkg *Package) lookup(from *File, name string) (target, bool) {
+	var found target
+	ok := false
+	for _, t := range pkg.targets() {
+		if t.name != name {
+			continue
+		}
+		if t.file == from {
+			return t, true
+		}
+		if !ok {
+			found, ok = t, true
+		}
+	}
+	return found, ok
+}
+
+// indexEntry is a declaration listed in an index, labelled with its file when another file
+// declares the same name.
+type indexEntry struct {
+	label string
+	target
+}
+
+// index returns every documented declaration sorted by name, for an index.
+func (pkg *Package) index() []indexEntry {
+	targets := pkg.targets()
+	count := make(map[string]int)
+	for _, t := range targets {
+		count[t.name]++
+	}
+
+	entries := make([]indexEntry, len(targets))
+	for i, t := range targets {
+		entries[i] = indexEntry{t.name, t}
+		if count[t.name] > 1 {
+			entries[i].label = t.name + " (" + t.file.Path + ")"
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].name < entries[j].name })
+	return entries
+}
+
+// linkPattern matches a reference to another declaration in a doc comment, written as [Name]
+// or [Class.method].
+var linkPattern = regexp.MustCompile(`\[([A-Za-z_][A-Za-z0-9_]*(?:\.[A-Za-z_][A-Za-z0-9_]*)?)\]`)
+
+// expandLinks replaces each reference in text, on the page for file from, which names a
+// documented declaration with the result of link.
+func (pkg *Package) expandLinks(from *File, text string, link func(name string, t target) string) string {
+	return linkPattern.ReplaceAllStringFunc(text, func(ref string) string {
+		name := ref[1 : len(ref)-1]
+		if t, ok := pkg.lookup(from, name); ok {
+			return link(name, t)
+		}
+		return ref
+	})
+}
+
+// Write writes the Markdown and HTML pages for every file, and an index of each, to dir.
+func (pkg *Package) Write(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	pages := map[string][]byte{
+		"index.md":   pkg.MarkdownIndex(),
+		"index.html": pkg.HTMLIndex(),
+	}
+	for _, f := range pkg.Files {
+		pages[pkg.Page(f)+".md"] = pkg.Markdown(f)
+		pages[pkg.Page(f)+".html"] = pkg.HTML(f)
+	}
+	for name, data := range pages {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,187 @@
+package doc
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testPackage(t *testing.T) *Package {
+	t.Helper()
+	base, err := ParseFile("base.lox", `
+/// The root of every [Point].
+class Shape {
+  /// Returns the area.
+  area() { return 0; }
+}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	point, err := ParseFile("shapes/point.lox", `
+/// A point, see [Shape.area] and [missing].
+class Point < Shape {
+  init(x, y) {}
+}
+
+/// Makes points.
+fun* points(n, ...rest) {}
+
+const origin = Point(0, 0);
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Package{Files: []*File{base, point}}
+}
+
+func TestMarkdown(t *testing.T) {
+	pkg := testPackage(t)
+
+	expected := `# shapes/point.lox
+
+## Classes
+
+<a id="Point"></a>
+
+### class Point < [Shape](base.md#Shape)
+
+A point, see [Shape.area](base.md#Shape.area) and [missing].
+
+<a id="Point.init"></a>
+
+#### ` + "`init(x, y)`" + `
+
+## Functions
+
+<a id="points"></a>
+
+### ` + "`fun* points(n, ...rest)`" + `
+
+Makes points.
+
+## Variables
+
+<a id="origin"></a>
+
+### ` + "`const origin`" + `
+`
+	if got := string(pkg.Markdown(pkg.Files[1])); got != expected {
+		t.Errorf("unexpected markdown.\nexpected:\n%s\ngot:\n%s", expected, got)
+	}
+
+	index := string(pkg.MarkdownIndex())
+	for _, want := range []string{
+		"- [shapes/point.lox](shapes_point.md)",
+		"- [Shape](base.md#Shape) - The root of every [Point](shapes_point.md#Point).",
+	} {
+		if !strings.Contains(index, want) {
+			t.Errorf("index does not contain %q:\n%s", want, index)
+		}
+	}
+}
+
+func TestHTML(t *testing.T) {
+	pkg := testPackage(t)
+
+	page := string(pkg.HTML(pkg.Files[0]))
+	for _, want := range []string{
+		`<h3 id="Shape">class Shape</h3>`,
+		`<p>The root of every <a href="shapes_point.html#Point">Point</a>.</p>`,
+		`<h4 id="Shape.area">area()</h4>`,
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("page does not contain %q:\n%s", want, page)
+		}
+	}
+}
+
+func TestPackage_SameNames(t *testing.T) {
+	var files []*File
+	for _, src := range []struct{ path, src string }{
+		{"a/b.lox", "/// First.\nfun helper() {}\n/// See [helper] and [Box.open].\nclass Box { open() {} }"},
+		{"a_b.lox", "/// Second.\nfun helper() {}\n/// See [helper].\nclass Box { /// Opens.\nopen() {} }"},
+		{"c.lox", "/// Uses [helper] and [Box.open].\nvar c;"},
+	} {
+		f, err := ParseFile(src.path, src.src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, f)
+	}
+	pkg := &Package{Files: files}
+
+	if a, b := pkg.Page(files[0]), pkg.Page(files[1]); a != "a_b" || b != "a_b_2" {
+		t.Errorf("expected the pages a_b and a_b_2, got %s and %s", a, b)
+	}
+
+	// Each page links to its own declarations, and other pages to the first file declaring them.
+	for _, tt := range []struct {
+		file int
+		want string
+	}{
+		{0, "See [helper](#helper) and [Box.open](#Box.open)."},
+		{1, "See [helper](#helper)."},
+		{2, "Uses [helper](a_b.md#helper) and [Box.open](a_b.md#Box.open)."},
+	} {
+		if page := string(pkg.Markdown(files[tt.file])); !strings.Contains(page, tt.want) {
+			t.Errorf("page %s does not contain %q:\n%s", files[tt.file].Path, tt.want, page)
+		}
+	}
+
+	index := string(pkg.MarkdownIndex())
+	for _, want := range []string{
+		"- [a_b.lox](a_b_2.md)",
+		"- [Box.open (a/b.lox)](a_b.md#Box.open)\n- [Box.open (a_b.lox)](a_b_2.md#Box.open) - Opens.",
+		"- [helper (a/b.lox)](a_b.md#helper) - First.\n- [helper (a_b.lox)](a_b_2.md#helper) - Second.",
+		"- [c](c.md#c) - Uses [helper](a_b.md#helper)",
+	} {
+		if !strings.Contains(index, want) {
+			t.Errorf("index does not contain %q:\n%s", want, index)
+		}
+	}
+}
+
+func TestPackage_IndexPage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "glox-doc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for name, src := range map[string]string{"index.lox": "/// From index.lox.\nfun f() {}", "Index.lox": "fun g() {}"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pkg, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(dir, "out")
+	if err := pkg.Write(out); err != nil {
+		t.Fatal(err)
+	}
+
+	index, err := ioutil.ReadFile(filepath.Join(out, "index.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"# Index", "- [Index.lox](Index_2.md)", "- [index.lox](index_3.md)", "- [f](index_3.md#f) - From index.lox."} {
+		if !strings.Contains(string(index), want) {
+			t.Errorf("index.md does not contain %q:\n%s", want, index)
+		}
+	}
+	page, err := ioutil.ReadFile(filepath.Join(out, "index_3.md"))
+	if err != nil || !strings.Contains(string(page), "From index.lox.") {
+		t.Errorf("expected index.lox to be documented in index_3.md, got %q (%v)", page, err)
+	}
+}
+
+func TestParseFile_SyntaxError(t *testing.T) {
+	if _, err := ParseFile("bad.lox", "class {"); err == nil {
+		t.Errorf("expected a syntax error")
+	}
+}
@@ -0,0 +1,156 @@
+package doc
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"html/template"
+	"strings"
+)
+
+var pageTemplate = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; max-width: 50em; margin: 2em auto; }
+code { background: #f4f4f4; padding: 0 0.2em; }
+h3, h4 { font-family: monospace; }
+</style>
+</head>
+<body>
+<p><a href="index.html">Index</a></p>
+<h1>{{.Title}}</h1>
+{{- with .Classes}}
+<h2>Classes</h2>
+{{- range .}}
+<h3 id="{{.Name}}">class {{.Name}}{{with .Superclass}} &lt; {{.}}{{end}}</h3>
+{{.Doc}}
+{{- range .Methods}}
+<h4 id="{{.Anchor}}">{{.Signature}}</h4>
+{{.Doc}}
+{{- end}}
+{{- end}}
+{{- end}}
+{{- with .Functions}}
+<h2>Functions</h2>
+{{- range .}}
+<h3 id="{{.Anchor}}">{{.Signature}}</h3>
+{{.Doc}}
+{{- end}}
+{{- end}}
+{{- with .Vars}}
+<h2>Variables</h2>
+{{- range .}}
+<h3 id="{{.Anchor}}">{{.Signature}}</h3>
+{{.Doc}}
+{{- end}}
+{{- end}}
+{{- with .Files}}
+<h2>Files</h2>
+<ul>
+{{- range .}}
+<li><a href="{{.Href}}">{{.Name}}</a></li>
+{{- end}}
+</ul>
+{{- end}}
+{{- with .Names}}
+<h2>Names</h2>
+<ul>
+{{- range .}}
+<li><a href="{{.Href}}">{{.Name}}</a>{{with .Summary}} - {{.}}{{end}}</li>
+{{- end}}
+</ul>
+{{- end}}
+</body>
+</html>
+`))
+
+type htmlPage struct {
+	Title     string
+	Classes   []htmlClass
+	Functions []htmlDecl
+	Vars      []htmlDecl
+	Files     []htmlLink
+	Names     []htmlLink
+}
+
+type htmlClass struct {
+	Name       string
+	Superclass template.HTML
+	Doc        template.HTML
+	Methods    []htmlDecl
+}
+
+type htmlDecl struct {
+	Anchor    string
+	Signature string
+	Doc       template.HTML
+}
+
+type htmlLink struct {
+	Name    string
+	Href    string
+	Summary template.HTML
+}
+
+// HTML renders the page documenting f. Links to other pages point to their HTML pages.
+func (pkg *Package) HTML(f *File) []byte {
+	link := func(name string, t target) string {
+		return fmt.Sprintf(`<a href="%s">%s</a>`, html.EscapeString(pkg.href(f, t, ".html")), name)
+	}
+	text := func(doc string) template.HTML {
+		var paras []string
+		for _, p := range strings.Split(doc, "\n\n") {
+			if p = strings.TrimSpace(p); p != "" {
+				paras = append(paras, "<p>"+pkg.expandLinks(f, html.EscapeString(p), link)+"</p>")
+			}
+		}
+		return template.HTML(strings.Join(paras, "\n"))
+	}
+
+	page := htmlPage{Title: f.Path}
+	for _, c := range f.Classes {
+		hc := htmlClass{Name: c.Name, Doc: text(c.Doc)}
+		if c.Superclass != "" {
+			hc.Superclass = template.HTML(pkg.expandLinks(f, "["+html.EscapeString(c.Superclass)+"]", link))
+		}
+		for _, m := range c.Methods {
+			hc.Methods = append(hc.Methods, htmlDecl{c.Name + "." + m.Name, m.Signature(true), text(m.Doc)})
+		}
+		page.Classes = append(page.Classes, hc)
+	}
+	for _, fn := range f.Functions {
+		page.Functions = append(page.Functions, htmlDecl{fn.Name, fn.Signature(false), text(fn.Doc)})
+	}
+	for _, v := range f.Vars {
+		page.Vars = append(page.Vars, htmlDecl{v.Name, keyword(v) + " " + v.Name, text(v.Doc)})
+	}
+	return render(page)
+}
+
+// HTMLIndex renders an index of every file and documented name.
+func (pkg *Package) HTMLIndex() []byte {
+	page := htmlPage{Title: "Index"}
+	for _, f := range pkg.Files {
+		page.Files = append(page.Files, htmlLink{Name: f.Path, Href: pkg.Page(f) + ".html"})
+	}
+	link := func(name string, t target) string {
+		return fmt.Sprintf(`<a href="%s">%s</a>`, html.EscapeString(pkg.href(nil, t, ".html")), name)
+	}
+	for _, e := range pkg.index() {
+		s := template.HTML(pkg.expandLinks(e.file, html.EscapeString(summary(e.doc)), link))
+		page.Names = append(page.Names, htmlLink{Name: e.label, Href: pkg.href(nil, e.target, ".html"), Summary: s})
+	}
+	return render(page)
+}
+
+func render(page htmlPage) []byte {
+	var b bytes.Buffer
+	if err := pageTemplate.Execute(&b, page); err != nil {
+		// The template and its data are fixed, so this is a bug rather than bad input.
+		panic(err)
+	}
+	return b.Bytes()
+}
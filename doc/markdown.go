@@ -0,0 +1,100 @@
+package doc
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Markdown renders the page documenting f. Links to other pages point to their Markdown pages.
+func (pkg *Package) Markdown(f *File) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "# %s\n\n", f.Path)
+
+	link := func(name string, t target) string {
+		return fmt.Sprintf("[%s](%s)", name, pkg.href(f, t, ".md"))
+	}
+	text := func(doc string) {
+		if doc != "" {
+			b.WriteString(pkg.expandLinks(f, doc, link) + "\n\n")
+		}
+	}
+
+	if len(f.Classes) > 0 {
+		b.WriteString("## Classes\n\n")
+	}
+	for _, c := range f.Classes {
+		fmt.Fprintf(&b, "<a id=\"%s\"></a>\n\n### class %s", c.Name, c.Name)
+		if c.Superclass != "" {
+			fmt.Fprintf(&b, " < %s", pkg.expandLinks(f, "["+c.Superclass+"]", link))
+		}
+		b.WriteString("\n\n")
+		text(c.Doc)
+		for _, m := range c.Methods {
+			fmt.Fprintf(&b, "<a id=\"%s.%s\"></a>\n\n#### `%s`\n\n", c.Name, m.Name, m.Signature(true))
+			text(m.Doc)
+		}
+	}
+
+	if len(f.Functions) > 0 {
+		b.WriteString("## Functions\n\n")
+	}
+	for _, fn := range f.Functions {
+		fmt.Fprintf(&b, "<a id=\"%s\"></a>\n\n### `%s`\n\n", fn.Name, fn.Signature(false))
+		text(fn.Doc)
+	}
+
+	if len(f.Vars) > 0 {
+		b.WriteString("## Variables\n\n")
+	}
+	for _, v := range f.Vars {
+		fmt.Fprintf(&b, "<a id=\"%s\"></a>\n\n### `%s %s`\n\n", v.Name, keyword(v), v.Name)
+		text(v.Doc)
+	}
+
+	return append(bytes.TrimRight(b.Bytes(), "\n"), '\n')
+}
+
+// MarkdownIndex renders an index of every file and documented name.
+func (pkg *Package) MarkdownIndex() []byte {
+	var b bytes.Buffer
+	b.WriteString("# Index\n\n## Files\n\n")
+	for _, f := range pkg.Files {
+		fmt.Fprintf(&b, "- [%s](%s.md)\n", f.Path, pkg.Page(f))
+	}
+
+	b.WriteString("\n## Names\n\n")
+	for _, e := range pkg.index() {
+		fmt.Fprintf(&b, "- [%s](%s)", e.label, pkg.href(nil, e.target, ".md"))
+		if e.doc != "" {
+			fmt.Fprintf(&b, " - %s", pkg.expandLinks(e.file, summary(e.doc), func(name string, t target) string {
+				return fmt.Sprintf("[%s](%s)", name, pkg.href(nil, t, ".md"))
+			}))
+		}
+		b.WriteByte('\n')
+	}
+	return b.Bytes()
+}
+
+// href returns the link from the page for file from to target t, in pages with extension ext.
+func (pkg *Package) href(from *File, t target, ext string) string {
+	if t.file == from {
+		return "#" + t.anchor
+	}
+	return pkg.Page(t.file) + ext + "#" + t.anchor
+}
+
+func keyword(v *Var) string {
+	if v.Constant {
+		return "const"
+	}
+	return "var"
+}
+
+// summary returns the first line of a doc comment.
+func summary(doc string) string {
+	if i := strings.IndexByte(doc, '\n'); i >= 0 {
+		return doc[:i]
+	}
+	return doc
+}
@@ -0,0 +1,334 @@
+// Package format implements the canonical layout of Lox source, as applied by "glox fmt".
+package format
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/butlermatt/glox/lexer"
+	"github.com/butlermatt/glox/parser"
+)
+
+// Indent is written once per level of nesting.
+const Indent = "  "
+
+// Source formats a Lox program. Statements are placed one per line, blocks are indented with
+// their opening brace at the end of the line, and tokens are separated by single spaces except
+// where the language reads better without. Comments and single blank lines are preserved.
+// The source must parse without errors.
+func Source(src []byte) ([]byte, error) {
+	p := parser.New(lexer.New(string(src)))
+	p.Parse()
+	if errs := p.Errors(); len(errs) > 0 {
+		var msgs []string
+		for _, e := range errs {
+			msgs = append(msgs, fmt.Sprintf("[Syntax Error line %d] Error %s: %s", e.Line, e.Where, e.Msg))
+		}
+		return nil, fmt.Errorf("%s", strings.Join(msgs, "\n"))
+	}
+
+	out := newPrinter(tokens(lexer.NewWithComments(string(src)))).print()
+
+	// The layout must only ever change the whitespace between tokens.
+	if !sameTokens(tokens(lexer.NewWithComments(string(src))), tokens(lexer.NewWithComments(string(out)))) {
+		return nil, fmt.Errorf("internal error: formatting changed the program")
+	}
+	return out, nil
+}
+
+// tokens returns every token scanned by l, without the final EOF.
+func tokens(l *lexer.Lexer) []*lexer.Token {
+	l.ScanTokens()
+	var toks []*lexer.Token
+	for tok := l.NextToken(); tok != nil && tok.Type != lexer.EOF; tok = l.NextToken() {
+		toks = append(toks, tok)
+	}
+	return toks
+}
+
+func sameTokens(a, b []*lexer.Token) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Type != b[i].Type || strings.TrimSpace(a[i].Lexeme) != strings.TrimSpace(b[i].Lexeme) {
+			return false
+		}
+	}
+	return true
+}
+
+// block is an open brace, tracking the case clauses of a match statement.
+type block struct {
+	match  bool // the body of a match statement
+	header bool // between "case" or "default" and its colon
+	inCase bool // within the statements of a case
+	cont   int  // the continuation indent of the statement holding the brace
+}
+
+type printer struct {
+	toks []*lexer.Token
+	out  bytes.Buffer
+
+	indent  int
+	parens  int     // open parentheses and brackets
+	blocks  []block // open braces
+	inMatch bool    // a match keyword has been seen but not its opening brace
+
+	// A statement broken across lines by a comment, like the body of "if (a) // c", is indented
+	// once for each token it was broken after, until it ends.
+	cont      int
+	contAfter *lexer.Token
+
+	prev      *lexer.Token // the last token written, excluding comments
+	prevUnary bool         // prev was a unary operator or the star of a generator method
+	lastLine  int          // the source line on which the last token or comment ended
+	newline   bool         // the next token starts a new line
+	spaceNext bool         // the next token follows a block comment on the same line
+}
+
+func newPrinter(toks []*lexer.Token) *printer {
+	return &printer{toks: toks}
+}
+
+func (p *printer) print() []byte {
+	for i := 0; i < len(p.toks); i++ {
+		tok := p.toks[i]
+
+		switch tok.Type {
+		case lexer.Comment, lexer.DocComment:
+			p.comment(tok, p.next(i))
+			continue
+		case lexer.LBrace:
+			if i+1 < len(p.toks) && p.toks[i+1].Type == lexer.RBrace {
+				// Write empty braces together, skipping the closing one.
+				p.write(tok, "{}")
+				p.lastLine = p.toks[i+1].Line
+				p.prev = p.toks[i+1]
+				p.inMatch = false
+				p.newline = true
+				i++
+				continue
+			}
+			if p.newline {
+				p.cont = 0 // a brace on its own line lines up with its statement.
+			}
+			p.write(tok, "{")
+			p.blocks = append(p.blocks, block{match: p.inMatch, cont: p.cont})
+			p.inMatch = false
+			p.indent += p.cont + 1
+			p.cont = 0
+			p.newline = true
+			continue
+		case lexer.RBrace:
+			cont := 0
+			if b := p.top(); b != nil {
+				if b.inCase {
+					p.indent--
+				}
+				cont = b.cont
+				p.blocks = p.blocks[:len(p.blocks)-1]
+			}
+			p.indent--
+			p.newline = true
+			p.write(tok, "}")
+			p.indent -= cont
+			p.cont = 0
+			if next := p.next(i); next != nil && next.Type == lexer.Else {
+				p.cont = cont
+			}
+			p.newline = true
+			continue
+		case lexer.Case, lexer.Default:
+			if b := p.top(); b != nil && b.match && p.parens == 0 {
+				if b.inCase {
+					p.indent--
+					b.inCase = false
+				}
+				b.header = true
+				p.newline = true
+			}
+		case lexer.Match:
+			p.inMatch = true
+		case lexer.LParen, lexer.LBracket:
+			p.write(tok, tok.Lexeme)
+			p.parens++
+			continue
+		case lexer.RParen, lexer.RBracket:
+			if p.parens > 0 {
+				p.parens--
+			}
+		}
+
+		p.write(tok, tok.Lexeme)
+
+		switch tok.Type {
+		case lexer.Semicolon:
+			if p.parens == 0 {
+				p.newline = true
+				p.cont = 0
+			}
+		case lexer.Colon:
+			if b := p.top(); b != nil && b.header && p.parens == 0 {
+				b.header = false
+				b.inCase = true
+				p.indent++
+				p.newline = true
+			}
+		}
+	}
+
+	if p.out.Len() > 0 {
+		p.out.WriteByte('\n')
+	}
+	return p.out.Bytes()
+}
+
+func (p *printer) top() *block {
+	if len(p.blocks) == 0 {
+		return nil
+	}
+	return &p.blocks[len(p.blocks)-1]
+}
+
+// write writes text for tok, preceded by a line break and indentation or a separating space.
+func (p *printer) write(tok *lexer.Token, text string) {
+	if tok.Type == lexer.Else && p.prev != nil && p.prev.Type == lexer.RBrace {
+		p.newline = false // "} else" stays on one line.
+	}
+
+	if p.newline {
+		p.startLine(tok)
+	} else if p.spaceNext || p.needsSpace(tok) {
+		p.out.WriteByte(' ')
+	}
+	p.spaceNext = false
+
+	p.prevUnary = p.isUnary(tok)
+	p.prev = tok
+	p.out.WriteString(text)
+	p.lastLine = tok.Line + strings.Count(tok.Lexeme, "\n")
+}
+
+// comment writes a comment. A line comment always ends its line, while a block comment stays
+// on the line with the tokens around it, if any.
+func (p *printer) comment(tok, next *lexer.Token) {
+	text := strings.TrimRight(tok.Lexeme, " \t\r")
+	if p.out.Len() > 0 && tok.Line == p.lastLine {
+		if !strings.HasPrefix(text, "/*") || p.needsSpace(tok) {
+			p.out.WriteByte(' ')
+		}
+	} else {
+		p.breakStatement()
+		p.startLine(tok)
+	}
+	p.out.WriteString(text)
+	p.lastLine = tok.Line + strings.Count(text, "\n")
+
+	if !strings.HasPrefix(text, "/*") || next == nil || next.Line > p.lastLine {
+		p.newline = true
+		p.breakStatement()
+	} else {
+		p.spaceNext = !p.newline
+	}
+}
+
+// breakStatement indents the lines which follow if a comment has broken a statement after the
+// last token written. Line breaks within parentheses and brackets are indented already.
+func (p *printer) breakStatement() {
+	if p.prev == nil || p.prev == p.contAfter || p.parens > 0 {
+		return
+	}
+	switch p.prev.Type {
+	case lexer.Semicolon, lexer.LBrace, lexer.RBrace, lexer.Colon:
+		return
+	}
+	p.cont++
+	p.contAfter = p.prev
+}
+
+// next returns the first token after index i which is not a comment.
+func (p *printer) next(i int) *lexer.Token {
+	for _, tok := range p.toks[i+1:] {
+		if tok.Type != lexer.Comment && tok.Type != lexer.DocComment {
+			return tok
+		}
+	}
+	return nil
+}
+
+// startLine ends the current line, keeping a single blank line where the source had at least
+// one, then indents for tok.
+func (p *printer) startLine(tok *lexer.Token) {
+	if p.out.Len() > 0 {
+		p.out.WriteByte('\n')
+		opening := p.prev != nil && p.prev.Type == lexer.LBrace && p.lastLine == p.prev.Line
+		if tok.Line > p.lastLine+1 && !opening && tok.Type != lexer.RBrace {
+			p.out.WriteByte('\n')
+		}
+	}
+
+	depth := p.indent + p.cont
+	if p.parens > 0 {
+		depth++
+	}
+	if depth > 0 {
+		p.out.WriteString(strings.Repeat(Indent, depth))
+	}
+	p.newline = false
+}
+
+func (p *printer) needsSpace(tok *lexer.Token) bool {
+	if p.prev == nil || p.prevUnary {
+		return false
+	}
+
+	switch p.prev.Type {
+	case lexer.LParen, lexer.LBracket, lexer.Dot, lexer.Ellipsis:
+		return false
+	}
+
+	switch tok.Type {
+	case lexer.RParen, lexer.RBracket, lexer.Comma, lexer.Semicolon, lexer.Dot, lexer.Colon:
+		return false
+	case lexer.LParen, lexer.LBracket:
+		return !endsOperand(p.prev)
+	case lexer.Star:
+		return p.prev.Type != lexer.Fun
+	}
+	return true
+}
+
+// isUnary reports whether tok is an operator which binds to the token after it, without a
+// separating space.
+func (p *printer) isUnary(tok *lexer.Token) bool {
+	switch tok.Type {
+	case lexer.Bang:
+		return true
+	case lexer.Minus:
+		return p.prev == nil || !endsOperand(p.prev)
+	case lexer.Star:
+		// The star of a generator method, as in "*each()". A generator function is "fun* name()".
+		return p.prev.Type != lexer.Fun && p.atIndent()
+	}
+	return false
+}
+
+// atIndent reports whether nothing but indentation has been written on the current line.
+func (p *printer) atIndent() bool {
+	b := p.out.Bytes()
+	line := b[bytes.LastIndexByte(b, '\n')+1:]
+	return len(bytes.TrimLeft(line, " ")) == 0
+}
+
+// endsOperand reports whether tok may be the last token of an operand, so that a following
+// parenthesis or bracket is a call or index rather than a grouping or array.
+func endsOperand(tok *lexer.Token) bool {
+	switch tok.Type {
+	case lexer.Ident, lexer.Number, lexer.String, lexer.UTString, lexer.True, lexer.False,
+		lexer.Null, lexer.This, lexer.RParen, lexer.RBracket:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,50 @@
+package format
+
+import "testing"
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"var   a=1;print a  ;", "var a = 1;\nprint a;\n"},
+		{"fun f( x,y ){return x*-y;}", "fun f(x, y) {\n  return x * -y;\n}\n"},
+		{"var a = 1; // trailing\n\n\n// own line\nprint a;", "var a = 1; // trailing\n\n// own line\nprint a;\n"},
+		{"class A < B{*gen(){yield 1;} init(){}}", "class A < B {\n  *gen() {\n    yield 1;\n  }\n  init() {}\n}\n"},
+		{"fun* g(){}", "fun* g() {}\n"},
+		{"if(a){print a;}else{print !a;}", "if (a) {\n  print a;\n} else {\n  print !a;\n}\n"},
+		{"for(;;)break;", "for (;;) break;\n"},
+		{"outer:for(var x in [1,2]){continue outer;}", "outer: for (var x in [1, 2]) {\n  continue outer;\n}\n"},
+		{"match(a){case 1,[b,...c]:print b; default:break;}", "match (a) {\n  case 1, [b, ...c]:\n    print b;\n  default:\n    break;\n}\n"},
+		{"f(1,...l,y:l[0]);", "f(1, ...l, y: l[0]);\n"},
+		{"f([1]);print [[1],[2]];print a[[0][0]];", "f([1]);\nprint [[1], [2]];\nprint a[[0][0]];\n"},
+		{"print f((1+2)*3,((x)));", "print f((1 + 2) * 3, ((x)));\n"},
+		{"/* a\n  /* b */ */\nf(/* x */ 1);  /* y */\n/// doc\nvar b;", "/* a\n  /* b */ */\nf(/* x */ 1); /* y */\n/// doc\nvar b;\n"},
+		{"if (a) // c\nreturn x;", "if (a) // c\n  return x;\n"},
+		{"fun f(){while(a)\n// c\nif(b) // d\nreturn; return 1;}", "fun f() {\n  while (a)\n    // c\n    if (b) // d\n      return;\n  return 1;\n}\n"},
+		{"if (a) // c\n{print a;}else /* d */\nprint b;", "if (a) // c\n{\n  print a;\n} else /* d */\n  print b;\n"},
+		{"var v = // c\n1;", "var v = // c\n  1;\n"},
+	}
+
+	for i, tt := range tests {
+		out, err := Source([]byte(tt.input))
+		if err != nil {
+			t.Errorf("test %d: unexpected error: %v", i+1, err)
+			continue
+		}
+		if string(out) != tt.expected {
+			t.Errorf("test %d: unexpected output. expected=%q, got=%q", i+1, tt.expected, out)
+		}
+
+		again, err := Source(out)
+		if err != nil || string(again) != string(out) {
+			t.Errorf("test %d: formatting is not idempotent. expected=%q, got=%q (%v)", i+1, out, again, err)
+		}
+	}
+}
+
+func TestSource_SyntaxError(t *testing.T) {
+	if _, err := Source([]byte("var = ;")); err == nil {
+		t.Errorf("expected a syntax error")
+	}
+}
@@ -0,0 +1,25 @@
+package interpreter
+
+// builtinFreeze makes an instance or array read-only and returns it. Freezing is shallow: the
+// instances and arrays it holds stay writable unless frozen themselves.
+func builtinFreeze(interp *Interpreter, args []interface{}) (interface{}, error) {
+	switch v := args[0].(type) {
+	case *LoxInstance:
+		v.frozen = true
+	case *LoxArray:
+		v.frozen = true
+	default:
+		return nil, newError(nil, "freeze() expects an instance or array.")
+	}
+	return args[0], nil
+}
+
+func builtinIsFrozen(interp *Interpreter, args []interface{}) (interface{}, error) {
+	switch v := args[0].(type) {
+	case *LoxInstance:
+		return v.frozen, nil
+	case *LoxArray:
+		return v.frozen, nil
+	}
+	return false, nil
+}
@@ -1,21 +1,39 @@
 package interpreter
 
-import "github.com/butlermatt/glox/parser"
+import (
+	"fmt"
+
+	"github.com/butlermatt/glox/lexer"
+	"github.com/butlermatt/glox/parser"
+)
 
 type CallFn func(interpreter *Interpreter, args []interface{}) (interface{}, error)
 
 type Callable interface {
-	// Arity is the number of expected arguments
+	// Arity is the number of required arguments
 	Arity() int
+	// MaxArity is the maximum number of arguments accepted, or -1 if there is no limit.
+	MaxArity() int
 	Call(interpreter *Interpreter, args []interface{}) (interface{}, error)
 }
 
+// unsetArg fills the positional slots of parameters which were skipped by named arguments.
+var unsetArg = &struct{}{}
+
 type BuiltIn struct {
-	arity  int
-	callFn CallFn
+	arity    int
+	variadic bool
+	callFn   CallFn
 }
 
-func (b *BuiltIn) Arity() int { return b.arity }
+func (b *BuiltIn) Arity() int     { return b.arity }
+func (b *BuiltIn) String() string { return "<native fn>" }
+func (b *BuiltIn) MaxArity() int {
+	if b.variadic {
+		return -1
+	}
+	return b.arity
+}
 func (b *BuiltIn) Call(interp *Interpreter, args []interface{}) (interface{}, error) {
 	return b.callFn(interp, args)
 }
@@ -26,19 +44,40 @@ type Function struct {
 	isInitializer bool
 }
 
-func (f *Function) Arity() int     { return len(f.declaration.Parameters) }
+func (f *Function) Arity() int {
+	for i, def := range f.declaration.Defaults {
+		if def != nil {
+			return i
+		}
+	}
+	return len(f.declaration.Parameters)
+}
+func (f *Function) MaxArity() int {
+	if f.declaration.Rest != nil {
+		return -1
+	}
+	return len(f.declaration.Parameters)
+}
 func (f *Function) String() string { return "<fn " + f.declaration.Name.Lexeme + ">" }
 func (f *Function) Call(interp *Interpreter, args []interface{}) (interface{}, error) {
-	if len(args) != f.Arity() {
-		return nil, newError(f.declaration.Name, "Incorrect number of arguments passed.")
+	env, err := f.bindArgs(interp, args)
+	if err != nil {
+		return nil, err
 	}
 
-	env := NewEnclosedEnvironment(f.closure)
-	for i, p := range f.declaration.Parameters {
-		env.Define(p, args[i])
+	if f.declaration.Generator {
+		return newGenerator(interp, f, env), nil
 	}
 
-	err := interp.executeBlock(f.declaration.Body, env)
+	err = interp.enterCall()
+	if err == nil {
+		if interp.hook != nil {
+			interp.pushFrame(f.declaration.Name.Lexeme, env)
+			defer interp.popFrame()
+		}
+		err = interp.executeBlock(f.declaration.Body, env)
+	}
+	interp.exitCall()
 	if err != nil {
 		if e, ok := err.(*ReturnError); ok {
 			return e.Value, nil
@@ -53,6 +92,76 @@ func (f *Function) Call(interp *Interpreter, args []interface{}) (interface{}, e
 	return nil, nil
 }
 
+// bindArgs creates the environment for a call to f, with the parameters bound to args.
+func (f *Function) bindArgs(interp *Interpreter, args []interface{}) (*Environment, error) {
+	if err := checkArity(f, f.declaration.Name, len(args)); err != nil {
+		return nil, err
+	}
+	if err := interp.alloc(sizeEnv + sizeField*len(args)); err != nil {
+		return nil, err
+	}
+
+	env := NewEnclosedEnvironment(f.closure)
+	for i, p := range f.declaration.Parameters {
+		if i < len(args) && args[i] != unsetArg {
+			env.Define(p, args[i])
+			continue
+		}
+
+		def := f.declaration.Defaults[i]
+		if def == nil {
+			return nil, newError(nil, "Missing argument for parameter '"+p.Lexeme+"'.")
+		}
+		// Defaults are evaluated at call time and may refer to earlier parameters.
+		val, err := interp.evaluateIn(def, env)
+		if err != nil {
+			return nil, err
+		}
+		env.Define(p, val)
+	}
+
+	if f.declaration.Rest != nil {
+		rest := []interface{}{}
+		if len(args) > len(f.declaration.Parameters) {
+			rest = append(rest, args[len(f.declaration.Parameters):]...)
+		}
+		if err := interp.alloc(sizeArray + sizeValue*len(rest)); err != nil {
+			return nil, err
+		}
+		env.Define(f.declaration.Rest, NewArray(rest))
+	}
+
+	return env, nil
+}
+
+// bindNamed places the named arguments into the positional slots of the matching parameters.
+// Any parameters skipped over are filled with unsetArg so their defaults are used.
+func (f *Function) bindNamed(args []interface{}, names []*lexer.Token, values []interface{}) ([]interface{}, error) {
+	params := f.declaration.Parameters
+	for i, name := range names {
+		ind := -1
+		for j, p := range params {
+			if p.Lexeme == name.Lexeme {
+				ind = j
+				break
+			}
+		}
+		if ind == -1 {
+			return nil, newError(name, "Unknown parameter '"+name.Lexeme+"'.")
+		}
+
+		for len(args) <= ind {
+			args = append(args, unsetArg)
+		}
+		if args[ind] != unsetArg {
+			return nil, newError(name, "Multiple values for parameter '"+name.Lexeme+"'.")
+		}
+		args[ind] = values[i]
+	}
+
+	return args, nil
+}
+
 func (f *Function) Bind(instance *LoxInstance) *Function {
 	env := NewEnclosedEnvironment(f.closure)
 	env.m["this"] = instance
@@ -62,3 +171,17 @@ func (f *Function) Bind(instance *LoxInstance) *Function {
 func NewFunction(declaration *parser.FunctionStmt, environment *Environment, isInit bool) *Function {
 	return &Function{declaration: declaration, closure: environment, isInitializer: isInit}
 }
+
+// checkArity returns an error reported at token if count arguments are not accepted by fn.
+func checkArity(fn Callable, token *lexer.Token, count int) error {
+	min, max := fn.Arity(), fn.MaxArity()
+	switch {
+	case min == max && count != min:
+		return newError(token, fmt.Sprintf("Expected %d arguments but got %d.", min, count))
+	case count < min:
+		return newError(token, fmt.Sprintf("Expected at least %d arguments but got %d.", min, count))
+	case max >= 0 && count > max:
+		return newError(token, fmt.Sprintf("Expected at most %d arguments but got %d.", max, count))
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package interpreter
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/butlermatt/glox/lexer"
+	"github.com/butlermatt/glox/parser"
+)
+
+func TestParameters(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"defaults", `fun f(a, b = 2) { print a + b; } f(1); f(1, 5);`, "3\n6\n"},
+		{"defaults read earlier parameters", `fun f(a, b = a * 2, c = a + b) { print c; } f(1); f(1, 1);`, "3\n2\n"},
+		{
+			"defaults are evaluated at call time",
+			`var n = 0; fun next() { n = n + 1; return n; } fun f(a = next()) { print a; } f(); f(); f(10); print n;`,
+			"1\n2\n10\n2\n",
+		},
+		{"defaults make fresh values", `fun f(a = []) { a = [...a, 1]; print a; } f(); f();`, "[1]\n[1]\n"},
+		{"rest", `fun f(a, ...rest) { print a; print rest; } f(1); f(1, 2, 3);`, "1\n[]\n1\n[2 3]\n"},
+		{"rest after defaults", `fun f(a = 1, ...rest) { print a; print rest; } f(); f(5, 6);`, "1\n[]\n5\n[6]\n"},
+		{"spread call", `fun f(a, b, c) { print a + b + c; } var xs = [1, 2]; f(...xs, 3); f(0, ...xs); f(...[1, 2, 3]);`, "6\n3\n6\n"},
+		{"spread into rest", `fun f(...rest) { print rest; } f(...[], ...[1], 2, ...[3, 4]);`, "[1 2 3 4]\n"},
+		{"spread array literal", `var xs = [2, 3]; print [1, ...xs, 4, ...[]]; print [...xs];`, "[1 2 3 4]\n[2 3]\n"},
+		{"named", `fun f(a, b = 2, c = 3) { print a; print b; print c; } f(1, c: 30); f(b: 20, a: 10);`, "1\n2\n30\n10\n20\n3\n"},
+		{
+			"named init",
+			`class P { init(x = 0, y = 0) { this.x = x; this.y = y; } } var p = P(y: 5); print p.x; print p.y; p = P(1, y: 2); print p.x + p.y;`,
+			"0\n5\n3\n",
+		},
+		{"named method", `class A { m(a, b = "b") { print a + b; } } A().m(b: "!", a: "hi");`, "hi!\n"},
+		{"arity", `fun f(a, b = 1, ...c) {} fun g(a, b = 1) {} print arity(f); print arity(g);`, "1\n1\n"},
+	}
+
+	for _, tt := range tests {
+		out, err := run(t, tt.input)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+		}
+		if out != tt.expected {
+			t.Errorf("%s: expected output %q, got %q", tt.name, tt.expected, out)
+		}
+	}
+}
+
+func TestParameters_Errors(t *testing.T) {
+	tests := []struct {
+		input   string
+		message string
+	}{
+		{`fun f(a, b) {} f(1);`, "Expected 2 arguments but got 1."},
+		{`fun f(a, b = 1) {} f();`, "Expected at least 1 arguments but got 0."},
+		{`fun f(a, ...rest) {} f();`, "Expected at least 1 arguments but got 0."},
+		{`fun f(a, b = 1) {} f(1, 2, 3);`, "Expected at most 2 arguments but got 3."},
+		{`fun f(a = 1) {} f(...[1, 2]);`, "Expected at most 1 arguments but got 2."},
+		{`class P { init(x = 0) {} } P(1, 2);`, "Expected at most 1 arguments but got 2."},
+		{`fun f(a) {} f(b: 1);`, "Unknown parameter 'b'."},
+		{`fun f(a, ...rest) {} f(1, rest: 2);`, "Unknown parameter 'rest'."},
+		{`class P { init(x) {} } P(y: 1);`, "Unknown parameter 'y'."},
+		{`fun f(a, b = 1) {} f(1, a: 2);`, "Multiple values for parameter 'a'."},
+		{`fun f(a, b) {} f(b: 1);`, "Missing argument for parameter 'a'."},
+		{`fun f(a) {} f(...1);`, "Operand must be an array."},
+		{`print [..."ab"];`, "Operand must be an array."},
+	}
+
+	for _, tt := range tests {
+		_, err := run(t, tt.input)
+		if _, ok := err.(*RuntimeError); !ok || !strings.Contains(err.Error(), tt.message) {
+			t.Errorf("%s: expected a runtime error containing %q, got %v", tt.input, tt.message, err)
+		}
+	}
+
+	for _, tt := range []struct {
+		input   string
+		message string
+	}{
+		{`f(a: 1, 2);`, "Positional argument cannot follow named arguments."},
+		{`fun f(a = 1, b) {}`, "Parameter without a default cannot follow parameters with defaults."},
+		{`fun f(...rest, a) {}`, "Rest parameter must be the last parameter."},
+	} {
+		p := parser.New(lexer.New(tt.input))
+		p.Parse()
+		if errs := p.Errors(); len(errs) == 0 || errs[0].Msg != tt.message {
+			t.Errorf("%s: expected the syntax error %q, got %+v", tt.input, tt.message, errs)
+		}
+	}
+
+	for _, tt := range []string{`fun f(a, a = 1) {}`, `fun f(a, ...a) {}`} {
+		if err := compileError(t, tt); err == nil || !strings.Contains(err.Error(), "Variable with this name already declared in this scope.") {
+			t.Errorf("%s: expected an error declaring a parameter twice, got %v", tt, err)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package interpreter
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Capability names a set of built-ins which an embedder may choose to give a script. The core
+// built-ins (reflection, freeze and channels) are always available; anything which reaches
+// outside the interpreter belongs to a capability, and is not even defined unless granted.
+type Capability string
+
+const (
+	CapIO       Capability = "io"        // Standard input and output
+	CapFS       Capability = "fs"        // Reading and writing files
+	CapTime     Capability = "time"      // Clocks and sleeping
+	CapOS       Capability = "os"        // Environment variables and running commands
+	CapNetLocal Capability = "net-local" // HTTP requests to the loopback interface
+)
+
+// AllCapabilities lists every capability, for fully trusted scripts.
+var AllCapabilities = []Capability{CapIO, CapFS, CapTime, CapOS, CapNetLocal}
+
+var capabilitySets = map[Capability]func(env *Environment){
+	CapIO:       defineIO,
+	CapFS:       defineFS,
+	CapTime:     defineTime,
+	CapOS:       defineOS,
+	CapNetLocal: defineNetLocal,
+}
+
+// ParseCapabilities parses a comma separated list of capability names. "all" grants every
+// capability and "none" (or an empty list) grants none.
+func ParseCapabilities(list string) ([]Capability, error) {
+	var caps []Capability
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		switch name {
+		case "", "none":
+			continue
+		case "all":
+			return AllCapabilities, nil
+		}
+
+		c := Capability(name)
+		if _, ok := capabilitySets[c]; !ok {
+			return nil, fmt.Errorf("unknown capability %q", name)
+		}
+		caps = append(caps, c)
+	}
+	return caps, nil
+}
+
+// newGlobals returns a global environment with the core built-ins plus those of caps.
+func newGlobals(caps ...Capability) *Environment {
+	env := NewEnvironment()
+	defineReflection(env)
+	env.builtin("freeze", &BuiltIn{arity: 1, callFn: builtinFreeze})
+	env.builtin("isFrozen", &BuiltIn{arity: 1, callFn: builtinIsFrozen})
+	env.builtin("exit", &BuiltIn{arity: 1, callFn: builtinExit})
+	env.m["args"] = NewArray(nil)
+	defineConcurrency(env)
+
+	for _, c := range caps {
+		if define, ok := capabilitySets[c]; ok {
+			define(env)
+		}
+	}
+	return env
+}
+
+// GlobalNames returns the sorted names of the globals defined before a script runs with the
+// granted capabilities.
+func GlobalNames(caps ...Capability) []string {
+	var names []string
+	for name := range newGlobals(caps...).m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func defineTime(env *Environment) {
+	env.builtin("clock", &BuiltIn{
+		arity: 0,
+		callFn: func(interp *Interpreter, args []interface{}) (interface{}, error) {
+			return float64(time.Now().Unix()), nil
+		}},
+	)
+	env.builtin("sleep", &BuiltIn{arity: 1, callFn: builtinSleep})
+}
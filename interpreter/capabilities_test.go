@@ -0,0 +1,138 @@
+package interpreter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestParseCapabilities(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []Capability
+		err      bool
+	}{
+		{"", nil, false},
+		{"none", nil, false},
+		{"io, time", []Capability{CapIO, CapTime}, false},
+		{"all", AllCapabilities, false},
+		{"io,bogus", nil, true},
+	}
+
+	for i, tt := range tests {
+		caps, err := ParseCapabilities(tt.input)
+		if (err != nil) != tt.err {
+			t.Errorf("test %d: unexpected error result. expected error=%v, got=%v", i+1, tt.err, err)
+			continue
+		}
+		if len(caps) != len(tt.expected) {
+			t.Errorf("test %d: unexpected capabilities. expected=%v, got=%v", i+1, tt.expected, caps)
+			continue
+		}
+		for j := range caps {
+			if caps[j] != tt.expected[j] {
+				t.Errorf("test %d: unexpected capability %d. expected=%q, got=%q", i+1, j, tt.expected[j], caps[j])
+			}
+		}
+	}
+}
+
+func TestNewInterpreter_Capabilities(t *testing.T) {
+	prog := compile(t, ``)
+
+	sandboxed := prog.NewInterpreter()
+	for _, name := range []string{"exec", "getenv", "clock", "httpGet", "write", "readFile"} {
+		if _, ok := sandboxed.Global(name); ok {
+			t.Errorf("sandboxed interpreter should not define %q", name)
+		}
+	}
+	if _, ok := sandboxed.Global("type"); !ok {
+		t.Errorf("core built-in %q should always be defined", "type")
+	}
+
+	withOS := prog.NewInterpreter(CapOS)
+	if _, ok := withOS.Global("exec"); !ok {
+		t.Errorf("interpreter granted %q should define %q", CapOS, "exec")
+	}
+	if _, ok := withOS.Global("clock"); ok {
+		t.Errorf("interpreter not granted %q should not define %q", CapTime, "clock")
+	}
+}
+
+func TestFS_RoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "glox")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := ioutil.WriteFile(dir+"/lines.txt", []byte("one\r\ntwo\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	prog := compile(t, fmt.Sprintf(`
+var lines = readLines(pathJoin("%[1]s", "lines.txt"));
+var dir = pathJoin("%[1]s", "sub");
+mkdir(dir);
+var file = pathJoin(dir, "notes.txt");
+writeFile(file, "one");
+appendFile(file, "two");
+var text = readFile(file);
+var names = listDir(dir);
+remove(file);
+var gone = !exists(file);
+`, dir))
+
+	interp := prog.NewInterpreter(CapFS)
+	if err := interp.Interpret(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	lines, _ := interp.Global("lines")
+	if got := fmt.Sprint(lines); got != "[one two]" {
+		t.Errorf("unexpected lines. expected=%q, got=%q", "[one two]", got)
+	}
+	if text, _ := interp.Global("text"); text != "onetwo" {
+		t.Errorf("unexpected text. expected=%q, got=%q", "onetwo", text)
+	}
+	names, _ := interp.Global("names")
+	if got := fmt.Sprint(names); got != "[notes.txt]" {
+		t.Errorf("unexpected names. expected=%q, got=%q", "[notes.txt]", got)
+	}
+	if gone, _ := interp.Global("gone"); gone != true {
+		t.Errorf("expected file to be removed")
+	}
+
+	long := strings.Repeat("x", 100000)
+	if err := ioutil.WriteFile(dir+"/long.txt", []byte("short\n"+long+"\n\nend"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	prog = compile(t, `var lines = readLines("`+dir+`/long.txt");`)
+	interp = prog.NewInterpreter(CapFS)
+	if err := interp.Interpret(context.Background()); err != nil {
+		t.Fatalf("unexpected error reading long lines: %v", err)
+	}
+	lines, _ = interp.Global("lines")
+	if arr, ok := lines.(*LoxArray); !ok || len(arr.Elements) != 4 || arr.Elements[1] != long || arr.Elements[2] != "" || arr.Elements[3] != "end" {
+		t.Errorf("unexpected long lines %.40v", lines)
+	}
+
+	// The memory limit stops a read before the file is held in memory.
+	for _, fn := range []string{"readFile", "readLines"} {
+		interp = compile(t, fn+`("`+dir+`/long.txt");`).NewInterpreter(CapFS)
+		interp.SetLimits(Limits{MaxMemory: 50000})
+		if err := interp.Interpret(context.Background()); !errors.Is(err, ErrMemoryLimit) {
+			t.Errorf("%s: expected a memory limit error, got %v", fn, err)
+		}
+	}
+
+	prog = compile(t, `readFile("`+dir+`/missing.txt");`)
+	err = prog.NewInterpreter(CapFS).Interpret(context.Background())
+	if err == nil {
+		t.Fatalf("expected error reading a missing file")
+	}
+}
@@ -0,0 +1,98 @@
+package interpreter
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestConst_Resolver(t *testing.T) {
+	tests := []string{
+		`const a = 1; a = 2;`,
+		`const a = 1; fun f() { a = 2; }`,
+		`{ const a = 1; a = a + 1; }`,
+		`fun f() { const a = 1; fun g() { a = 2; } }`,
+		`const a = 1; for (var x in [1]) a = x;`,
+	}
+
+	for _, tt := range tests {
+		if err := compileError(t, tt); err == nil || !strings.Contains(err.Error(), "Cannot assign to constant 'a'.") {
+			t.Errorf("%s: expected the assignment to be rejected, got %v", tt, err)
+		}
+	}
+
+	for _, tt := range []string{
+		`const a = 1; var a = 2; a = 3;`,
+		`const a = 1; const a = 2;`,
+		`const a = 1; fun a() {}`,
+		`const a = 1; class a {}`,
+		`const a = 1; fun f() {} var a = 2;`,
+	} {
+		if err := compileError(t, tt); err == nil || !strings.Contains(err.Error(), "Cannot redeclare constant 'a'.") {
+			t.Errorf("%s: expected the redeclaration to be rejected, got %v", tt, err)
+		}
+	}
+
+	for _, tt := range []string{
+		`const a = 1; { var a = 2; a = 3; }`,
+		`const a = 1; fun f(a) { a = 2; }`,
+		`const a = 1; fun f() { var a = 2; fun a() {} }`,
+		`var a = 1; var a = 2; a = 3;`,
+	} {
+		if err := compileError(t, tt); err != nil {
+			t.Errorf("%s: unexpected error: %v", tt, err)
+		}
+	}
+}
+
+func TestConst_Runtime(t *testing.T) {
+	// f is resolved before the constant is declared, so only the interpreter sees the assignment.
+	_, err := run(t, `fun f() { a = 2; } const a = 1; f();`)
+	if _, ok := err.(*RuntimeError); !ok || !strings.Contains(err.Error(), "Cannot assign to constant 'a'.") {
+		t.Errorf("expected a runtime error assigning to the constant, got %v", err)
+	}
+}
+
+func TestFreeze(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`class P {} var p = P(); p.x = 1; print isFrozen(p); print freeze(p).x; print isFrozen(p);`, "false\n1\ntrue\n"},
+		{`var a = [1, 2]; freeze(a); print isFrozen(a); print a[0] + a[1]; print isFrozen(1);`, "true\n3\nfalse\n"},
+		// Freezing is shallow: the values a frozen instance or array holds can still change.
+		{`class P {} var p = P(); p.items = [1]; p.child = P(); freeze(p); var items = p.items; items[0] = 2; p.child.x = 3; print p.items; print p.child.x;`, "[2]\n3\n"},
+		{`var inner = [1]; var outer = freeze([inner]); inner[0] = 2; print outer; print isFrozen(inner);`, "[[2]]\nfalse\n"},
+		// Methods may read a frozen instance, and a copy made by spreading is not frozen.
+		{`class P { init() { this.x = 1; } get() { return this.x; } } var p = freeze(P()); print p.get();`, "1\n"},
+		{`var a = freeze([1]); var b = [...a]; b[0] = 2; print a; print b;`, "[1]\n[2]\n"},
+	}
+
+	for _, tt := range tests {
+		out, err := run(t, tt.input)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.input, err)
+		}
+		if out != tt.expected {
+			t.Errorf("%s: expected output %q, got %q", tt.input, tt.expected, out)
+		}
+	}
+}
+
+func TestFreeze_Errors(t *testing.T) {
+	tests := []struct {
+		input   string
+		message string
+	}{
+		{`class P {} var p = freeze(P()); p.x = 1;`, "Cannot modify a frozen instance."},
+		{`class P { init() { this.x = 1; } set() { this.x = 2; } } var p = freeze(P()); p.set();`, "Cannot modify a frozen instance."},
+		{`var a = freeze([1]); a[0] = 2;`, "Cannot modify a frozen array."},
+		{`freeze("s");`, "freeze() expects an instance or array."},
+	}
+
+	for _, tt := range tests {
+		_, err := run(t, tt.input)
+		if _, ok := err.(*RuntimeError); !ok || !strings.Contains(err.Error(), tt.message) {
+			t.Errorf("%s: expected a runtime error containing %q, got %v", tt.input, tt.message, err)
+		}
+	}
+}
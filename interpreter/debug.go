@@ -0,0 +1,173 @@
+package interpreter
+
+import (
+	"sort"
+
+	"github.com/butlermatt/glox/parser"
+)
+
+// Hook observes an interpreter as it runs, for debuggers. Statement is called before each
+// statement executes and may block to pause the script there; returning an error stops the
+// script with that error. Spawned tasks run without the hook.
+type Hook interface {
+	Statement(interp *Interpreter, stmt parser.Stmt) error
+}
+
+// Frame is a function call in progress, or the top level of the script.
+type Frame struct {
+	Name string       // The function or generator name, or "<script>"
+	Stmt parser.Stmt  // The statement being executed
+	Env  *Environment // The innermost environment of the statement
+}
+
+// SetHook attaches h to the interpreter, which then keeps track of its call frames.
+func (i *Interpreter) SetHook(h Hook) {
+	i.hook = h
+	i.frames = []*Frame{{Name: "<script>", Env: i.globals}}
+}
+
+// Frames returns the call stack, innermost frame first. It is only kept while a Hook is set.
+func (i *Interpreter) Frames() []*Frame {
+	frames := make([]*Frame, len(i.frames))
+	for n, f := range i.frames {
+		frames[len(i.frames)-1-n] = f
+	}
+	return frames
+}
+
+// Globals returns the global environment.
+func (i *Interpreter) Globals() *Environment {
+	return i.globals
+}
+
+func (i *Interpreter) pushFrame(name string, env *Environment) {
+	i.frames = append(i.frames, &Frame{Name: name, Env: env})
+}
+
+func (i *Interpreter) popFrame() {
+	i.frames = i.frames[:len(i.frames)-1]
+}
+
+// hookStatement records stmt as the current statement of the innermost frame, then calls the hook.
+func (i *Interpreter) hookStatement(stmt parser.Stmt) error {
+	top := i.frames[len(i.frames)-1]
+	top.Stmt, top.Env = stmt, i.environment
+	return i.hook.Statement(i, stmt)
+}
+
+// Eval evaluates expr, which has not been resolved, with env as the current environment, such
+// as that of a paused frame. Its variables are found by searching env and the environments
+// enclosing it at the time of the call. Functions it calls do not trigger the hook.
+//
+// The resolver's checks which apply to a lone expression are made first, so it may not yield,
+// assign to a constant, or use 'this' or 'super' where they are not defined.
+func (i *Interpreter) Eval(expr parser.Expr, env *Environment) (interface{}, error) {
+	if err := checkEval(expr, env); err != nil {
+		return nil, err
+	}
+
+	locals := make(map[parser.Expr]int, len(i.locals))
+	for e, d := range i.locals {
+		locals[e] = d
+	}
+	bind := func(e parser.Expr, name string) {
+		d := 0
+		for scope := env; scope != nil && scope != i.globals; scope = scope.enclosing {
+			if _, ok := scope.m[name]; ok {
+				locals[e] = d
+				return
+			}
+			d++
+		}
+	}
+	parser.Inspect(expr, func(node interface{}) bool {
+		switch e := node.(type) {
+		case *parser.VariableExpr:
+			bind(e, e.Name.Lexeme)
+		case *parser.AssignExpr:
+			bind(e, e.Name.Lexeme)
+		case *parser.ThisExpr:
+			bind(e, "this")
+		case *parser.SuperExpr:
+			bind(e, "super")
+		}
+		return true
+	})
+
+	eval := *i
+	eval.locals, eval.environment, eval.hook, eval.frames = locals, env, nil, nil
+	return eval.evaluate(expr)
+}
+
+// checkEval reports the first part of expr which the resolver would reject, given the variables
+// reachable from env.
+func checkEval(expr parser.Expr, env *Environment) error {
+	var err error
+	parser.Inspect(expr, func(node interface{}) bool {
+		switch e := node.(type) {
+		case *parser.YieldExpr:
+			err = newError(e.Keyword, "Cannot yield outside of a generator.")
+		case *parser.AssignExpr:
+			if scope := env.scopeOf(e.Name.Lexeme); scope != nil && scope.consts[e.Name.Lexeme] {
+				err = newError(e.Name, "Cannot assign to constant '"+e.Name.Lexeme+"'.")
+			}
+		case *parser.ThisExpr:
+			if env.scopeOf("this") == nil {
+				err = newError(e.Keyword, "Cannot use 'this' outside of a class.")
+			}
+		case *parser.SuperExpr:
+			if env.scopeOf("super") == nil {
+				err = newError(e.Keyword, "Cannot use 'super' outside of a class.")
+			}
+		}
+		return err == nil
+	})
+	return err
+}
+
+// scopeOf returns the innermost environment, e or one enclosing it, which defines name.
+func (e *Environment) scopeOf(name string) *Environment {
+	for scope := e; scope != nil; scope = scope.enclosing {
+		if _, ok := scope.m[name]; ok {
+			return scope
+		}
+	}
+	return nil
+}
+
+// Enclosing returns the environment enclosing e, or nil for the globals.
+func (e *Environment) Enclosing() *Environment {
+	return e.enclosing
+}
+
+// Names returns the sorted names of the variables defined directly in e.
+func (e *Environment) Names() []string {
+	var names []string
+	for name := range e.m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Value returns the value of a variable defined directly in e.
+func (e *Environment) Value(name string) (interface{}, bool) {
+	v, ok := e.m[name]
+	return v, ok
+}
+
+// FieldNames returns the sorted names of the fields set on li.
+func (li *LoxInstance) FieldNames() []string {
+	var names []string
+	for name := range li.fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Field returns the value of a field set on li, ignoring its methods.
+func (li *LoxInstance) Field(name string) (interface{}, bool) {
+	v, ok := li.fields[name]
+	return v, ok
+}
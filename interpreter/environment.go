@@ -8,6 +8,7 @@ import (
 type Environment struct {
 	enclosing *Environment
 	m         map[string]interface{}
+	consts    map[string]bool
 }
 
 func NewEnvironment() *Environment {
@@ -26,6 +27,18 @@ func (e *Environment) Define(name *lexer.Token, value interface{}) error {
 	return nil
 }
 
+// DefineConst defines a variable which may not be reassigned.
+func (e *Environment) DefineConst(name *lexer.Token, value interface{}) error {
+	if err := e.Define(name, value); err != nil {
+		return err
+	}
+	if e.consts == nil {
+		e.consts = make(map[string]bool)
+	}
+	e.consts[name.Lexeme] = true
+	return nil
+}
+
 func (e *Environment) builtin(name string, in *BuiltIn) {
 	e.m[name] = in
 }
@@ -56,6 +69,9 @@ func (e *Environment) GetAt(distance int, name *lexer.Token) (interface{}, error
 
 func (e *Environment) Assign(name *lexer.Token, value interface{}) error {
 	if _, ok := e.m[name.Lexeme]; ok {
+		if e.consts[name.Lexeme] {
+			return newError(name, "Cannot assign to constant '"+name.Lexeme+"'.")
+		}
 		e.m[name.Lexeme] = value
 		return nil
 	}
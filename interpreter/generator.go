@@ -0,0 +1,200 @@
+package interpreter
+
+import (
+	"errors"
+
+	"github.com/butlermatt/glox/lexer"
+	"github.com/butlermatt/glox/parser"
+)
+
+// Object is a value whose properties can be accessed with '.'.
+type Object interface {
+	Get(name *lexer.Token) (interface{}, error)
+}
+
+// genResult is passed back to the caller each time a generator suspends or finishes.
+type genResult struct {
+	value interface{}
+	done  bool
+	err   error
+}
+
+// errGeneratorClosed unwinds the body of a generator which is closed while suspended at a yield.
+var errGeneratorClosed = errors.New("generator closed")
+
+// Generator is returned by calling a generator function. The body runs on its own goroutine,
+// but only ever while the caller is blocked waiting for the next value. It has an interpreter of
+// its own, which takes on the call depth and debugger of whichever task resumes it, so a task
+// other than the one which created it may advance it without disturbing the creator's state.
+// A generator which is abandoned before it finishes is closed when a for-in loop over it ends
+// early, or else when the run ends, so that its goroutine exits.
+type Generator struct {
+	fn   *Function
+	env  *Environment
+	body *Interpreter // runs the body
+
+	resumeCh chan bool // true closes the generator instead of resuming it
+	yieldCh  chan genResult
+	started  bool
+	running  bool // the body is running, so it cannot be resumed again until it yields
+	done     bool
+	sent     interface{} // the value passed to next(), returned by the yield it resumes
+
+	// hasNext must run the body to know if there is another value, so it holds on to it here.
+	peeked *genResult
+}
+
+func newGenerator(interp *Interpreter, fn *Function, env *Environment) *Generator {
+	g := &Generator{fn: fn, env: env, body: interp.fork(), resumeCh: make(chan bool), yieldCh: make(chan genResult)}
+	g.body.generator = g
+	return g
+}
+
+func (g *Generator) String() string { return "<generator " + g.fn.declaration.Name.Lexeme + ">" }
+
+func (g *Generator) Get(name *lexer.Token) (interface{}, error) {
+	switch name.Lexeme {
+	case "next":
+		return &BuiltIn{arity: 0, variadic: true, callFn: func(interp *Interpreter, args []interface{}) (interface{}, error) {
+			if len(args) > 1 {
+				return nil, newError(nil, "next() takes at most one argument.")
+			}
+			if len(args) == 1 {
+				g.sent = args[0]
+			}
+			r := g.advance(interp)
+			return r.value, r.err
+		}}, nil
+	case "hasNext":
+		return &BuiltIn{arity: 0, callFn: func(interp *Interpreter, args []interface{}) (interface{}, error) {
+			more, err := g.hasNext(interp)
+			return more, err
+		}}, nil
+	}
+
+	return nil, newError(name, "Undefined property '"+name.Lexeme+"'.")
+}
+
+func (g *Generator) hasNext(interp *Interpreter) (bool, error) {
+	if g.peeked == nil {
+		r := g.resume(interp)
+		g.peeked = &r
+	}
+	return !g.peeked.done, g.peeked.err
+}
+
+// advance returns the next value, or a done result once the body has finished. A value sent with
+// next() is dropped if the body has not started, or already ran ahead for hasNext().
+func (g *Generator) advance(interp *Interpreter) genResult {
+	if g.peeked != nil {
+		r := *g.peeked
+		g.peeked, g.sent = nil, nil
+		return r
+	}
+	return g.resume(interp)
+}
+
+// resume runs the body until the next yield, continuing the call stack of interp, the
+// interpreter of the task resuming it.
+func (g *Generator) resume(interp *Interpreter) genResult {
+	if g.done {
+		return genResult{done: true}
+	}
+	if g.running {
+		// Another task resumed it and is waiting for it, or the body is resuming itself.
+		return genResult{err: newError(nil, "Generator is already running.")}
+	}
+
+	if interp.hook != nil {
+		interp.pushFrame(g.fn.declaration.Name.Lexeme, g.env)
+		defer interp.popFrame()
+	}
+	g.body.depth, g.body.hook, g.body.frames = interp.depth, interp.hook, interp.frames
+	if !g.started {
+		g.started, g.sent = true, nil
+		if b := g.body.budget; b != nil {
+			b.generators[g] = true
+		}
+		go g.run()
+	}
+
+	g.running = true
+	g.resumeCh <- false
+	r := <-g.yieldCh
+	g.running = false
+	g.body.hook, g.body.frames = nil, nil
+	if r.done {
+		g.finish()
+	}
+	return r
+}
+
+// close stops a generator which has not finished. If it is suspended at a yield, its body is
+// unwound as if the yield had failed, and close waits for its goroutine to exit.
+func (g *Generator) close() {
+	if g.done || g.running {
+		return // A running generator is finished by the task waiting for it.
+	}
+	started := g.started
+	g.finish()
+	if !started {
+		return
+	}
+
+	g.resumeCh <- true
+	<-g.yieldCh
+}
+
+// finish marks the generator done, so it no longer needs closing when the run ends.
+func (g *Generator) finish() {
+	g.done, g.peeked = true, nil
+	if b := g.body.budget; b != nil {
+		delete(b.generators, g)
+	}
+}
+
+func (g *Generator) run() {
+	if <-g.resumeCh {
+		g.yieldCh <- genResult{done: true}
+		return
+	}
+	err := g.body.executeBlock(g.fn.declaration.Body, g.env)
+	if _, ok := err.(*ReturnError); ok || err == errGeneratorClosed {
+		err = nil
+	}
+	g.yieldCh <- genResult{done: true, err: err}
+}
+
+// yield hands value to the caller and blocks until the generator is resumed, returning the value
+// sent by the caller, or errGeneratorClosed if it is closed instead.
+func (g *Generator) yield(value interface{}) (interface{}, error) {
+	g.yieldCh <- genResult{value: value}
+	if <-g.resumeCh {
+		return nil, errGeneratorClosed
+	}
+	sent := g.sent
+	g.sent = nil
+	return sent, nil
+}
+
+// VisitYieldExpr suspends the generator, evaluating to the value passed to the next() call which
+// resumes it, or null.
+func (i *Interpreter) VisitYieldExpr(expr *parser.YieldExpr) (interface{}, error) {
+	var value interface{}
+	var err error
+	if expr.Value != nil {
+		value, err = i.evaluate(expr.Value)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return i.generator.yield(value)
+}
+
+func (r *Resolver) VisitYieldExpr(expr *parser.YieldExpr) (interface{}, error) {
+	if !r.inGenerator {
+		return nil, newError(expr.Keyword, "Cannot yield outside of a generator.")
+	}
+	return nil, r.resolveExpr(expr.Value)
+}
@@ -0,0 +1,148 @@
+package interpreter
+
+import (
+	"context"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/butlermatt/glox/lexer"
+	"github.com/butlermatt/glox/parser"
+)
+
+func TestGenerators(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"for-in", `fun* count(n) { for (var i = 0; i < n; i = i + 1) yield i; } for (var x in count(3)) print x;`, "0\n1\n2\n"},
+		{"lazy", `fun* g() { print "start"; yield 1; print "resumed"; } var it = g(); print "created"; print it.next();`, "created\nstart\n1\n"},
+		{"exhaustion", `fun* g() { yield 1; } var it = g(); print it.next(); print it.hasNext(); print it.next(); print it.next();`, "1\nfalse\nnull\nnull\n"},
+		{"return ends", `fun* g() { yield 1; return; yield 2; } for (var x in g()) print x;`, "1\n"},
+		{"hasNext peeks", `fun* g() { yield 1; yield 2; } var it = g(); print it.hasNext(); print it.hasNext(); print it.next(); print it.next(); print it.hasNext();`, "true\ntrue\n1\n2\nfalse\n"},
+		{
+			"send values",
+			`fun* sum() { var total = 0; while (true) { var v = yield total; total = total + v; } }
+var s = sum();
+print s.next(100);
+print s.next(5);
+print s.next(2);`,
+			"0\n5\n7\n",
+		},
+		{"yield without a value", `fun* g() { var got = yield; print got; } var it = g(); it.next(); it.next("sent");`, "sent\n"},
+		{"methods", `class Bag { init() { this.items = [1, 2]; } *each() { for (var x in this.items) yield x * 10; } } for (var x in Bag().each()) print x;`, "10\n20\n"},
+		{"break closes", `fun* g() { var i = 0; while (true) { yield i; i = i + 1; } } for (var x in g()) { if (x == 2) break; print x; }`, "0\n1\n"},
+	}
+
+	for _, tt := range tests {
+		out, err := run(t, tt.input)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+		}
+		if out != tt.expected {
+			t.Errorf("%s: expected output %q, got %q", tt.name, tt.expected, out)
+		}
+	}
+}
+
+func TestGenerator_Errors(t *testing.T) {
+	tests := []struct {
+		input   string
+		message string
+	}{
+		{`fun* g() { yield 1; var x = 1 / "a"; } for (var x in g()) print x;`, "Operands must be numbers."},
+		{`fun* g() { yield 1; } g().next(1, 2);`, "next() takes at most one argument."},
+		{`fun* g() { yield 1; } g().nope;`, "Undefined property 'nope'."},
+		{`fun* g() { yield me.next(); } var me = g(); me.next();`, "Generator is already running."},
+		{`fun* g() { for (var x in me) print x; yield 1; } var me = g(); me.next();`, "Generator is already running."},
+		// A second task may not advance a generator which is blocked running for the first.
+		{`fun* g(ready, c) { send(ready, 1); recv(c); yield 1; } fun f(g) { return g.next(); }
+var ready = chan(); var c = chan(); var gen = g(ready, c); spawn f(gen); recv(ready); gen.next();`, "Generator is already running."},
+	}
+
+	for _, tt := range tests {
+		_, err := run(t, tt.input)
+		if _, ok := err.(*RuntimeError); !ok || !strings.Contains(err.Error(), tt.message) {
+			t.Errorf("%s: expected a runtime error containing %q, got %v", tt.input, tt.message, err)
+		}
+	}
+
+	p := parser.New(lexer.New(`fun f() { yield 1; }`))
+	if _, err := Compile(p.Parse()); err == nil || !strings.Contains(err.Error(), "Cannot yield outside of a generator.") {
+		t.Errorf("expected yield outside a generator to be rejected, got %v", err)
+	}
+}
+
+// goroutinesSettle waits for the number of goroutines to drop to at most n, returning the count.
+func goroutinesSettle(n int) int {
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > n && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	return runtime.NumGoroutine()
+}
+
+func TestGenerator_NoLeaks(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	interp := compile(t, `
+fun* naturals() { var i = 0; while (true) { yield i; i = i + 1; } }
+var total = 0;
+for (var n = 0; n < 100; n = n + 1) {
+  for (var x in naturals()) {
+    if (x == 3) break;
+    total = total + x;
+  }
+}
+`).NewInterpreter()
+	if err := interp.Interpret(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if total, _ := interp.Global("total"); total != float64(300) {
+		t.Errorf("unexpected total. expected=300, got=%v", total)
+	}
+	if after := goroutinesSettle(before); after > before {
+		t.Errorf("breaking out of loops leaked %d goroutines", after-before)
+	}
+
+	// Generators left suspended without a loop are closed when the run ends.
+	interp = compile(t, `
+fun* naturals() { var i = 0; while (true) { yield i; i = i + 1; } }
+var kept = [];
+for (var n = 0; n < 50; n = n + 1) {
+  var g = naturals();
+  g.next();
+  kept = [...kept, g];
+}
+`).NewInterpreter()
+	if err := interp.Interpret(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if after := goroutinesSettle(before); after > before {
+		t.Errorf("abandoned generators leaked %d goroutines", after-before)
+	}
+}
+
+func TestGenerator_OtherTask(t *testing.T) {
+	// The body runs for the task which advances it, so while it blocks in recv() the task which
+	// created it carries on with its own variables.
+	out, err := run(t, `
+fun* received(c) { while (true) { var v = recv(c); yield v; } }
+fun sum(g, n) { var total = 0; for (var i = 0; i < n; i = i + 1) total = total + g.next(); return total; }
+var c = chan();
+var g = received(c);
+var t = spawn sum(g, 100);
+var ok = true;
+for (var i = 0; i < 100; i = i + 1) { var mine = i; send(c, i); if (mine != i) ok = false; }
+print ok;
+print t.wait();
+`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out != "true\n4950\n" {
+		t.Errorf("unexpected output %q", out)
+	}
+}
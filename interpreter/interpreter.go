@@ -1,16 +1,31 @@
 package interpreter
 
 import (
+	"bufio"
+	"context"
 	"errors"
 	"fmt"
 	"github.com/butlermatt/glox/lexer"
 	"github.com/butlermatt/glox/parser"
-	"time"
+	"io"
+	"os"
+	"sync"
 )
 
 var BreakError = errors.New("Unexpected 'break' outside of loop")
 var ContinueError = errors.New("Unexpected 'continue' outside of loop")
 
+// LabelError unwinds to the loop with the matching label, where Err is handled as if it
+// were returned by that loop's body.
+type LabelError struct {
+	Label string
+	Err   error
+}
+
+func (le *LabelError) Error() string {
+	return le.Err.Error() + " '" + le.Label + "'"
+}
+
 type RuntimeError struct {
 	Token   *lexer.Token
 	Message string
@@ -34,20 +49,50 @@ type Interpreter struct {
 	globals     *Environment
 	environment *Environment
 	locals      map[parser.Expr]int
-}
-
+	generator   *Generator  // the generator whose body is currently running, if any
+	gil         *sync.Mutex // held while executing; shared with spawned tasks
+	limits      Limits
+	budget      *budget // usage of the current run
+	depth       int     // current call depth
+	maxDepth    int
+	stdin       *bufio.Reader
+	stdout      io.Writer
+	hook        Hook     // the debugger, if any
+	frames      []*Frame // the call stack, outermost first, kept while hook is set
+}
+
+// New returns an Interpreter for statements with every capability granted.
 func New(statements []parser.Stmt) *Interpreter {
-	env := NewEnvironment()
-	env.builtin("clock", &BuiltIn{
-		arity: 0,
-		callFn: func(interp *Interpreter, args []interface{}) (interface{}, error) {
-			return float64(time.Now().Unix()), nil
-		}},
-	)
-	return &Interpreter{stmts: statements, globals: env, environment: env, locals: make(map[parser.Expr]int)}
+	env := newGlobals(AllCapabilities...)
+	return &Interpreter{stmts: statements, globals: env, environment: env, locals: make(map[parser.Expr]int), gil: &sync.Mutex{}, maxDepth: DefaultMaxStackDepth, stdin: stdin, stdout: os.Stdout}
 }
 
-func (i *Interpreter) Interpret() error {
+// Interpret runs the program, stopping early with a *LimitError if ctx is done or the
+// interpreter's Limits are exceeded. Tasks the script spawned are cancelled when it ends, and
+// Interpret waits for them to stop before returning.
+func (i *Interpreter) Interpret(ctx context.Context) error {
+	if i.limits.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, i.limits.Timeout)
+		defer cancel()
+	}
+	ctx, cancel := context.WithCancel(ctx)
+
+	i.gil.Lock()
+	b := &budget{limits: i.limits, ctx: ctx, generators: make(map[*Generator]bool)}
+	i.budget = b
+	defer func() {
+		cancel()
+		i.gil.Unlock() // Let the tasks run until they see the cancellation.
+		b.tasks.Wait()
+
+		i.gil.Lock()
+		for g := range b.generators {
+			g.close()
+		}
+		i.gil.Unlock()
+	}()
+
 	for _, stmt := range i.stmts {
 		err := i.execute(stmt)
 		if err != nil {
@@ -59,17 +104,52 @@ func (i *Interpreter) Interpret() error {
 }
 
 func (i *Interpreter) execute(stmt parser.Stmt) error {
+	if err := i.budget.step(); err != nil {
+		return err
+	}
+	if i.hook != nil {
+		if err := i.hookStatement(stmt); err != nil {
+			return err
+		}
+	}
 	return stmt.Accept(i)
 }
 
-func (i *Interpreter) resolve(expr parser.Expr, depth int) {
-	i.locals[expr] = depth
+// Global returns the value of the global variable name, if it is defined.
+func (i *Interpreter) Global(name string) (interface{}, bool) {
+	v, ok := i.globals.m[name]
+	return v, ok
 }
 
 func (i *Interpreter) VisitArrayExpr(expr *parser.ArrayExpr) (interface{}, error) {
+	values, err := i.evaluateList(expr.Values)
+	if err != nil {
+		return nil, err
+	}
+	if err := i.alloc(sizeArray + sizeValue*len(values)); err != nil {
+		return nil, err
+	}
+	return NewArray(values), nil
+}
+
+// evaluateList evaluates each expression in turn, expanding any spread arrays in place.
+func (i *Interpreter) evaluateList(exprs []parser.Expr) ([]interface{}, error) {
 	var values []interface{}
-	for _, val := range expr.Values {
-		value, err := i.evaluate(val)
+	for _, e := range exprs {
+		if spread, ok := e.(*parser.SpreadExpr); ok {
+			value, err := i.evaluate(spread.Expression)
+			if err != nil {
+				return nil, err
+			}
+			arr, err := checkSliceOperand(spread.Ellipsis, value)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, arr.Elements...)
+			continue
+		}
+
+		value, err := i.evaluate(e)
 		if err != nil {
 			return nil, err
 		}
@@ -152,6 +232,9 @@ func (i *Interpreter) VisitBinaryExpr(binary *parser.BinaryExpr) (interface{}, e
 			if r, ok := right.(string); !ok {
 				return nil, newError(binary.Operator, "Both operands must be of the same type.")
 			} else {
+				if err := i.alloc(len(l) + len(r)); err != nil {
+					return nil, err
+				}
 				return l + r, nil
 			}
 		default:
@@ -184,11 +267,7 @@ func (i *Interpreter) VisitIndexExpr(expr *parser.IndexExpr) (interface{}, error
 	if err != nil {
 		return nil, err
 	}
-	ind := int(r)
-	if ind >= len(l) {
-		return nil, newError(expr.Operator, "Index out of range.")
-	}
-	return l[ind], nil
+	return l.Get(expr.Operator, int(r))
 }
 
 func (i *Interpreter) VisitLiteralExpr(literal *parser.LiteralExpr) (interface{}, error) {
@@ -232,9 +311,12 @@ func (i *Interpreter) VisitAssignExpr(expr *parser.AssignExpr) (interface{}, err
 		return nil, err
 	}
 	if distance, ok := i.locals[expr]; ok {
-		i.environment.AssignAt(distance, expr.Name, value)
+		err = i.environment.AssignAt(distance, expr.Name, value)
 	} else {
-		i.globals.Assign(expr.Name, value)
+		err = i.globals.Assign(expr.Name, value)
+	}
+	if err != nil {
+		return nil, err
 	}
 
 	return value, nil
@@ -282,10 +364,9 @@ func (i *Interpreter) VisitSetExpr(expr *parser.SetExpr) (interface{}, error) {
 		if err != nil {
 			return nil, err
 		}
-		if index >= len(arr) {
-			return nil, newError(ie.Operator, "Index out of range.")
+		if err := arr.Set(ie.Operator, index, val); err != nil {
+			return nil, err
 		}
-		arr[index] = val
 		return val, nil
 	}
 
@@ -299,7 +380,14 @@ func (i *Interpreter) VisitSetExpr(expr *parser.SetExpr) (interface{}, error) {
 		if err != nil {
 			return nil, err
 		}
-		o.Set(expr.Name, val)
+		if _, ok := o.fields[expr.Name.Lexeme]; !ok {
+			if err := i.alloc(sizeField); err != nil {
+				return nil, err
+			}
+		}
+		if err := o.Set(expr.Name, val); err != nil {
+			return nil, err
+		}
 
 		return val, nil
 	}
@@ -342,28 +430,74 @@ func (i *Interpreter) VisitThisExpr(expr *parser.ThisExpr) (interface{}, error)
 }
 
 func (i *Interpreter) VisitCallExpr(expr *parser.CallExpr) (interface{}, error) {
-	callee, err := i.evaluate(expr.Callee)
+	function, args, err := i.prepareCall(expr)
 	if err != nil {
 		return nil, err
 	}
 
-	var args []interface{}
-	for _, arg := range expr.Args {
-		a, err := i.evaluate(arg)
+	res, err := function.Call(i, args)
+	if re, ok := err.(*RuntimeError); ok && re.Token == nil {
+		// Built-ins don't know where they were called from.
+		re.Token = expr.Paren
+	}
+	return res, err
+}
+
+// prepareCall evaluates the callee and arguments of expr, checking they can be called.
+func (i *Interpreter) prepareCall(expr *parser.CallExpr) (Callable, []interface{}, error) {
+	callee, err := i.evaluate(expr.Callee)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	args, err := i.evaluateList(expr.Args)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	function, ok := callee.(Callable)
+	if !ok {
+		return nil, nil, newError(expr.Paren, "Can only call functions and classes.")
+	}
+	if len(expr.Named) > 0 {
+		args, err = i.bindNamedArgs(function, expr, args)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
-		args = append(args, a)
 	}
+	if err := checkArity(function, expr.Paren, len(args)); err != nil {
+		return nil, nil, err
+	}
+	return function, args, nil
+}
 
-	if function, ok := callee.(Callable); !ok {
-		return nil, newError(expr.Paren, "Can only call functions and classes.")
-	} else {
-		if len(args) != function.Arity() {
-			return nil, newError(expr.Paren, fmt.Sprintf("Expected %d arguments but got %d.", function.Arity(), len(args)))
+// bindNamedArgs evaluates the named arguments of expr and merges them into args.
+func (i *Interpreter) bindNamedArgs(callee Callable, expr *parser.CallExpr, args []interface{}) ([]interface{}, error) {
+	var fn *Function
+	switch c := callee.(type) {
+	case *Function:
+		fn = c
+	case *LoxClass:
+		fn = c.methods["init"]
+	}
+	if fn == nil {
+		return nil, newError(expr.Paren, "Callee does not accept named arguments.")
+	}
+
+	values := make([]interface{}, len(expr.Named))
+	for ind, e := range expr.Named {
+		v, err := i.evaluate(e)
+		if err != nil {
+			return nil, err
 		}
-		return function.Call(i, args)
+		values[ind] = v
 	}
+
+	return fn.bindNamed(args, expr.Names, values)
+}
+
+func (i *Interpreter) VisitSpreadExpr(expr *parser.SpreadExpr) (interface{}, error) {
+	return nil, newError(expr.Ellipsis, "Spread is only allowed in arguments and array values.")
 }
 
 func (i *Interpreter) VisitGetExpr(expr *parser.GetExpr) (interface{}, error) {
@@ -371,8 +505,8 @@ func (i *Interpreter) VisitGetExpr(expr *parser.GetExpr) (interface{}, error) {
 	if err != nil {
 		return nil, err
 	}
-	if li, ok := obj.(*LoxInstance); ok {
-		return li.Get(expr.Name)
+	if o, ok := obj.(Object); ok {
+		return o.Get(expr.Name)
 	}
 	return nil, newError(expr.Name, "Only instances have properties.")
 }
@@ -381,6 +515,15 @@ func (i *Interpreter) evaluate(expr parser.Expr) (interface{}, error) {
 	return expr.Accept(i)
 }
 
+// evaluateIn evaluates expr with env as the current environment.
+func (i *Interpreter) evaluateIn(expr parser.Expr, env *Environment) (interface{}, error) {
+	prev := i.environment
+	i.environment = env
+	val, err := i.evaluate(expr)
+	i.environment = prev
+	return val, err
+}
+
 func (i *Interpreter) VisitExpressionStmt(stmt *parser.ExpressionStmt) error {
 	_, err := i.evaluate(stmt.Expression)
 	return err
@@ -391,7 +534,7 @@ func (i *Interpreter) VisitPrintStmt(stmt *parser.PrintStmt) error {
 	if err != nil {
 		return err
 	}
-	fmt.Println(stringify(val))
+	fmt.Fprintln(i.stdout, stringify(val))
 	return nil
 }
 
@@ -405,11 +548,20 @@ func (i *Interpreter) VisitVarStmt(stmt *parser.VarStmt) error {
 		}
 	}
 
+	if err := i.alloc(sizeField); err != nil {
+		return err
+	}
+	if stmt.Constant {
+		return i.environment.DefineConst(stmt.Name, value)
+	}
 	i.environment.Define(stmt.Name, value)
 	return nil
 }
 
 func (i *Interpreter) VisitBlockStmt(stmt *parser.BlockStmt) error {
+	if err := i.alloc(sizeEnv); err != nil {
+		return err
+	}
 	return i.executeBlock(stmt.Statements, NewEnclosedEnvironment(i.environment))
 }
 
@@ -473,15 +625,11 @@ func (i *Interpreter) VisitForStmt(stmt *parser.ForStmt) error {
 		}
 	}
 
-	cond, err := i.evaluate(stmt.Condition)
+	cond, err := i.loopCondition(stmt.Condition)
 	for err == nil && isTruthy(cond) {
-		err = i.execute(stmt.Body)
-		if err == BreakError {
-			err = nil
-			break
-		} else if err == ContinueError {
-			err = nil
-		} else if err != nil {
+		var stop bool
+		stop, err = loopSignal(stmt.Label, i.execute(stmt.Body))
+		if stop {
 			break
 		}
 
@@ -492,7 +640,7 @@ func (i *Interpreter) VisitForStmt(stmt *parser.ForStmt) error {
 				break
 			}
 		}
-		cond, err = i.evaluate(stmt.Condition)
+		cond, err = i.loopCondition(stmt.Condition)
 
 	}
 
@@ -500,11 +648,41 @@ func (i *Interpreter) VisitForStmt(stmt *parser.ForStmt) error {
 	return err
 }
 
+// loopCondition evaluates the condition of a for loop, where a missing condition is always true.
+func (i *Interpreter) loopCondition(cond parser.Expr) (interface{}, error) {
+	if cond == nil {
+		return true, nil
+	}
+	return i.evaluate(cond)
+}
+
+// loopSignal interprets the error returned by the body of a loop with the given label. stop is
+// true if the loop should exit, and err is any error which should continue to unwind.
+func loopSignal(label *lexer.Token, err error) (stop bool, rest error) {
+	if le, ok := err.(*LabelError); ok && label != nil && le.Label == label.Lexeme {
+		err = le.Err
+	}
+
+	switch err {
+	case nil, ContinueError:
+		return false, nil
+	case BreakError:
+		return true, nil
+	}
+	return true, err
+}
+
 func (i *Interpreter) VisitBreakStmt(stmt *parser.BreakStmt) error {
+	if stmt.Label != nil {
+		return &LabelError{Label: stmt.Label.Lexeme, Err: BreakError}
+	}
 	return BreakError
 }
 
 func (i *Interpreter) VisitContinueStmt(stmt *parser.ContinueStmt) error {
+	if stmt.Label != nil {
+		return &LabelError{Label: stmt.Label.Lexeme, Err: ContinueError}
+	}
 	return ContinueError
 }
 
@@ -575,8 +753,8 @@ func checkNumberOperands(operator *lexer.Token, left, right interface{}) (float6
 	return l, r, nil
 }
 
-func checkSliceOperand(operator *lexer.Token, operand interface{}) ([]interface{}, error) {
-	sl, ok := operand.([]interface{})
+func checkSliceOperand(operator *lexer.Token, operand interface{}) (*LoxArray, error) {
+	sl, ok := operand.(*LoxArray)
 	if !ok {
 		return nil, newError(operator, "Operand must be an array.")
 	}
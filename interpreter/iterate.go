@@ -0,0 +1,123 @@
+package interpreter
+
+import (
+	"github.com/butlermatt/glox/lexer"
+	"github.com/butlermatt/glox/parser"
+)
+
+// iterator returns successive values of a sequence. ok is false once the sequence is exhausted.
+type iterator func() (value interface{}, ok bool, err error)
+
+// newIterator returns an iterator over an array, string, generator or instance implementing the iterator
+// protocol. Instances may provide an iter() method returning the iterator, and iterators provide
+// hasNext() and next() methods. The returned stop function must be called when the loop ends, to
+// close a generator which was not exhausted.
+func (i *Interpreter) newIterator(token *lexer.Token, value interface{}) (next iterator, stop func(), err error) {
+	stop = func() {}
+	switch v := value.(type) {
+	case *LoxArray:
+		ind := 0
+		return func() (interface{}, bool, error) {
+			if ind >= len(v.Elements) {
+				return nil, false, nil
+			}
+			ind++
+			return v.Elements[ind-1], true, nil
+		}, stop, nil
+	case string:
+		chars := []rune(v)
+		ind := 0
+		return func() (interface{}, bool, error) {
+			if ind >= len(chars) {
+				return nil, false, nil
+			}
+			ind++
+			return string(chars[ind-1]), true, nil
+		}, stop, nil
+	case *Generator:
+		return func() (interface{}, bool, error) {
+			r := v.advance(i)
+			if re, ok := r.err.(*RuntimeError); ok && re.Token == nil {
+				re.Token = token
+			}
+			return r.value, !r.done && r.err == nil, r.err
+		}, v.close, nil
+	case *LoxInstance:
+		if iter := v.klass.findMethod(v, "iter"); iter != nil {
+			it, err := iter.Call(i, nil)
+			if err != nil {
+				return nil, nil, err
+			}
+			if it != value {
+				return i.newIterator(token, it)
+			}
+		}
+
+		hasNext := v.klass.findMethod(v, "hasNext")
+		next := v.klass.findMethod(v, "next")
+		if hasNext == nil || next == nil {
+			break
+		}
+		return func() (interface{}, bool, error) {
+			more, err := hasNext.Call(i, nil)
+			if err != nil || !isTruthy(more) {
+				return nil, false, err
+			}
+			val, err := next.Call(i, nil)
+			return val, err == nil, err
+		}, stop, nil
+	}
+
+	return nil, nil, newError(token, "Can only iterate over arrays, strings and iterators.")
+}
+
+func (i *Interpreter) VisitForInStmt(stmt *parser.ForInStmt) error {
+	iterable, err := i.evaluate(stmt.Iterable)
+	if err != nil {
+		return err
+	}
+	next, stop, err := i.newIterator(stmt.Keyword, iterable)
+	if err != nil {
+		return err
+	}
+	defer stop()
+
+	prev := i.environment
+	defer func() { i.environment = prev }()
+	for {
+		value, ok, err := next()
+		if err != nil || !ok {
+			return err
+		}
+
+		// Each iteration gets a fresh binding so closures capture the current value.
+		if err := i.alloc(sizeEnv + sizeField); err != nil {
+			return err
+		}
+		i.environment = NewEnclosedEnvironment(prev)
+		i.environment.Define(stmt.Name, value)
+		done, err := loopSignal(stmt.Label, i.execute(stmt.Body))
+		i.environment = prev
+		if done {
+			return err
+		}
+	}
+}
+
+func (r *Resolver) VisitForInStmt(stmt *parser.ForInStmt) error {
+	err := r.resolveExpr(stmt.Iterable)
+	if err != nil {
+		return err
+	}
+
+	r.beginScope()
+	r.declare(stmt.Name)
+	r.define(stmt.Name)
+
+	oldLoop := r.inLoop
+	r.inLoop = true
+	err = r.resolveLoopBody(stmt.Label, stmt.Body)
+	r.inLoop = oldLoop
+	r.endScope()
+	return err
+}
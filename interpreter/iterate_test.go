@@ -0,0 +1,70 @@
+package interpreter
+
+import (
+	"strings"
+	"testing"
+)
+
+const rangeClass = `
+class Range {
+  init(lo, hi) { this.lo = lo; this.hi = hi; }
+  iter() { return RangeIter(this.lo, this.hi); }
+}
+class RangeIter {
+  init(at, hi) { this.at = at; this.hi = hi; }
+  hasNext() { return this.at < this.hi; }
+  next() { this.at = this.at + 1; return this.at - 1; }
+}
+`
+
+func TestForIn(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"array", `for (var x in [1, "two", null]) print x;`, "1\ntwo\nnull\n"},
+		{"empty array", `for (var x in []) print x; print "done";`, "done\n"},
+		{"string", `for (var c in "héy") print c;`, "h\né\ny\n"},
+		{"generator", `fun* g() { yield 1; yield 2; } for (var x in g()) print x;`, "1\n2\n"},
+		{"instance with iter()", `for (var x in Range(0, 3)) print x;`, "0\n1\n2\n"},
+		{"iterator instance", `for (var x in RangeIter(5, 7)) print x;`, "5\n6\n"},
+		{"iter() returning a generator", `class Twice { init(v) { this.v = v; } *iter() { yield this.v; yield this.v; } } for (var x in Twice("a")) print x;`, "a\na\n"},
+		{"iter() returning an array", `class Bag { iter() { return [3, 4]; } } for (var x in Bag()) print x;`, "3\n4\n"},
+		{"break", `for (var x in [1, 2, 3]) { if (x == 2) break; print x; }`, "1\n"},
+		{"continue", `for (var x in [1, 2, 3]) { if (x == 2) continue; print x; }`, "1\n3\n"},
+		{"labeled", `outer: for (var x in [1, 2]) { for (var y in "ab") { if (y == "b") continue outer; print x; print y; } }`, "1\na\n2\na\n"},
+		{"fresh binding", `var fs = []; for (var x in [1, 2]) { fun f() { return x; } fs = [...fs, f]; } for (var f in fs) print f();`, "1\n2\n"},
+		{"array is read as it changes", `var a = [1, 2]; for (var x in a) { if (x == 1) a[1] = 5; print x; }`, "1\n5\n"},
+	}
+
+	for _, tt := range tests {
+		out, err := run(t, rangeClass+tt.input)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+		}
+		if out != tt.expected {
+			t.Errorf("%s: expected output %q, got %q", tt.name, tt.expected, out)
+		}
+	}
+}
+
+func TestForIn_Errors(t *testing.T) {
+	tests := []struct {
+		input   string
+		message string
+	}{
+		{`for (var x in 1) print x;`, "Can only iterate over arrays, strings and iterators."},
+		{`class A {} for (var x in A()) print x;`, "Can only iterate over arrays, strings and iterators."},
+		{`class A { hasNext() { return true; } } for (var x in A()) print x;`, "Can only iterate over arrays, strings and iterators."},
+		{`class A { iter() { return 1; } } for (var x in A()) print x;`, "Can only iterate over arrays, strings and iterators."},
+		{`class A { hasNext() { return true; } next() { return nope; } } for (var x in A()) print x;`, "Undefined variable 'nope'."},
+	}
+
+	for _, tt := range tests {
+		_, err := run(t, tt.input)
+		if _, ok := err.(*RuntimeError); !ok || !strings.Contains(err.Error(), tt.message) {
+			t.Errorf("%s: expected a runtime error containing %q, got %v", tt.input, tt.message, err)
+		}
+	}
+}
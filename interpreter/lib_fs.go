@@ -0,0 +1,292 @@
+package interpreter
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func defineFS(env *Environment) {
+	env.builtin("readFile", &BuiltIn{arity: 1, callFn: builtinReadFile})
+	env.builtin("readLines", &BuiltIn{arity: 1, callFn: builtinReadLines})
+	env.builtin("writeFile", &BuiltIn{arity: 2, callFn: builtinWriteFile})
+	env.builtin("appendFile", &BuiltIn{arity: 2, callFn: builtinAppendFile})
+	env.builtin("listDir", &BuiltIn{arity: 1, callFn: builtinListDir})
+	env.builtin("exists", &BuiltIn{arity: 1, callFn: builtinExists})
+	env.builtin("isDir", &BuiltIn{arity: 1, callFn: builtinIsDir})
+	env.builtin("mkdir", &BuiltIn{arity: 1, callFn: builtinMkdir})
+	env.builtin("remove", &BuiltIn{arity: 1, callFn: builtinRemove})
+	env.builtin("removeAll", &BuiltIn{arity: 1, callFn: builtinRemoveAll})
+	env.builtin("rename", &BuiltIn{arity: 2, callFn: builtinRename})
+
+	env.builtin("pathJoin", &BuiltIn{arity: 1, variadic: true, callFn: builtinPathJoin})
+	env.builtin("pathBase", &BuiltIn{arity: 1, callFn: pathFn("pathBase", filepath.Base)})
+	env.builtin("pathDir", &BuiltIn{arity: 1, callFn: pathFn("pathDir", filepath.Dir)})
+	env.builtin("pathExt", &BuiltIn{arity: 1, callFn: pathFn("pathExt", filepath.Ext)})
+	env.builtin("pathClean", &BuiltIn{arity: 1, callFn: pathFn("pathClean", filepath.Clean)})
+	env.builtin("pathAbs", &BuiltIn{arity: 1, callFn: builtinPathAbs})
+}
+
+// fsError reports a failed file system operation as a runtime error at the call site.
+func fsError(fnName string, err error) error {
+	return newError(nil, fnName+"() failed: "+err.Error())
+}
+
+func builtinReadFile(interp *Interpreter, args []interface{}) (interface{}, error) {
+	path, err := checkStringArg(args[0], "readFile")
+	if err != nil {
+		return nil, err
+	}
+
+	return readAll(interp, "readFile", path)
+}
+
+// readChunk is how much of a file is read at a time, each chunk charged before it is kept.
+const readChunk = 32 * 1024
+
+// readAll returns the contents of a file, charging the memory budget as each chunk is read so
+// a file too large for the limit fails before it is held in memory.
+func readAll(interp *Interpreter, fnName, path string) (string, error) {
+	var f *os.File
+	var err error
+	interp.blocking(func() { f, err = os.Open(path) })
+	if err != nil {
+		return "", fsError(fnName, err)
+	}
+	defer f.Close()
+
+	var sb strings.Builder
+	buf := make([]byte, readChunk)
+	for {
+		var n int
+		interp.blocking(func() { n, err = f.Read(buf) })
+		if n > 0 {
+			if err := interp.alloc(n); err != nil {
+				return "", err
+			}
+			sb.Write(buf[:n])
+		}
+		if err == io.EOF {
+			return sb.String(), nil
+		}
+		if err != nil {
+			return "", fsError(fnName, err)
+		}
+	}
+}
+
+// builtinReadLines returns the lines of a file as an array, without their line endings.
+func builtinReadLines(interp *Interpreter, args []interface{}) (interface{}, error) {
+	path, err := checkStringArg(args[0], "readLines")
+	if err != nil {
+		return nil, err
+	}
+
+	text, err := readAll(interp, "readLines", path)
+	if err != nil {
+		return nil, err
+	}
+
+	// The lines share the text already charged for, leaving only their slots in the array.
+	text = strings.TrimSuffix(text, "\n")
+	count := 0
+	if text != "" {
+		count = strings.Count(text, "\n") + 1
+	}
+	if err := interp.alloc(sizeArray + count*sizeValue); err != nil {
+		return nil, err
+	}
+	lines := make([]interface{}, 0, count)
+	if count > 0 {
+		for _, line := range strings.Split(text, "\n") {
+			lines = append(lines, strings.TrimSuffix(line, "\r"))
+		}
+	}
+	return NewArray(lines), nil
+}
+
+func builtinWriteFile(interp *Interpreter, args []interface{}) (interface{}, error) {
+	path, err := checkStringArg(args[0], "writeFile")
+	if err != nil {
+		return nil, err
+	}
+
+	interp.blocking(func() { err = ioutil.WriteFile(path, []byte(stringify(args[1])), 0644) })
+	if err != nil {
+		return nil, fsError("writeFile", err)
+	}
+	return nil, nil
+}
+
+func builtinAppendFile(interp *Interpreter, args []interface{}) (interface{}, error) {
+	path, err := checkStringArg(args[0], "appendFile")
+	if err != nil {
+		return nil, err
+	}
+
+	interp.blocking(func() {
+		var f *os.File
+		f, err = os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return
+		}
+		_, err = f.WriteString(stringify(args[1]))
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	})
+	if err != nil {
+		return nil, fsError("appendFile", err)
+	}
+	return nil, nil
+}
+
+// builtinListDir returns the sorted names of the entries in a directory.
+func builtinListDir(interp *Interpreter, args []interface{}) (interface{}, error) {
+	path, err := checkStringArg(args[0], "listDir")
+	if err != nil {
+		return nil, err
+	}
+
+	var infos []os.FileInfo
+	interp.blocking(func() { infos, err = ioutil.ReadDir(path) })
+	if err != nil {
+		return nil, fsError("listDir", err)
+	}
+
+	names := make([]string, len(infos))
+	for i, info := range infos {
+		names[i] = info.Name()
+	}
+	if err := interp.alloc(sizeArray + sizeValue*len(names)); err != nil {
+		return nil, err
+	}
+	return sortedNames(names), nil
+}
+
+func builtinExists(interp *Interpreter, args []interface{}) (interface{}, error) {
+	path, err := checkStringArg(args[0], "exists")
+	if err != nil {
+		return nil, err
+	}
+
+	interp.blocking(func() { _, err = os.Stat(path) })
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return nil, fsError("exists", err)
+	}
+	return true, nil
+}
+
+func builtinIsDir(interp *Interpreter, args []interface{}) (interface{}, error) {
+	path, err := checkStringArg(args[0], "isDir")
+	if err != nil {
+		return nil, err
+	}
+
+	var info os.FileInfo
+	interp.blocking(func() { info, err = os.Stat(path) })
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return nil, fsError("isDir", err)
+	}
+	return info.IsDir(), nil
+}
+
+// builtinMkdir creates a directory along with any missing parents.
+func builtinMkdir(interp *Interpreter, args []interface{}) (interface{}, error) {
+	path, err := checkStringArg(args[0], "mkdir")
+	if err != nil {
+		return nil, err
+	}
+
+	interp.blocking(func() { err = os.MkdirAll(path, 0755) })
+	if err != nil {
+		return nil, fsError("mkdir", err)
+	}
+	return nil, nil
+}
+
+// builtinRemove removes a file or empty directory.
+func builtinRemove(interp *Interpreter, args []interface{}) (interface{}, error) {
+	path, err := checkStringArg(args[0], "remove")
+	if err != nil {
+		return nil, err
+	}
+
+	interp.blocking(func() { err = os.Remove(path) })
+	if err != nil {
+		return nil, fsError("remove", err)
+	}
+	return nil, nil
+}
+
+// builtinRemoveAll removes a path and anything it contains.
+func builtinRemoveAll(interp *Interpreter, args []interface{}) (interface{}, error) {
+	path, err := checkStringArg(args[0], "removeAll")
+	if err != nil {
+		return nil, err
+	}
+
+	interp.blocking(func() { err = os.RemoveAll(path) })
+	if err != nil {
+		return nil, fsError("removeAll", err)
+	}
+	return nil, nil
+}
+
+func builtinRename(interp *Interpreter, args []interface{}) (interface{}, error) {
+	from, err := checkStringArg(args[0], "rename")
+	if err != nil {
+		return nil, err
+	}
+	to, err := checkStringArg(args[1], "rename")
+	if err != nil {
+		return nil, err
+	}
+
+	interp.blocking(func() { err = os.Rename(from, to) })
+	if err != nil {
+		return nil, fsError("rename", err)
+	}
+	return nil, nil
+}
+
+func builtinPathJoin(interp *Interpreter, args []interface{}) (interface{}, error) {
+	parts := make([]string, len(args))
+	for i, a := range args {
+		s, err := checkStringArg(a, "pathJoin")
+		if err != nil {
+			return nil, err
+		}
+		parts[i] = s
+	}
+	return filepath.Join(parts...), nil
+}
+
+// pathFn adapts a function of one path to a built-in.
+func pathFn(fnName string, fn func(string) string) CallFn {
+	return func(interp *Interpreter, args []interface{}) (interface{}, error) {
+		path, err := checkStringArg(args[0], fnName)
+		if err != nil {
+			return nil, err
+		}
+		return fn(path), nil
+	}
+}
+
+func builtinPathAbs(interp *Interpreter, args []interface{}) (interface{}, error) {
+	path, err := checkStringArg(args[0], "pathAbs")
+	if err != nil {
+		return nil, err
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fsError("pathAbs", err)
+	}
+	return abs, nil
+}
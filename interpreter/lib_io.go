@@ -0,0 +1,56 @@
+package interpreter
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+func defineIO(env *Environment) {
+	env.builtin("write", &BuiltIn{arity: 1, callFn: builtinWrite})
+	env.builtin("writeErr", &BuiltIn{arity: 1, callFn: builtinWriteErr})
+	env.builtin("input", &BuiltIn{arity: 0, variadic: true, callFn: builtinInput})
+	env.builtin("readLine", &BuiltIn{arity: 0, callFn: builtinReadLine})
+}
+
+// builtinWrite prints a value to stdout without the trailing newline added by print.
+func builtinWrite(interp *Interpreter, args []interface{}) (interface{}, error) {
+	fmt.Fprint(interp.stdout, stringify(args[0]))
+	return nil, nil
+}
+
+func builtinWriteErr(interp *Interpreter, args []interface{}) (interface{}, error) {
+	fmt.Fprint(os.Stderr, stringify(args[0]))
+	return nil, nil
+}
+
+// builtinInput writes an optional prompt to stdout then reads a line from stdin.
+func builtinInput(interp *Interpreter, args []interface{}) (interface{}, error) {
+	if len(args) > 1 {
+		return nil, newError(nil, "input() expects at most 1 argument.")
+	}
+	if len(args) == 1 {
+		fmt.Fprint(interp.stdout, stringify(args[0]))
+	}
+	return builtinReadLine(interp, nil)
+}
+
+// builtinReadLine reads a line from stdin without its line ending, returning null at the end
+// of the input.
+func builtinReadLine(interp *Interpreter, args []interface{}) (interface{}, error) {
+	var line string
+	var err error
+	interp.blocking(func() { line, err = interp.stdin.ReadString('\n') })
+	if err == io.EOF && line == "" {
+		return nil, nil
+	} else if err != nil && err != io.EOF {
+		return nil, newError(nil, "readLine() failed: "+err.Error())
+	}
+
+	line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+	if err := interp.alloc(len(line)); err != nil {
+		return nil, err
+	}
+	return line, nil
+}
@@ -0,0 +1,85 @@
+package interpreter
+
+import (
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+)
+
+func defineNetLocal(env *Environment) {
+	env.builtin("httpGet", &BuiltIn{arity: 1, callFn: builtinHTTPGet})
+	env.builtin("httpPost", &BuiltIn{arity: 2, callFn: builtinHTTPPost})
+}
+
+var errNotLoopback = errors.New("only loopback addresses may be contacted")
+
+// localClient refuses to connect anywhere but the loopback interface. The check is made on the
+// resolved address, so a hostname which resolves elsewhere is refused too.
+var localClient = &http.Client{
+	Transport: &http.Transport{
+		Proxy: nil,
+		DialContext: (&net.Dialer{
+			Control: func(network, address string, c syscall.RawConn) error {
+				host, _, err := net.SplitHostPort(address)
+				if err != nil {
+					return err
+				}
+				if ip := net.ParseIP(host); ip == nil || !ip.IsLoopback() {
+					return errNotLoopback
+				}
+				return nil
+			},
+		}).DialContext,
+	},
+}
+
+func builtinHTTPGet(interp *Interpreter, args []interface{}) (interface{}, error) {
+	url, err := checkStringArg(args[0], "httpGet")
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, newError(nil, "httpGet() failed: "+err.Error())
+	}
+	return doLocalRequest(interp, "httpGet", req)
+}
+
+func builtinHTTPPost(interp *Interpreter, args []interface{}) (interface{}, error) {
+	url, err := checkStringArg(args[0], "httpPost")
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(stringify(args[1])))
+	if err != nil {
+		return nil, newError(nil, "httpPost() failed: "+err.Error())
+	}
+	return doLocalRequest(interp, "httpPost", req)
+}
+
+// doLocalRequest sends req and returns the response body as a string.
+func doLocalRequest(interp *Interpreter, fnName string, req *http.Request) (interface{}, error) {
+	var body []byte
+	var err error
+	req = req.WithContext(interp.context())
+	interp.blocking(func() {
+		var resp *http.Response
+		resp, err = localClient.Do(req)
+		if err != nil {
+			return
+		}
+		defer resp.Body.Close()
+		body, err = ioutil.ReadAll(resp.Body)
+	})
+	if err != nil {
+		return nil, newError(nil, fnName+"() failed: "+err.Error())
+	}
+
+	if err := interp.alloc(len(body)); err != nil {
+		return nil, err
+	}
+	return string(body), nil
+}
@@ -0,0 +1,56 @@
+package interpreter
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+func defineOS(env *Environment) {
+	env.builtin("getenv", &BuiltIn{arity: 1, callFn: builtinGetenv})
+	env.builtin("exec", &BuiltIn{arity: 1, variadic: true, callFn: builtinExec})
+}
+
+// builtinGetenv returns the value of an environment variable, or null if it is not set.
+func builtinGetenv(interp *Interpreter, args []interface{}) (interface{}, error) {
+	name, err := checkStringArg(args[0], "getenv")
+	if err != nil {
+		return nil, err
+	}
+	if v, ok := os.LookupEnv(name); ok {
+		return v, nil
+	}
+	return nil, nil
+}
+
+// builtinExec runs a command, exec(name, args...), and returns its standard output.
+func builtinExec(interp *Interpreter, args []interface{}) (interface{}, error) {
+	name, err := checkStringArg(args[0], "exec")
+	if err != nil {
+		return nil, err
+	}
+	var cmdArgs []string
+	for _, a := range args[1:] {
+		cmdArgs = append(cmdArgs, stringify(a))
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(interp.context(), name, cmdArgs...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	interp.blocking(func() { err = cmd.Run() })
+	if err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return nil, newError(nil, "exec() failed: "+msg)
+	}
+
+	out := stdout.String()
+	if err := interp.alloc(len(out)); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
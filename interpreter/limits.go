@@ -0,0 +1,140 @@
+package interpreter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DefaultMaxStackDepth is the deepest nesting of function calls allowed before a script fails
+// with a stack overflow, unless changed with SetMaxStackDepth.
+const DefaultMaxStackDepth = 10000
+
+// Approximate sizes, in bytes, charged against Limits.MaxMemory.
+const (
+	sizeValue    = 16 // An interface value, such as an array element
+	sizeArray    = 48
+	sizeInstance = 64
+	sizeField    = 48 // A map entry in an instance or environment
+	sizeEnv      = 64
+)
+
+// Errors wrapped by a LimitError to identify which limit was exceeded.
+var (
+	ErrStepLimit   = errors.New("step limit exceeded")
+	ErrDepthLimit  = errors.New("call depth limit exceeded")
+	ErrMemoryLimit = errors.New("memory limit exceeded")
+)
+
+// Limits bound the resources a script may use. A zero value for any field means no limit.
+type Limits struct {
+	MaxSteps     int           // Number of statements executed
+	MaxCallDepth int           // Depth of nested function calls
+	Timeout      time.Duration // Wall time for the whole run
+	// MaxMemory is the approximate number of bytes the script may allocate over the whole run
+	// for strings, arrays, instances and variables. Memory is not credited back when values
+	// become garbage, so this bounds the total work as well as what is live at any one time.
+	MaxMemory int64
+}
+
+// LimitError is returned when a script exceeds one of its Limits or its context is done.
+type LimitError struct {
+	Message string
+	Err     error // One of the Err*Limit errors, or the context's error
+}
+
+func (le *LimitError) Error() string {
+	return "[Limit Error] " + le.Message
+}
+
+func (le *LimitError) Unwrap() error { return le.Err }
+
+// budget tracks a run's usage against its limits. It is shared with any tasks the run spawns,
+// and only touched while holding the interpreter lock.
+type budget struct {
+	limits     Limits
+	ctx        context.Context // cancelled when the run ends
+	steps      int
+	allocated  int64
+	tasks      sync.WaitGroup      // the tasks spawned by the run, which Interpret waits for
+	generators map[*Generator]bool // the generators started and not yet finished, closed when the run ends
+}
+
+// SetLimits sets the limits applied to subsequent calls to Interpret.
+func (i *Interpreter) SetLimits(limits Limits) {
+	i.limits = limits
+}
+
+// context returns the context of the current run.
+func (i *Interpreter) context() context.Context {
+	if i.budget == nil {
+		return context.Background()
+	}
+	return i.budget.ctx
+}
+
+// step accounts for the execution of one statement.
+func (b *budget) step() error {
+	if b == nil {
+		return nil
+	}
+
+	select {
+	case <-b.ctx.Done():
+		return ctxLimitError(b.ctx)
+	default:
+	}
+
+	b.steps++
+	if b.limits.MaxSteps > 0 && b.steps > b.limits.MaxSteps {
+		return &LimitError{Message: fmt.Sprintf("Exceeded the limit of %d steps.", b.limits.MaxSteps), Err: ErrStepLimit}
+	}
+	return nil
+}
+
+// alloc charges an allocation of approximately size bytes against the run's memory limit.
+func (i *Interpreter) alloc(size int) error {
+	b := i.budget
+	if b == nil {
+		return nil
+	}
+
+	b.allocated += int64(size)
+	if b.limits.MaxMemory > 0 && b.allocated > b.limits.MaxMemory {
+		return &LimitError{Message: fmt.Sprintf("Exceeded the memory limit of %d bytes.", b.limits.MaxMemory), Err: ErrMemoryLimit}
+	}
+	return nil
+}
+
+// SetMaxStackDepth sets the deepest nesting of function calls allowed before a script fails with
+// a "Stack overflow." runtime error. Unlike Limits.MaxCallDepth this is always enforced, to stop
+// runaway recursion from exhausting the Go stack.
+func (i *Interpreter) SetMaxStackDepth(depth int) {
+	i.maxDepth = depth
+}
+
+// enterCall records a nested function call, which must be paired with a call to exitCall.
+func (i *Interpreter) enterCall() error {
+	i.depth++
+	if i.depth > i.maxDepth {
+		// Reported at the call site by VisitCallExpr.
+		return newError(nil, "Stack overflow.")
+	}
+	if i.budget != nil && i.budget.limits.MaxCallDepth > 0 && i.depth > i.budget.limits.MaxCallDepth {
+		return &LimitError{Message: fmt.Sprintf("Exceeded the limit of %d nested calls.", i.budget.limits.MaxCallDepth), Err: ErrDepthLimit}
+	}
+	return nil
+}
+
+func (i *Interpreter) exitCall() {
+	i.depth--
+}
+
+func ctxLimitError(ctx context.Context) *LimitError {
+	if ctx.Err() == context.DeadlineExceeded {
+		return &LimitError{Message: "Exceeded the time limit.", Err: ctx.Err()}
+	}
+	return &LimitError{Message: "Execution cancelled.", Err: ctx.Err()}
+}
@@ -0,0 +1,91 @@
+package interpreter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestInterpreter_Limits(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		limits Limits
+	}{
+		{"steps", `while (true) {}`, Limits{MaxSteps: 1000}},
+		{"call depth", `fun f() { f(); } f();`, Limits{MaxCallDepth: 50}},
+		{"timeout", `while (true) {}`, Limits{Timeout: 20 * time.Millisecond}},
+		{"blocked timeout", `recv(chan());`, Limits{Timeout: 20 * time.Millisecond}},
+		{"string growth", `var s = "x"; while (true) s = s + s;`, Limits{MaxMemory: 1 << 20}},
+		{"array growth", `var a = []; while (true) a = [...a, a];`, Limits{MaxMemory: 1 << 20}},
+		{"instances", `class A {} var l = null; while (true) { var a = A(); a.next = l; l = a; }`, Limits{MaxMemory: 1 << 20}},
+	}
+
+	for _, tt := range tests {
+		interp := compile(t, tt.input).NewInterpreter()
+		interp.SetLimits(tt.limits)
+
+		err := interp.Interpret(context.Background())
+		if _, ok := err.(*LimitError); !ok {
+			t.Errorf("%s: expected a *LimitError, got=%T (%v)", tt.name, err, err)
+		}
+	}
+}
+
+func TestInterpreter_Cancel(t *testing.T) {
+	interp := compile(t, `while (true) {}`).NewInterpreter()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+
+	err := interp.Interpret(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected cancellation error, got=%v", err)
+	}
+}
+
+func TestInterpreter_WithinLimits(t *testing.T) {
+	interp := compile(t, `var x = 0; for (var i = 0; i < 10; i = i + 1) x = x + i;`).NewInterpreter()
+	interp.SetLimits(Limits{MaxSteps: 100, MaxCallDepth: 5, Timeout: time.Second, MaxMemory: 4096})
+
+	if err := interp.Interpret(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if x, _ := interp.Global("x"); x != float64(45) {
+		t.Errorf("unexpected result. expected=45, got=%v", x)
+	}
+}
+
+func TestInterpreter_StackOverflow(t *testing.T) {
+	interp := compile(t, `fun f(n) {
+  return f(n + 1);
+}
+f(0);`).NewInterpreter()
+	interp.SetMaxStackDepth(100)
+
+	err := interp.Interpret(context.Background())
+	re, ok := err.(*RuntimeError)
+	if !ok {
+		t.Fatalf("expected a *RuntimeError, got=%T (%v)", err, err)
+	}
+	if re.Message != "Stack overflow." {
+		t.Errorf("unexpected message. expected=%q, got=%q", "Stack overflow.", re.Message)
+	}
+	if re.Token.Line != 2 {
+		t.Errorf("unexpected line. expected=2, got=%d", re.Token.Line)
+	}
+}
+
+func TestInterpreter_MemoryLimitError(t *testing.T) {
+	interp := compile(t, `var s = "abcdefgh"; while (true) s = s + s;`).NewInterpreter()
+	interp.SetLimits(Limits{MaxMemory: 1 << 16})
+
+	err := interp.Interpret(context.Background())
+	if !errors.Is(err, ErrMemoryLimit) {
+		t.Errorf("expected memory limit error, got=%v", err)
+	}
+}
@@ -0,0 +1,38 @@
+package interpreter
+
+import (
+	"fmt"
+
+	"github.com/butlermatt/glox/lexer"
+)
+
+func NewArray(elements []interface{}) *LoxArray {
+	return &LoxArray{Elements: elements}
+}
+
+type LoxArray struct {
+	Elements []interface{}
+	frozen   bool
+}
+
+func (la *LoxArray) String() string {
+	return fmt.Sprintf("%v", la.Elements)
+}
+
+func (la *LoxArray) Get(token *lexer.Token, index int) (interface{}, error) {
+	if index < 0 || index >= len(la.Elements) {
+		return nil, newError(token, "Index out of range.")
+	}
+	return la.Elements[index], nil
+}
+
+func (la *LoxArray) Set(token *lexer.Token, index int, value interface{}) error {
+	if la.frozen {
+		return newError(token, "Cannot modify a frozen array.")
+	}
+	if index < 0 || index >= len(la.Elements) {
+		return newError(token, "Index out of range.")
+	}
+	la.Elements[index] = value
+	return nil
+}
@@ -17,6 +17,9 @@ func (lc *LoxClass) String() string {
 }
 
 func (lc *LoxClass) Call(interpreter *Interpreter, args []interface{}) (interface{}, error) {
+	if err := interpreter.alloc(sizeInstance); err != nil {
+		return nil, err
+	}
 	instance := &LoxInstance{klass: lc, fields: make(map[string]interface{})}
 	initializer := lc.methods["init"]
 	if initializer != nil {
@@ -36,6 +39,14 @@ func (lc *LoxClass) Arity() int {
 	return init.Arity()
 }
 
+func (lc *LoxClass) MaxArity() int {
+	init := lc.methods["init"]
+	if init == nil {
+		return 0
+	}
+	return init.MaxArity()
+}
+
 func (lc *LoxClass) findMethod(instance *LoxInstance, name string) *Function {
 	method := lc.methods[name]
 	if method != nil {
@@ -52,6 +63,7 @@ func (lc *LoxClass) findMethod(instance *LoxInstance, name string) *Function {
 type LoxInstance struct {
 	klass  *LoxClass
 	fields map[string]interface{}
+	frozen bool
 }
 
 func (li *LoxInstance) String() string {
@@ -70,6 +82,10 @@ func (li *LoxInstance) Get(name *lexer.Token) (interface{}, error) {
 	return nil, newError(name, "Undefined property '"+name.Lexeme+"'.")
 }
 
-func (li *LoxInstance) Set(name *lexer.Token, value interface{}) {
+func (li *LoxInstance) Set(name *lexer.Token, value interface{}) error {
+	if li.frozen {
+		return newError(name, "Cannot modify a frozen instance.")
+	}
 	li.fields[name.Lexeme] = value
+	return nil
 }
@@ -0,0 +1,221 @@
+package interpreter
+
+import "github.com/butlermatt/glox/parser"
+
+func (i *Interpreter) VisitMatchStmt(stmt *parser.MatchStmt) error {
+	subject, err := i.evaluate(stmt.Subject)
+	if err != nil {
+		return err
+	}
+
+	// The default case is only taken once every other case has failed to match.
+	var def *parser.CaseStmt
+	for _, c := range stmt.Cases {
+		if len(c.Patterns) == 0 {
+			def = c
+			continue
+		}
+
+		env := NewEnclosedEnvironment(i.environment)
+		matched, err := i.matchCase(c, subject, env)
+		if err != nil {
+			return err
+		}
+		if matched {
+			return i.executeCase(c, env)
+		}
+	}
+
+	if def != nil {
+		return i.executeCase(def, NewEnclosedEnvironment(i.environment))
+	}
+	return nil
+}
+
+func (i *Interpreter) VisitCaseStmt(stmt *parser.CaseStmt) error {
+	for _, s := range stmt.Body {
+		if err := i.execute(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// executeCase runs the body of a matched case. A 'break' only leaves the match statement.
+func (i *Interpreter) executeCase(c *parser.CaseStmt, env *Environment) error {
+	prev := i.environment
+	i.environment = env
+	err := i.execute(c)
+	i.environment = prev
+
+	if err == BreakError {
+		return nil
+	}
+	return err
+}
+
+func (i *Interpreter) matchCase(c *parser.CaseStmt, subject interface{}, env *Environment) (bool, error) {
+	for _, pat := range c.Patterns {
+		matched, err := i.matchPattern(pat, subject, env)
+		if err != nil || matched {
+			return matched, err
+		}
+	}
+	return false, nil
+}
+
+// matchPattern reports if value matches pattern, defining any bound names in env.
+func (i *Interpreter) matchPattern(pattern parser.Expr, value interface{}, env *Environment) (bool, error) {
+	switch pat := pattern.(type) {
+	case *parser.LiteralExpr:
+		return isEqual(value, pat.Value)
+	case *parser.UnaryExpr:
+		lit := pat.Right.(*parser.LiteralExpr)
+		return isEqual(value, -lit.Value.(float64))
+	case *parser.VariableExpr:
+		if pat.Name.Lexeme != "_" {
+			env.Define(pat.Name, value)
+		}
+		return true, nil
+	case *parser.ArrayExpr:
+		return i.matchArray(pat, value, env)
+	case *parser.CallExpr:
+		return i.matchInstance(pat, value, env)
+	}
+
+	return false, nil
+}
+
+func (i *Interpreter) matchArray(pattern *parser.ArrayExpr, value interface{}, env *Environment) (bool, error) {
+	arr, ok := value.(*LoxArray)
+	if !ok {
+		return false, nil
+	}
+
+	elems := pattern.Values
+	var rest *parser.SpreadExpr
+	if len(elems) > 0 {
+		if s, ok := elems[len(elems)-1].(*parser.SpreadExpr); ok {
+			rest = s
+			elems = elems[:len(elems)-1]
+		}
+	}
+
+	if len(arr.Elements) < len(elems) || (rest == nil && len(arr.Elements) != len(elems)) {
+		return false, nil
+	}
+
+	for ind, elem := range elems {
+		matched, err := i.matchPattern(elem, arr.Elements[ind], env)
+		if err != nil || !matched {
+			return false, err
+		}
+	}
+
+	if rest != nil {
+		tail := make([]interface{}, len(arr.Elements)-len(elems))
+		copy(tail, arr.Elements[len(elems):])
+		return i.matchPattern(rest.Expression, NewArray(tail), env)
+	}
+	return true, nil
+}
+
+func (i *Interpreter) matchInstance(pattern *parser.CallExpr, value interface{}, env *Environment) (bool, error) {
+	callee, err := i.evaluateIn(pattern.Callee, env)
+	if err != nil {
+		return false, err
+	}
+	klass, ok := callee.(*LoxClass)
+	if !ok {
+		return false, newError(pattern.Paren, "Class pattern must name a class.")
+	}
+
+	inst, ok := value.(*LoxInstance)
+	if !ok {
+		return false, nil
+	}
+	c := inst.klass
+	for c != nil && c != klass {
+		c = c.superclass
+	}
+	if c == nil {
+		return false, nil
+	}
+
+	for ind, name := range pattern.Names {
+		field, ok := inst.fields[name.Lexeme]
+		if !ok {
+			return false, nil
+		}
+		matched, err := i.matchPattern(pattern.Named[ind], field, env)
+		if err != nil || !matched {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func (r *Resolver) VisitMatchStmt(stmt *parser.MatchStmt) error {
+	err := r.resolveExpr(stmt.Subject)
+	if err != nil {
+		return err
+	}
+
+	oldMatch := r.inMatch
+	r.inMatch = true
+	for _, c := range stmt.Cases {
+		err = r.resolveStmt(c)
+		if err != nil {
+			break
+		}
+	}
+	r.inMatch = oldMatch
+	return err
+}
+
+func (r *Resolver) VisitCaseStmt(stmt *parser.CaseStmt) error {
+	r.beginScope()
+	defer r.endScope()
+
+	for _, pat := range stmt.Patterns {
+		if err := r.resolvePattern(pat, len(stmt.Patterns) == 1); err != nil {
+			return err
+		}
+	}
+	return r.Resolve(stmt.Body)
+}
+
+// resolvePattern declares the names bound by pattern in the current scope.
+func (r *Resolver) resolvePattern(pattern parser.Expr, canBind bool) error {
+	switch pat := pattern.(type) {
+	case *parser.VariableExpr:
+		if pat.Name.Lexeme == "_" {
+			return nil
+		}
+		if !canBind {
+			return newError(pat.Name, "Cannot bind names in a case with alternative patterns.")
+		}
+		if err := r.declare(pat.Name); err != nil {
+			return err
+		}
+		r.define(pat.Name)
+	case *parser.ArrayExpr:
+		for _, elem := range pat.Values {
+			if err := r.resolvePattern(elem, canBind); err != nil {
+				return err
+			}
+		}
+	case *parser.SpreadExpr:
+		return r.resolvePattern(pat.Expression, canBind)
+	case *parser.CallExpr:
+		if err := r.resolveExpr(pat.Callee); err != nil {
+			return err
+		}
+		for _, field := range pat.Named {
+			if err := r.resolvePattern(field, canBind); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
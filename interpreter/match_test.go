@@ -0,0 +1,97 @@
+package interpreter
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			"literals",
+			`fun f(v) { match (v) { case 1, 2: print "small"; case "a": print "string"; case -3: print "negative"; case true: print "true"; case null: print "null"; default: print "other"; } }
+f(1); f(2); f("a"); f(-3); f(true); f(null); f(4); f(false);`,
+			"small\nsmall\nstring\nnegative\ntrue\nnull\nother\nother\n",
+		},
+		{
+			"arrays",
+			`fun f(v) { match (v) { case []: print "empty"; case [x]: print "one " + x; case [1, y]: print "pair " + y; case [first, ...rest]: print first; print rest; } }
+f([]); f(["a"]); f([1, "b"]); f([2, 3, 4]); f("no");`,
+			"empty\none a\npair b\n2\n[3 4]\n",
+		},
+		{
+			"nested arrays",
+			`match ([[1, 2], 3]) { case [[a, b], c]: print a + b + c; }`,
+			"6\n",
+		},
+		{
+			"class patterns",
+			`class Point { init(x, y) { this.x = x; this.y = y; } }
+class Point3 < Point { init(x, y, z) { super.init(x, y); this.z = z; } }
+class Other {}
+fun f(v) { match (v) { case Point3(z: 0): print "flat"; case Point(x: 0, y): print y; case Point(x, y: [a, _]): print x + a; case Point(): print "point"; case Other(): print "other"; default: print "none"; } }
+f(Point3(1, 2, 0)); f(Point(0, 5)); f(Point3(0, 6, 1)); f(Point(1, [2, 3])); f(Point(1, 2)); f(Other()); f(1);`,
+			"flat\n5\n6\n3\npoint\nother\nnone\n",
+		},
+		{
+			"missing fields don't match",
+			`class P {} match (P()) { case P(x): print x; default: print "no x"; }`,
+			"no x\n",
+		},
+		{
+			"bindings are scoped to their case",
+			`var x = "outer"; match ([1]) { case [x]: print x; } print x;`,
+			"1\nouter\n",
+		},
+		{
+			"wildcard",
+			`match ([1, 2]) { case [_, _]: print "two"; }`,
+			"two\n",
+		},
+		{
+			"no case matches",
+			`match (3) { case 1: print "one"; case [a]: print a; } print "after";`,
+			"after\n",
+		},
+		{
+			"default is taken last",
+			`match (1) { default: print "default"; case 1: print "one"; }`,
+			"one\n",
+		},
+		{
+			"break leaves the match",
+			`for (var i = 0; i < 2; i = i + 1) { match (i) { case 0: print "zero"; break; print "skipped"; default: print i; } }`,
+			"zero\n1\n",
+		},
+		{
+			"return from a case",
+			`fun f(v) { match (v) { case [a]: return a; } return "none"; } print f([7]); print f(7);`,
+			"7\nnone\n",
+		},
+	}
+
+	for _, tt := range tests {
+		out, err := run(t, tt.input)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+		}
+		if out != tt.expected {
+			t.Errorf("%s: expected output %q, got %q", tt.name, tt.expected, out)
+		}
+	}
+}
+
+func TestMatch_Errors(t *testing.T) {
+	_, err := run(t, `var NotClass = 1; match (1) { case NotClass(): print "no"; }`)
+	if _, ok := err.(*RuntimeError); !ok || !strings.Contains(err.Error(), "Class pattern must name a class.") {
+		t.Errorf("expected a runtime error for a class pattern naming a number, got %v", err)
+	}
+
+	if err := compileError(t, `match (1) { case [a, a]: print a; }`); err == nil {
+		t.Errorf("expected an error binding a name twice in one pattern")
+	}
+}
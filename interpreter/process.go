@@ -0,0 +1,51 @@
+package interpreter
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"os"
+	"strconv"
+)
+
+// stdin is shared by every interpreter reading os.Stdin, so that no input is lost in the buffer
+// of one run when the next starts.
+var stdin = bufio.NewReader(os.Stdin)
+
+// ExitError is returned by Interpret when a script calls exit(code).
+type ExitError struct {
+	Code int
+}
+
+func (ee *ExitError) Error() string {
+	return "exit status " + strconv.Itoa(ee.Code)
+}
+
+// SetArgs sets the global args array seen by the script.
+func (i *Interpreter) SetArgs(args []string) {
+	values := make([]interface{}, len(args))
+	for n, a := range args {
+		values[n] = a
+	}
+	i.globals.m["args"] = NewArray(values)
+}
+
+// SetInput sets the reader used by input() and readLine(), which is os.Stdin by default.
+func (i *Interpreter) SetInput(r io.Reader) {
+	i.stdin = bufio.NewReader(r)
+}
+
+// SetOutput sets the writer used by print, write() and the prompt of input(), which is os.Stdout
+// by default.
+func (i *Interpreter) SetOutput(w io.Writer) {
+	i.stdout = w
+}
+
+// builtinExit stops the script, returning an *ExitError with the given status from Interpret.
+func builtinExit(interp *Interpreter, args []interface{}) (interface{}, error) {
+	code, ok := args[0].(float64)
+	if !ok || code != math.Trunc(code) || code < 0 || code > 255 {
+		return nil, newError(nil, "exit() expects an integer status code from 0 to 255.")
+	}
+	return nil, &ExitError{Code: int(code)}
+}
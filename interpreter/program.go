@@ -0,0 +1,49 @@
+package interpreter
+
+import (
+	"context"
+	"os"
+	"sync"
+
+	"github.com/butlermatt/glox/lexer"
+	"github.com/butlermatt/glox/parser"
+)
+
+// Program is a parsed and resolved script. It is never modified once compiled, so a single
+// Program may be run any number of times, including concurrently from multiple goroutines.
+// Each run gets its own Interpreter with independent globals.
+type Program struct {
+	stmts  []parser.Stmt
+	locals map[parser.Expr]int
+}
+
+// Compile resolves the variables of the parsed statements, returning the resulting Program.
+func Compile(statements []parser.Stmt) (*Program, error) {
+	locals := make(map[parser.Expr]int)
+	if err := newResolver(locals).Resolve(statements); err != nil {
+		return nil, err
+	}
+	return &Program{stmts: statements, locals: locals}, nil
+}
+
+// Definitions resolves statements as Compile does, returning for each local variable name the
+// token which declared it. A declaration maps to itself, and names absent from the map are
+// globals. The definitions found before any error are returned along with it.
+func Definitions(statements []parser.Stmt) (map[*lexer.Token]*lexer.Token, error) {
+	r := newResolver(make(map[parser.Expr]int))
+	r.defs = make(map[*lexer.Token]*lexer.Token)
+	err := r.Resolve(statements)
+	return r.defs, err
+}
+
+// NewInterpreter returns an Interpreter which will execute p with a fresh set of globals,
+// containing the core built-ins and those of the granted capabilities.
+func (p *Program) NewInterpreter(caps ...Capability) *Interpreter {
+	env := newGlobals(caps...)
+	return &Interpreter{stmts: p.stmts, globals: env, environment: env, locals: p.locals, gil: &sync.Mutex{}, maxDepth: DefaultMaxStackDepth, stdin: stdin, stdout: os.Stdout}
+}
+
+// Run executes p in a new Interpreter with the granted capabilities and no limits.
+func (p *Program) Run(ctx context.Context, caps ...Capability) error {
+	return p.NewInterpreter(caps...).Interpret(ctx)
+}
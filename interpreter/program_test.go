@@ -0,0 +1,184 @@
+package interpreter
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/butlermatt/glox/lexer"
+	"github.com/butlermatt/glox/parser"
+)
+
+func compile(t *testing.T, input string) *Program {
+	t.Helper()
+	p := parser.New(lexer.New(input))
+	stmts := p.Parse()
+	if errs := p.Errors(); len(errs) > 0 {
+		t.Fatalf("unexpected parse errors: %+v", errs)
+	}
+
+	prog, err := Compile(stmts)
+	if err != nil {
+		t.Fatalf("unexpected compile error: %v", err)
+	}
+	return prog
+}
+
+// compileError compiles input, which must parse, and returns the resolver's error.
+func compileError(t *testing.T, input string) error {
+	t.Helper()
+	p := parser.New(lexer.New(input))
+	stmts := p.Parse()
+	if errs := p.Errors(); len(errs) > 0 {
+		t.Fatalf("unexpected parse errors: %+v", errs)
+	}
+	_, err := Compile(stmts)
+	return err
+}
+
+// run compiles and runs input with every capability, returning what it printed.
+func run(t *testing.T, input string) (string, error) {
+	t.Helper()
+	interp := compile(t, input).NewInterpreter(AllCapabilities...)
+	var out bytes.Buffer
+	interp.SetOutput(&out)
+	err := interp.Interpret(context.Background())
+	return out.String(), err
+}
+
+func TestProgram_ConcurrentRuns(t *testing.T) {
+	prog := compile(t, `
+fun fib(n) {
+  if (n < 2) return n;
+  return fib(n - 1) + fib(n - 2);
+}
+var counter = 0;
+for (var i = 0; i < 10; i = i + 1) counter = counter + 1;
+var result = fib(15) + counter;
+`)
+
+	var wg sync.WaitGroup
+	results := make([]interface{}, 8)
+	errs := make([]error, len(results))
+	for n := range results {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			interp := prog.NewInterpreter()
+			errs[n] = interp.Interpret(context.Background())
+			results[n], _ = interp.Global("result")
+		}(n)
+	}
+	wg.Wait()
+
+	for n, res := range results {
+		if errs[n] != nil {
+			t.Errorf("run %d: unexpected error: %v", n, errs[n])
+		}
+		if res != float64(620) {
+			t.Errorf("run %d: unexpected result. expected=%v, got=%v", n, 620, res)
+		}
+	}
+}
+
+func TestProgram_IndependentGlobals(t *testing.T) {
+	prog := compile(t, `var x = 1;`)
+
+	first := prog.NewInterpreter()
+	if err := first.Interpret(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	first.globals.m["x"] = 2.0
+
+	second := prog.NewInterpreter()
+	if err := second.Interpret(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if x, _ := second.Global("x"); x != 1.0 {
+		t.Errorf("globals leaked between runs. expected=1, got=%v", x)
+	}
+}
+
+func TestInterpreter_ArgsInputExit(t *testing.T) {
+	prog := compile(t, `
+var first = args[0];
+var count = 0;
+var lines = "";
+var line;
+while ((line = readLine()) != null) {
+  lines = lines + line + ";";
+  count = count + 1;
+}
+exit(count);
+var unreachable = true;
+`)
+
+	interp := prog.NewInterpreter(CapIO)
+	interp.SetArgs([]string{"a", "b"})
+	interp.SetInput(strings.NewReader("one\r\ntwo\nthree"))
+	err := interp.Interpret(context.Background())
+
+	ee, ok := err.(*ExitError)
+	if !ok {
+		t.Fatalf("expected an *ExitError, got=%v", err)
+	}
+	if ee.Code != 3 {
+		t.Errorf("unexpected exit code. expected=3, got=%d", ee.Code)
+	}
+	if v, _ := interp.Global("first"); v != "a" {
+		t.Errorf("unexpected first argument. expected=%q, got=%v", "a", v)
+	}
+	if v, _ := interp.Global("lines"); v != "one;two;three;" {
+		t.Errorf("unexpected lines. expected=%q, got=%v", "one;two;three;", v)
+	}
+	if _, ok := interp.Global("unreachable"); ok {
+		t.Errorf("expected exit to stop the script")
+	}
+}
+
+func TestInterpreter_ExitCodes(t *testing.T) {
+	for _, code := range []float64{0, 1, 255} {
+		_, err := builtinExit(nil, []interface{}{code})
+		if ee, ok := err.(*ExitError); !ok || ee.Code != int(code) {
+			t.Errorf("exit(%v): expected an *ExitError with code %v, got %v", code, code, err)
+		}
+	}
+
+	// NaN cannot be written in a script, so the built-in is called directly.
+	for _, arg := range []interface{}{math.NaN(), math.Inf(1), 1.5, -1.0, 256.0, "1", nil} {
+		_, err := builtinExit(nil, []interface{}{arg})
+		if re, ok := err.(*RuntimeError); !ok || re.Message != "exit() expects an integer status code from 0 to 255." {
+			t.Errorf("exit(%v): expected a runtime error, got %v", arg, err)
+		}
+	}
+}
+
+func TestCompile_DecodedJSON(t *testing.T) {
+	p := parser.New(lexer.New(`
+fun add(a, b = 2) { return a + b; }
+var result = add(1);
+`))
+	data, err := parser.EncodeJSON(p.Parse())
+	if err != nil {
+		t.Fatalf("unexpected encoding error: %v", err)
+	}
+	stmts, err := parser.DecodeJSON(data)
+	if err != nil {
+		t.Fatalf("unexpected decoding error: %v", err)
+	}
+
+	prog, err := Compile(stmts)
+	if err != nil {
+		t.Fatalf("unexpected compile error: %v", err)
+	}
+	interp := prog.NewInterpreter()
+	if err := interp.Interpret(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if v, _ := interp.Global("result"); v != float64(3) {
+		t.Errorf("unexpected result. expected=3, got=%v", v)
+	}
+}
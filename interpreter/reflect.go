@@ -0,0 +1,212 @@
+package interpreter
+
+import "sort"
+
+// defineReflection adds the introspection built-ins to the provided environment.
+func defineReflection(env *Environment) {
+	env.builtin("type", &BuiltIn{arity: 1, callFn: builtinType})
+	env.builtin("classOf", &BuiltIn{arity: 1, callFn: builtinClassOf})
+	env.builtin("isinstance", &BuiltIn{arity: 2, callFn: builtinIsInstance})
+	env.builtin("fields", &BuiltIn{arity: 1, callFn: builtinFields})
+	env.builtin("methods", &BuiltIn{arity: 1, callFn: builtinMethods})
+	env.builtin("hasField", &BuiltIn{arity: 2, callFn: builtinHasField})
+	env.builtin("getField", &BuiltIn{arity: 2, callFn: builtinGetField})
+	env.builtin("setField", &BuiltIn{arity: 3, callFn: builtinSetField})
+	env.builtin("superclass", &BuiltIn{arity: 1, callFn: builtinSuperclass})
+	env.builtin("arity", &BuiltIn{arity: 1, callFn: builtinArity})
+}
+
+// typeName returns the name of the Lox type of value.
+func typeName(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case *LoxArray:
+		return "array"
+	case *LoxClass:
+		return "class"
+	case *LoxInstance:
+		return "instance"
+	case *Generator:
+		return "generator"
+	case *Task:
+		return "task"
+	case *Channel:
+		return "channel"
+	case Callable:
+		return "function"
+	}
+	return "unknown"
+}
+
+func builtinType(interp *Interpreter, args []interface{}) (interface{}, error) {
+	return typeName(args[0]), nil
+}
+
+func builtinClassOf(interp *Interpreter, args []interface{}) (interface{}, error) {
+	if inst, ok := args[0].(*LoxInstance); ok {
+		return inst.klass, nil
+	}
+	return nil, nil
+}
+
+func builtinIsInstance(interp *Interpreter, args []interface{}) (interface{}, error) {
+	klass, err := checkClassArg(args[1], "isinstance")
+	if err != nil {
+		return nil, err
+	}
+	inst, ok := args[0].(*LoxInstance)
+	if !ok {
+		return false, nil
+	}
+
+	for c := inst.klass; c != nil; c = c.superclass {
+		if c == klass {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func builtinFields(interp *Interpreter, args []interface{}) (interface{}, error) {
+	inst, err := checkInstanceArg(args[0], "fields")
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for name := range inst.fields {
+		names = append(names, name)
+	}
+	return sortedNames(names), nil
+}
+
+func builtinMethods(interp *Interpreter, args []interface{}) (interface{}, error) {
+	klass, err := checkClassArg(args[0], "methods")
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	var names []string
+	for c := klass; c != nil; c = c.superclass {
+		for name := range c.methods {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	return sortedNames(names), nil
+}
+
+func builtinHasField(interp *Interpreter, args []interface{}) (interface{}, error) {
+	inst, err := checkInstanceArg(args[0], "hasField")
+	if err != nil {
+		return nil, err
+	}
+	name, err := checkStringArg(args[1], "hasField")
+	if err != nil {
+		return nil, err
+	}
+
+	_, ok := inst.fields[name]
+	return ok, nil
+}
+
+func builtinGetField(interp *Interpreter, args []interface{}) (interface{}, error) {
+	inst, err := checkInstanceArg(args[0], "getField")
+	if err != nil {
+		return nil, err
+	}
+	name, err := checkStringArg(args[1], "getField")
+	if err != nil {
+		return nil, err
+	}
+
+	v, ok := inst.fields[name]
+	if !ok {
+		return nil, newError(nil, "Undefined field '"+name+"'.")
+	}
+	return v, nil
+}
+
+func builtinSetField(interp *Interpreter, args []interface{}) (interface{}, error) {
+	inst, err := checkInstanceArg(args[0], "setField")
+	if err != nil {
+		return nil, err
+	}
+	name, err := checkStringArg(args[1], "setField")
+	if err != nil {
+		return nil, err
+	}
+
+	if inst.frozen {
+		return nil, newError(nil, "Cannot modify a frozen instance.")
+	}
+	if _, ok := inst.fields[name]; !ok {
+		if err := interp.alloc(sizeField); err != nil {
+			return nil, err
+		}
+	}
+	inst.fields[name] = args[2]
+	return args[2], nil
+}
+
+func builtinSuperclass(interp *Interpreter, args []interface{}) (interface{}, error) {
+	klass, err := checkClassArg(args[0], "superclass")
+	if err != nil {
+		return nil, err
+	}
+	if klass.superclass == nil {
+		return nil, nil
+	}
+	return klass.superclass, nil
+}
+
+func builtinArity(interp *Interpreter, args []interface{}) (interface{}, error) {
+	fn, ok := args[0].(Callable)
+	if !ok {
+		return nil, newError(nil, "arity() expects a function or class.")
+	}
+	return float64(fn.Arity()), nil
+}
+
+func checkInstanceArg(arg interface{}, fnName string) (*LoxInstance, error) {
+	inst, ok := arg.(*LoxInstance)
+	if !ok {
+		return nil, newError(nil, fnName+"() expects an instance.")
+	}
+	return inst, nil
+}
+
+func checkClassArg(arg interface{}, fnName string) (*LoxClass, error) {
+	klass, ok := arg.(*LoxClass)
+	if !ok {
+		return nil, newError(nil, fnName+"() expects a class.")
+	}
+	return klass, nil
+}
+
+func checkStringArg(arg interface{}, fnName string) (string, error) {
+	s, ok := arg.(string)
+	if !ok {
+		return "", newError(nil, fnName+"() expects a string name.")
+	}
+	return s, nil
+}
+
+func sortedNames(names []string) *LoxArray {
+	sort.Strings(names)
+	values := make([]interface{}, len(names))
+	for i, n := range names {
+		values[i] = n
+	}
+	return NewArray(values)
+}
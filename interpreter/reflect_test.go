@@ -0,0 +1,73 @@
+package interpreter
+
+import (
+	"strings"
+	"testing"
+)
+
+const reflectClasses = `
+class Shape { area() { return 0; } describe() { return "shape"; } }
+class Square < Shape {
+  init(side) { this.side = side; }
+  area() { return this.side * this.side; }
+}
+class Other {}
+var sq = Square(3);
+`
+
+func TestReflection(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`print type(null); print type(true); print type(1); print type("s"); print type([]);`, "null\nboolean\nnumber\nstring\narray\n"},
+		{`print type(Square); print type(sq); print type(clock); print type(sq.area);`, "class\ninstance\nfunction\nfunction\n"},
+		{`fun* g() {} print type(g()); print type(chan());`, "generator\nchannel\n"},
+		{`print classOf(sq); print classOf(1); print classOf(Square);`, "Square\nnull\nnull\n"},
+		{`print isinstance(sq, Square); print isinstance(sq, Shape); print isinstance(sq, Other); print isinstance(1, Shape);`, "true\ntrue\nfalse\nfalse\n"},
+		{`sq.color = "red"; print fields(sq); print fields(Other());`, "[color side]\n[]\n"},
+		{`print methods(Square); print methods(Other);`, "[area describe init]\n[]\n"},
+		{`print hasField(sq, "side"); print hasField(sq, "area");`, "true\nfalse\n"},
+		{`print getField(sq, "side");`, "3\n"},
+		{`print setField(sq, "side", 4); print sq.area(); setField(sq, "new", 1); print sq.new;`, "4\n16\n1\n"},
+		{`print superclass(Square); print superclass(Shape);`, "Shape\nnull\n"},
+		{`fun f(a, b = 1, ...c) {} print arity(f); print arity(Square); print arity(Other); print arity(sq.area); print arity(clock);`, "1\n1\n0\n0\n0\n"},
+	}
+
+	for _, tt := range tests {
+		out, err := run(t, reflectClasses+tt.input)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.input, err)
+		}
+		if out != tt.expected {
+			t.Errorf("%s: expected output %q, got %q", tt.input, tt.expected, out)
+		}
+	}
+}
+
+func TestReflection_Errors(t *testing.T) {
+	tests := []struct {
+		input   string
+		message string
+	}{
+		{`isinstance(sq, sq);`, "isinstance() expects a class."},
+		{`fields(Square);`, "fields() expects an instance."},
+		{`methods(sq);`, "methods() expects a class."},
+		{`hasField(1, "a");`, "hasField() expects an instance."},
+		{`hasField(sq, 1);`, "hasField() expects a string name."},
+		{`getField(sq, "missing");`, "Undefined field 'missing'."},
+		{`getField(sq, null);`, "getField() expects a string name."},
+		{`setField(Square, "a", 1);`, "setField() expects an instance."},
+		{`setField(freeze(sq), "side", 1);`, "Cannot modify a frozen instance."},
+		{`superclass(sq);`, "superclass() expects a class."},
+		{`arity(1);`, "arity() expects a function or class."},
+		{`type();`, "Expected 1 arguments but got 0."},
+	}
+
+	for _, tt := range tests {
+		_, err := run(t, reflectClasses+tt.input)
+		if _, ok := err.(*RuntimeError); !ok || !strings.Contains(err.Error(), tt.message) {
+			t.Errorf("%s: expected a runtime error containing %q, got %v", tt.input, tt.message, err)
+		}
+	}
+}
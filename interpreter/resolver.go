@@ -22,19 +22,33 @@ const (
 )
 
 func NewResolver(interpreter *Interpreter) *Resolver {
-	return &Resolver{interpreter: interpreter, curFunc: NoneFT}
+	return newResolver(interpreter.locals)
+}
+
+// newResolver returns a Resolver which records the scope depth of local variables in locals.
+func newResolver(locals map[parser.Expr]int) *Resolver {
+	return &Resolver{locals: locals, curFunc: NoneFT, globalConsts: make(map[string]bool)}
 }
 
 type Resolver struct {
-	interpreter *Interpreter
-	stack       []map[string]bool
-	curFunc     FunctionType
-	curClass    ClassType
-	inLoop      bool
+	locals       map[parser.Expr]int
+	stack        []map[string]bool
+	consts       []map[string]bool         // constants declared in the matching scope of stack
+	decls        []map[string]*lexer.Token // declarations in the matching scope of stack
+	defs         map[*lexer.Token]*lexer.Token
+	globalConsts map[string]bool
+	curFunc      FunctionType
+	curClass     ClassType
+	inLoop       bool
+	inMatch      bool
+	labels       []string
+	inGenerator  bool
 }
 
 func (r *Resolver) beginScope() {
 	r.stack = append(r.stack, make(map[string]bool))
+	r.consts = append(r.consts, make(map[string]bool))
+	r.decls = append(r.decls, make(map[string]*lexer.Token))
 }
 
 func (r *Resolver) endScope() {
@@ -42,6 +56,8 @@ func (r *Resolver) endScope() {
 		return
 	}
 	r.stack = r.stack[:len(r.stack)-1]
+	r.consts = r.consts[:len(r.consts)-1]
+	r.decls = r.decls[:len(r.decls)-1]
 }
 
 func (r *Resolver) peekScope() map[string]bool {
@@ -60,6 +76,9 @@ func (r *Resolver) VisitBlockStmt(stmt *parser.BlockStmt) error {
 }
 
 func (r *Resolver) VisitClassStmt(stmt *parser.ClassStmt) error {
+	if err := r.redeclares(stmt.Name); err != nil {
+		return err
+	}
 	r.declare(stmt.Name)
 	r.define(stmt.Name)
 
@@ -123,6 +142,9 @@ func (r *Resolver) VisitIfStmt(stmt *parser.IfStmt) error {
 }
 
 func (r *Resolver) VisitFunctionStmt(stmt *parser.FunctionStmt) error {
+	if err := r.redeclares(stmt.Name); err != nil {
+		return err
+	}
 	r.declare(stmt.Name)
 	r.define(stmt.Name)
 
@@ -142,6 +164,9 @@ func (r *Resolver) VisitReturnStmt(stmt *parser.ReturnStmt) error {
 		if r.curFunc == InitializerFT {
 			return newError(stmt.Keyword, "Cannot return a value from an initializer.")
 		}
+		if r.inGenerator {
+			return newError(stmt.Keyword, "Cannot return a value from a generator.")
+		}
 		return r.resolveExpr(stmt.Value)
 	}
 	return nil
@@ -160,7 +185,7 @@ func (r *Resolver) VisitForStmt(stmt *parser.ForStmt) error {
 
 	oldLoop := r.inLoop
 	r.inLoop = true
-	err = r.resolveStmt(stmt.Body)
+	err = r.resolveLoopBody(stmt.Label, stmt.Body)
 	if err != nil {
 		r.inLoop = oldLoop
 		return err
@@ -172,6 +197,9 @@ func (r *Resolver) VisitForStmt(stmt *parser.ForStmt) error {
 }
 
 func (r *Resolver) VisitVarStmt(stmt *parser.VarStmt) error {
+	if err := r.redeclares(stmt.Name); err != nil {
+		return err
+	}
 	r.declare(stmt.Name)
 	if stmt.Initializer != nil {
 		err := r.resolveExpr(stmt.Initializer)
@@ -180,17 +208,54 @@ func (r *Resolver) VisitVarStmt(stmt *parser.VarStmt) error {
 		}
 	}
 	r.define(stmt.Name)
+
+	if len(r.stack) == 0 {
+		// Globals other than constants may be redeclared, so remember the latest declaration.
+		r.globalConsts[stmt.Name.Lexeme] = stmt.Constant
+	} else if stmt.Constant {
+		r.consts[len(r.consts)-1][stmt.Name.Lexeme] = true
+	}
 	return nil
 }
 
+// resolveLoopBody resolves the body of a loop, making its label (if any) available to it.
+func (r *Resolver) resolveLoopBody(label *lexer.Token, body parser.Stmt) error {
+	if label == nil {
+		return r.resolveStmt(body)
+	}
+
+	if r.hasLabel(label) {
+		return newError(label, "Label '"+label.Lexeme+"' is already in use by an enclosing loop.")
+	}
+	r.labels = append(r.labels, label.Lexeme)
+	err := r.resolveStmt(body)
+	r.labels = r.labels[:len(r.labels)-1]
+	return err
+}
+
+func (r *Resolver) hasLabel(label *lexer.Token) bool {
+	for _, l := range r.labels {
+		if l == label.Lexeme {
+			return true
+		}
+	}
+	return false
+}
+
 func (r *Resolver) VisitBreakStmt(stmt *parser.BreakStmt) error {
-	if !r.inLoop {
-		return newError(stmt.Keyword, "Cannot break when not in loop.")
+	if stmt.Label != nil && !r.hasLabel(stmt.Label) {
+		return newError(stmt.Label, "Undefined label '"+stmt.Label.Lexeme+"'.")
+	}
+	if !r.inLoop && !r.inMatch {
+		return newError(stmt.Keyword, "Cannot break when not in loop or match.")
 	}
 	return nil
 }
 
 func (r *Resolver) VisitContinueStmt(stmt *parser.ContinueStmt) error {
+	if stmt.Label != nil && !r.hasLabel(stmt.Label) {
+		return newError(stmt.Label, "Undefined label '"+stmt.Label.Lexeme+"'.")
+	}
 	if !r.inLoop {
 		return newError(stmt.Keyword, "Cannot continue when not in loop.")
 	}
@@ -212,10 +277,23 @@ func (r *Resolver) VisitAssignExpr(expr *parser.AssignExpr) (interface{}, error)
 	if err != nil {
 		return nil, err
 	}
+	if r.isConstant(expr.Name) {
+		return nil, newError(expr.Name, "Cannot assign to constant '"+expr.Name.Lexeme+"'.")
+	}
 	r.resolveLocal(expr, expr.Name)
 	return nil, nil
 }
 
+// isConstant reports whether name resolves to a variable declared with 'const'.
+func (r *Resolver) isConstant(name *lexer.Token) bool {
+	for i := len(r.stack) - 1; i >= 0; i-- {
+		if _, ok := r.stack[i][name.Lexeme]; ok {
+			return r.consts[i][name.Lexeme]
+		}
+	}
+	return r.globalConsts[name.Lexeme]
+}
+
 func (r *Resolver) VisitBinaryExpr(expr *parser.BinaryExpr) (interface{}, error) {
 	err := r.resolveExpr(expr.Left)
 	if err != nil {
@@ -239,6 +317,12 @@ func (r *Resolver) VisitCallExpr(expr *parser.CallExpr) (interface{}, error) {
 			return nil, err
 		}
 	}
+	for _, e := range expr.Named {
+		err = r.resolveExpr(e)
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	return nil, nil
 }
@@ -289,6 +373,10 @@ func (r *Resolver) VisitSetExpr(expr *parser.SetExpr) (interface{}, error) {
 	return nil, err
 }
 
+func (r *Resolver) VisitSpreadExpr(expr *parser.SpreadExpr) (interface{}, error) {
+	return nil, r.resolveExpr(expr.Expression)
+}
+
 func (r *Resolver) VisitSuperExpr(expr *parser.SuperExpr) (interface{}, error) {
 	if r.curClass == NoneCT {
 		return nil, newError(expr.Keyword, "Cannot use 'super' outside of a class.")
@@ -334,10 +422,16 @@ func (r *Resolver) Resolve(stmts []parser.Stmt) error {
 }
 
 func (r *Resolver) resolveStmt(stmt parser.Stmt) error {
+	if stmt == nil {
+		return nil // Optional clauses, such as a for loop initializer
+	}
 	return stmt.Accept(r)
 }
 
 func (r *Resolver) resolveExpr(expr parser.Expr) error {
+	if expr == nil {
+		return nil
+	}
 	_, err := expr.Accept(r)
 	return err
 }
@@ -345,7 +439,10 @@ func (r *Resolver) resolveExpr(expr parser.Expr) error {
 func (r *Resolver) resolveLocal(expr parser.Expr, name *lexer.Token) {
 	for i := len(r.stack) - 1; i >= 0; i-- {
 		if _, ok := r.stack[i][name.Lexeme]; ok {
-			r.interpreter.resolve(expr, len(r.stack)-1-i)
+			r.locals[expr] = len(r.stack) - 1 - i
+			if decl := r.decls[i][name.Lexeme]; decl != nil && r.defs != nil {
+				r.defs[name] = decl
+			}
 			return
 		}
 	}
@@ -355,11 +452,42 @@ func (r *Resolver) resolveLocal(expr parser.Expr, name *lexer.Token) {
 func (r *Resolver) resolveFunction(function *parser.FunctionStmt, fnType FunctionType) error {
 	enclosingFun := r.curFunc
 	r.curFunc = fnType
+	if function.Generator && fnType == InitializerFT {
+		r.curFunc = enclosingFun
+		return newError(function.Name, "Initializer cannot be a generator.")
+	}
+	enclosingGen := r.inGenerator
+	r.inGenerator = function.Generator
+	defer func() { r.inGenerator = enclosingGen }()
+	// Labels, loops and matches cannot be targeted from inside a nested function.
+	enclosingLabels, enclosingLoop, enclosingMatch := r.labels, r.inLoop, r.inMatch
+	r.labels, r.inLoop, r.inMatch = nil, false, false
+	defer func() { r.labels, r.inLoop, r.inMatch = enclosingLabels, enclosingLoop, enclosingMatch }()
 	r.beginScope()
-	for _, param := range function.Parameters {
-		r.declare(param)
+	for i, param := range function.Parameters {
+		// Defaults are evaluated in the function's scope, so they can see earlier parameters.
+		if def := function.Defaults[i]; def != nil {
+			if err := r.resolveExpr(def); err != nil {
+				r.endScope()
+				r.curFunc = enclosingFun
+				return err
+			}
+		}
+		if err := r.declare(param); err != nil {
+			r.endScope()
+			r.curFunc = enclosingFun
+			return err
+		}
 		r.define(param)
 	}
+	if function.Rest != nil {
+		if err := r.declare(function.Rest); err != nil {
+			r.endScope()
+			r.curFunc = enclosingFun
+			return err
+		}
+		r.define(function.Rest)
+	}
 	err := r.Resolve(function.Body)
 	r.endScope()
 	r.curFunc = enclosingFun
@@ -376,6 +504,19 @@ func (r *Resolver) declare(name *lexer.Token) error {
 	}
 
 	scope[name.Lexeme] = false
+	r.decls[len(r.decls)-1][name.Lexeme] = name
+	if r.defs != nil {
+		r.defs[name] = name
+	}
+	return nil
+}
+
+// redeclares returns an error if name declares a global which is already a constant. The
+// interpreter ignores the redeclaration of a global, so the constant would keep its value.
+func (r *Resolver) redeclares(name *lexer.Token) error {
+	if len(r.stack) == 0 && r.globalConsts[name.Lexeme] {
+		return newError(name, "Cannot redeclare constant '"+name.Lexeme+"'.")
+	}
 	return nil
 }
 
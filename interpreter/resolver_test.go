@@ -0,0 +1,79 @@
+package interpreter
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestResolver_LoopControl(t *testing.T) {
+	tests := []struct {
+		input   string
+		message string
+	}{
+		{`break;`, "Cannot break when not in loop or match."},
+		{`continue;`, "Cannot continue when not in loop."},
+		{`match (1) { case 1: continue; }`, "Cannot continue when not in loop."},
+		{`var i = 0; while (i < 5) { fun f() { break; } i = i + 1; f(); }`, "Cannot break when not in loop or match."},
+		{`for (var x in [1]) { fun f() { continue; } }`, "Cannot continue when not in loop."},
+		{`match (1) { case 1: fun f() { break; } }`, "Cannot break when not in loop or match."},
+		{`while (true) { class A { m() { break; } } }`, "Cannot break when not in loop or match."},
+		{`for (;;) break outer;`, "Undefined label 'outer'."},
+		{`outer: for (;;) { fun f() { while (true) continue outer; } }`, "Undefined label 'outer'."},
+		{`outer: for (;;) { outer: for (;;) break; }`, "Label 'outer' is already in use by an enclosing loop."},
+	}
+
+	for _, tt := range tests {
+		if err := compileError(t, tt.input); err == nil || !strings.Contains(err.Error(), tt.message) {
+			t.Errorf("%s: expected an error containing %q, got %v", tt.input, tt.message, err)
+		}
+	}
+
+	for _, tt := range []string{
+		`while (true) { fun f() { while (true) break; } break; }`,
+		`for (var x in [1]) { match (x) { case 1: continue; } }`,
+		`outer: for (;;) { inner: for (;;) { break outer; } }`,
+		`a: for (;;) break; a: for (;;) break;`,
+	} {
+		if err := compileError(t, tt); err != nil {
+			t.Errorf("%s: unexpected error: %v", tt, err)
+		}
+	}
+}
+
+func TestLoops(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"while", `var i = 0; while (i < 3) { print i; i = i + 1; }`, "0\n1\n2\n"},
+		{"for without clauses", `var i = 0; for (;;) { i = i + 1; if (i == 3) break; } print i;`, "3\n"},
+		{"for without a condition", `for (var i = 0;; i = i + 1) { if (i > 1) break; print i; }`, "0\n1\n"},
+		{"continue runs the increment", `for (var i = 0; i < 4; i = i + 1) { if (i == 0 or i == 2) continue; print i; }`, "1\n3\n"},
+		{
+			"labeled break",
+			`outer: for (var i = 0; i < 3; i = i + 1) { for (var j = 0; j < 3; j = j + 1) { if (j == 1) break outer; print i * 10 + j; } } print "done";`,
+			"0\ndone\n",
+		},
+		{
+			"labeled continue",
+			`outer: for (var i = 0; i < 3; i = i + 1) { var j = 0; while (true) { if (j == 1) continue outer; print i * 10 + j; j = j + 1; } }`,
+			"0\n10\n20\n",
+		},
+		{
+			"labeled break from a match",
+			`outer: for (var i = 0; i < 3; i = i + 1) { match (i) { case 1: break outer; default: print i; } }`,
+			"0\n",
+		},
+	}
+
+	for _, tt := range tests {
+		out, err := run(t, tt.input)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+		}
+		if out != tt.expected {
+			t.Errorf("%s: expected output %q, got %q", tt.name, tt.expected, out)
+		}
+	}
+}
@@ -0,0 +1,270 @@
+package interpreter
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"time"
+
+	"github.com/butlermatt/glox/lexer"
+	"github.com/butlermatt/glox/parser"
+)
+
+// Scripts run concurrently by spawning tasks, each of which runs on its own goroutine with its
+// own copy of the interpreter state. Only one task executes Lox code at a time: the interpreter
+// lock is released while a task blocks in a built-in (channel operations, sleep, waiting on
+// another task, host I/O), which is where host work overlaps.
+
+// defineConcurrency adds the task and channel built-ins to the provided environment.
+func defineConcurrency(env *Environment) {
+	env.builtin("chan", &BuiltIn{arity: 0, variadic: true, callFn: builtinChan})
+	env.builtin("send", &BuiltIn{arity: 2, callFn: builtinSend})
+	env.builtin("recv", &BuiltIn{arity: 1, callFn: builtinRecv})
+	env.builtin("close", &BuiltIn{arity: 1, callFn: builtinClose})
+	env.builtin("select", &BuiltIn{arity: 1, variadic: true, callFn: builtinSelect})
+}
+
+// blocking runs fn with the interpreter lock released so other tasks may run.
+func (i *Interpreter) blocking(fn func()) {
+	i.gil.Unlock()
+	defer i.gil.Lock()
+	fn()
+}
+
+// fork returns an interpreter for a new task, sharing the globals, resolved program and budget.
+func (i *Interpreter) fork() *Interpreter {
+	return &Interpreter{globals: i.globals, environment: i.globals, locals: i.locals, gil: i.gil, limits: i.limits, budget: i.budget, maxDepth: i.maxDepth, stdin: i.stdin, stdout: i.stdout}
+}
+
+// Task is the handle returned by spawn.
+type Task struct {
+	name   string
+	done   chan struct{}
+	result interface{}
+	err    error
+}
+
+func (t *Task) String() string { return "<task " + t.name + ">" }
+
+func (t *Task) Get(name *lexer.Token) (interface{}, error) {
+	switch name.Lexeme {
+	case "wait":
+		return &BuiltIn{arity: 0, callFn: func(interp *Interpreter, args []interface{}) (interface{}, error) {
+			var err error
+			done := interp.context().Done()
+			interp.blocking(func() {
+				select {
+				case <-t.done:
+				case <-done:
+					err = ctxLimitError(interp.context())
+				}
+			})
+			if err != nil {
+				return nil, err
+			}
+			return t.result, t.err
+		}}, nil
+	case "done":
+		return &BuiltIn{arity: 0, callFn: func(interp *Interpreter, args []interface{}) (interface{}, error) {
+			select {
+			case <-t.done:
+				return true, nil
+			default:
+				return false, nil
+			}
+		}}, nil
+	}
+
+	return nil, newError(name, "Undefined property '"+name.Lexeme+"'.")
+}
+
+func (i *Interpreter) VisitSpawnExpr(expr *parser.SpawnExpr) (interface{}, error) {
+	function, args, err := i.prepareCall(expr.Call)
+	if err != nil {
+		return nil, err
+	}
+
+	task := &Task{name: stringify(function), done: make(chan struct{})}
+	child := i.fork()
+	if i.budget != nil {
+		i.budget.tasks.Add(1)
+	}
+	go func() {
+		if child.budget != nil {
+			defer child.budget.tasks.Done()
+		}
+		child.gil.Lock()
+		defer child.gil.Unlock()
+		defer close(task.done)
+
+		task.result, task.err = function.Call(child, args)
+		if re, ok := task.err.(*RuntimeError); ok && re.Token == nil {
+			re.Token = expr.Keyword
+		}
+	}()
+	return task, nil
+}
+
+func (r *Resolver) VisitSpawnExpr(expr *parser.SpawnExpr) (interface{}, error) {
+	return nil, r.resolveExpr(expr.Call)
+}
+
+// Channel passes values between tasks.
+type Channel struct {
+	ch chan interface{}
+}
+
+func (c *Channel) String() string { return "<channel>" }
+
+func checkChannelArg(arg interface{}, fnName string) (*Channel, error) {
+	c, ok := arg.(*Channel)
+	if !ok {
+		return nil, newError(nil, fnName+"() expects a channel.")
+	}
+	return c, nil
+}
+
+// maxChannelSize is the largest buffer chan(size) will create.
+const maxChannelSize = 1 << 20
+
+// builtinChan creates a channel, optionally buffered: chan() or chan(size).
+func builtinChan(interp *Interpreter, args []interface{}) (interface{}, error) {
+	size := 0
+	if len(args) > 1 {
+		return nil, newError(nil, "chan() takes at most one argument.")
+	}
+	if len(args) == 1 {
+		n, ok := args[0].(float64)
+		if !ok || n < 0 || n != math.Trunc(n) || n > maxChannelSize {
+			return nil, newError(nil, fmt.Sprintf("chan() expects an integer buffer size from 0 to %d.", maxChannelSize))
+		}
+		size = int(n)
+	}
+	if err := interp.alloc(sizeValue * size); err != nil {
+		return nil, err
+	}
+	return &Channel{ch: make(chan interface{}, size)}, nil
+}
+
+func builtinSend(interp *Interpreter, args []interface{}) (result interface{}, err error) {
+	c, err := checkChannelArg(args[0], "send")
+	if err != nil {
+		return nil, err
+	}
+
+	done := interp.context().Done()
+	interp.blocking(func() {
+		defer func() {
+			if recover() != nil {
+				err = newError(nil, "Cannot send on a closed channel.")
+			}
+		}()
+		select {
+		case c.ch <- args[1]:
+		case <-done:
+			err = ctxLimitError(interp.context())
+		}
+	})
+	return nil, err
+}
+
+// builtinRecv returns the next value from a channel, or null once it is closed and drained.
+func builtinRecv(interp *Interpreter, args []interface{}) (interface{}, error) {
+	c, err := checkChannelArg(args[0], "recv")
+	if err != nil {
+		return nil, err
+	}
+
+	var v interface{}
+	done := interp.context().Done()
+	interp.blocking(func() {
+		select {
+		case v = <-c.ch:
+		case <-done:
+			err = ctxLimitError(interp.context())
+		}
+	})
+	return v, err
+}
+
+func builtinClose(interp *Interpreter, args []interface{}) (result interface{}, err error) {
+	c, err := checkChannelArg(args[0], "close")
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if recover() != nil {
+			err = newError(nil, "Channel is already closed.")
+		}
+	}()
+	close(c.ch)
+	return nil, nil
+}
+
+// builtinSelect waits to receive from any of an array of channels: select(channels) or
+// select(channels, timeoutMs). It returns [index, value], or null if the timeout expires first.
+func builtinSelect(interp *Interpreter, args []interface{}) (interface{}, error) {
+	if len(args) > 2 {
+		return nil, newError(nil, "select() takes at most two arguments.")
+	}
+	arr, ok := args[0].(*LoxArray)
+	if !ok {
+		return nil, newError(nil, "select() expects an array of channels.")
+	}
+
+	cases := make([]reflect.SelectCase, 0, len(arr.Elements)+1)
+	for _, el := range arr.Elements {
+		c, err := checkChannelArg(el, "select")
+		if err != nil {
+			return nil, err
+		}
+		cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(c.ch)})
+	}
+	if len(args) == 2 {
+		ms, ok := args[1].(float64)
+		if !ok {
+			return nil, newError(nil, "select() expects a timeout in milliseconds.")
+		}
+		timer := time.NewTimer(time.Duration(ms * float64(time.Millisecond)))
+		defer timer.Stop()
+		cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(timer.C)})
+	}
+	cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(interp.context().Done())})
+
+	var chosen int
+	var value reflect.Value
+	var recvOK bool
+	interp.blocking(func() { chosen, value, recvOK = reflect.Select(cases) })
+
+	switch {
+	case chosen == len(cases)-1:
+		return nil, ctxLimitError(interp.context())
+	case chosen == len(arr.Elements):
+		return nil, nil // Timed out
+	}
+	var v interface{}
+	if recvOK {
+		v = value.Interface()
+	}
+	return NewArray([]interface{}{float64(chosen), v}), nil
+}
+
+func builtinSleep(interp *Interpreter, args []interface{}) (interface{}, error) {
+	ms, ok := args[0].(float64)
+	if !ok {
+		return nil, newError(nil, "sleep() expects a number of milliseconds.")
+	}
+	var err error
+	timer := time.NewTimer(time.Duration(ms * float64(time.Millisecond)))
+	defer timer.Stop()
+	done := interp.context().Done()
+	interp.blocking(func() {
+		select {
+		case <-timer.C:
+		case <-done:
+			err = ctxLimitError(interp.context())
+		}
+	})
+	return nil, err
+}
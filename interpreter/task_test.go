@@ -0,0 +1,113 @@
+package interpreter
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTasks(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"wait", `fun add(a, b) { return a + b; } var t = spawn add(1, 2); print t.wait();`, "3\n"},
+		{"done", `fun f(c) { recv(c); } var c = chan(); var t = spawn f(c); print t.done(); send(c, 1); t.wait(); print t.done();`, "false\ntrue\n"},
+		{
+			"unbuffered channel",
+			`fun produce(c) { for (var i = 0; i < 3; i = i + 1) send(c, i); close(c); }
+var c = chan();
+spawn produce(c);
+var v;
+while ((v = recv(c)) != null) print v;`,
+			"0\n1\n2\n",
+		},
+		{"buffered channel", `var c = chan(2); send(c, "a"); send(c, "b"); print recv(c) + recv(c);`, "ab\n"},
+		{"closed and drained", `var c = chan(1); send(c, 1); close(c); print recv(c); print recv(c);`, "1\nnull\n"},
+		{"select", `var a = chan(1); var b = chan(1); send(b, "x"); print select([a, b]); print select([a], 1);`, "[1 x]\nnull\n"},
+		{"task error", `fun f() { return 1 / "a"; } var t = spawn f(); var r = t.wait();`, ""},
+	}
+
+	for _, tt := range tests {
+		out, err := run(t, tt.input)
+		if tt.name == "task error" {
+			if _, ok := err.(*RuntimeError); !ok {
+				t.Errorf("%s: expected a runtime error, got %v", tt.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+		}
+		if out != tt.expected {
+			t.Errorf("%s: expected output %q, got %q", tt.name, tt.expected, out)
+		}
+	}
+}
+
+func TestChannel_Errors(t *testing.T) {
+	tests := []struct {
+		input   string
+		message string
+	}{
+		{`chan(-1);`, "chan() expects an integer buffer size"},
+		{`chan(1.5);`, "chan() expects an integer buffer size"},
+		{`chan(100000000000000000000);`, "chan() expects an integer buffer size"},
+		{`chan(1000000000000);`, "chan() expects an integer buffer size"},
+		{`chan("2");`, "chan() expects an integer buffer size"},
+		{`chan(1, 2);`, "chan() takes at most one argument."},
+		{`var c = chan(); close(c); close(c);`, "Channel is already closed."},
+		{`var c = chan(1); close(c); send(c, 1);`, "Cannot send on a closed channel."},
+		{`recv(1);`, "recv() expects a channel."},
+		{`select([1]);`, "select() expects a channel."},
+	}
+
+	for _, tt := range tests {
+		_, err := run(t, tt.input)
+		if err == nil || !strings.Contains(err.Error(), tt.message) {
+			t.Errorf("%s: expected an error containing %q, got %v", tt.input, tt.message, err)
+		}
+	}
+}
+
+func TestChannel_MemoryLimit(t *testing.T) {
+	interp := compile(t, `chan(100000);`).NewInterpreter()
+	interp.SetLimits(Limits{MaxMemory: 1 << 16})
+	if _, ok := interp.Interpret(context.Background()).(*LimitError); !ok {
+		t.Errorf("expected a channel buffer to be charged against the memory limit")
+	}
+}
+
+func TestInterpret_StopsTasks(t *testing.T) {
+	interp := compile(t, `
+fun spin() { while (true) {} }
+fun block() { recv(chan()); }
+var spinning = spawn spin();
+var blocked = spawn block();
+`).NewInterpreter()
+
+	finished := make(chan error, 1)
+	go func() { finished <- interp.Interpret(context.Background()) }()
+	select {
+	case err := <-finished:
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Interpret did not return while tasks were running")
+	}
+
+	for _, name := range []string{"spinning", "blocked"} {
+		task, _ := interp.Global(name)
+		select {
+		case <-task.(*Task).done:
+		default:
+			t.Errorf("%s task is still running after Interpret returned", name)
+		}
+	}
+	// The tasks must also have released the interpreter lock.
+	interp.gil.Lock()
+	interp.gil.Unlock()
+}
@@ -1,6 +1,9 @@
 package lexer
 
-import "strconv"
+import (
+	"strconv"
+	"strings"
+)
 
 type Lexer struct {
 	input   string
@@ -9,27 +12,38 @@ type Lexer struct {
 	current int // Current position
 	line    int // Current line
 	index   int // token index in tokens.
+
+	comments bool // Emit Comment tokens rather than discarding comments.
+	trivia   bool // Attach whitespace and comments to tokens.
+	end      int  // End of the previous token, where the next token's trivia begins.
 }
 
 var keywords = map[string]TokenType{
 	"and":      And,
 	"break":    Break,
+	"case":     Case,
 	"class":    Class,
+	"const":    Const,
 	"continue": Continue,
+	"default":  Default,
 	"else":     Else,
 	"false":    False,
 	"fun":      Fun,
 	"for":      For,
 	"if":       If,
+	"in":       In,
+	"match":    Match,
 	"null":     Null,
 	"or":       Or,
 	"print":    Print,
 	"return":   Return,
+	"spawn":    Spawn,
 	"super":    Super,
 	"this":     This,
 	"true":     True,
 	"var":      Var,
 	"while":    While,
+	"yield":    Yield,
 }
 
 func isAlpha(ch byte) bool {
@@ -50,13 +64,35 @@ func New(input string) *Lexer {
 	return l
 }
 
+// NewWithComments returns a new Lexer which emits a Comment token for each comment instead of
+// discarding it, for tools which rewrite the source.
+func NewWithComments(input string) *Lexer {
+	l := New(input)
+	l.comments = true
+	return l
+}
+
+// NewWithTrivia returns a new Lexer which attaches the whitespace and comments around each token
+// to it as trivia, so that the source can be rebuilt from the tokens.
+func NewWithTrivia(input string) *Lexer {
+	l := New(input)
+	l.trivia = true
+	return l
+}
+
 func (l *Lexer) ScanTokens() {
 	for !l.isAtEnd() {
 		l.start = l.current
 		l.scanToken()
 	}
 
-	l.tokens = append(l.tokens, NewToken(EOF, "", nil, l.line))
+	l.start = l.current
+	l.emit(NewToken(EOF, "", nil, l.line))
+}
+
+// Tokens returns every token scanned, ending with EOF.
+func (l *Lexer) Tokens() []*Token {
+	return l.tokens
 }
 
 // NextToken steps through the input to generate the next token
@@ -70,6 +106,14 @@ func (l *Lexer) NextToken() *Token {
 	return tok
 }
 
+// PeekToken returns the token which the next call to NextToken will return, without consuming it.
+func (l *Lexer) PeekToken() *Token {
+	if l.index >= len(l.tokens) {
+		return nil
+	}
+	return l.tokens[l.index]
+}
+
 func (l *Lexer) isAtEnd() bool {
 	return l.current >= len(l.input)
 }
@@ -104,7 +148,47 @@ func (l *Lexer) peekNext() byte {
 }
 
 func (l *Lexer) addToken(ty TokenType, literal interface{}) {
-	l.tokens = append(l.tokens, NewToken(ty, l.input[l.start:l.current], literal, l.line))
+	l.emit(NewToken(ty, l.input[l.start:l.current], literal, l.line))
+}
+
+// emit appends the token scanned from start to current. In trivia mode, the text since the
+// previous token is split between that token's trailing trivia, up to the end of its line, and
+// this token's leading trivia.
+func (l *Lexer) emit(tok *Token) {
+	if l.trivia {
+		gap := l.input[l.end:l.start]
+		if n := len(l.tokens); n > 0 {
+			i := trailingEnd(gap)
+			l.tokens[n-1].Trailing, gap = gap[:i], gap[i:]
+		}
+		tok.Leading = gap
+		l.end = l.current
+	}
+	l.tokens = append(l.tokens, tok)
+}
+
+// trailingEnd returns the length of the trailing trivia at the start of gap, which holds only
+// whitespace and comments: everything before the first newline outside of a block comment.
+func trailingEnd(gap string) int {
+	depth := 0
+	for i := 0; i < len(gap); i++ {
+		switch {
+		case depth == 0 && strings.HasPrefix(gap[i:], "//"):
+			if j := strings.IndexByte(gap[i:], '\n'); j >= 0 {
+				return i + j
+			}
+			return len(gap)
+		case strings.HasPrefix(gap[i:], "/*"):
+			depth++
+			i++
+		case depth > 0 && strings.HasPrefix(gap[i:], "*/"):
+			depth--
+			i++
+		case depth == 0 && gap[i] == '\n':
+			return i
+		}
+	}
+	return len(gap)
 }
 
 func (l *Lexer) scanToken() {
@@ -133,7 +217,13 @@ func (l *Lexer) scanToken() {
 	case ',':
 		l.addToken(Comma, nil)
 	case '.':
-		l.addToken(Dot, nil)
+		if l.peek() == '.' && l.peekNext() == '.' {
+			l.readChar()
+			l.readChar()
+			l.addToken(Ellipsis, nil)
+		} else {
+			l.addToken(Dot, nil)
+		}
 	case '+':
 		l.addToken(Plus, nil)
 	case '-':
@@ -145,6 +235,14 @@ func (l *Lexer) scanToken() {
 			for l.peek() != '\n' && l.peek() != 0 {
 				l.readChar()
 			}
+			if strings.HasPrefix(l.input[l.start:], "///") {
+				// Doc comments are always kept, for the parser to attach to declarations.
+				l.addToken(DocComment, docText(l.input[l.start:l.current]))
+			} else if l.comments {
+				l.addToken(Comment, nil)
+			}
+		} else if l.match('*') {
+			l.blockComment()
 		} else {
 			l.addToken(Slash, nil)
 		}
@@ -187,6 +285,38 @@ func (l *Lexer) scanToken() {
 	}
 }
 
+// blockComment scans a /* */ comment, which may contain nested block comments.
+func (l *Lexer) blockComment() {
+	line := l.line
+	depth := 1
+	for depth > 0 && !l.isAtEnd() {
+		switch {
+		case l.peek() == '/' && l.peekNext() == '*':
+			l.readChar()
+			depth++
+		case l.peek() == '*' && l.peekNext() == '/':
+			l.readChar()
+			depth--
+		case l.peek() == '\n':
+			l.line += 1
+		}
+		l.readChar()
+	}
+
+	if depth > 0 {
+		l.emit(NewToken(UTComment, l.input[l.start:l.current], nil, line))
+	} else if l.comments {
+		l.emit(NewToken(Comment, l.input[l.start:l.current], nil, line))
+	}
+}
+
+// docText returns the text of a /// comment, without the slashes and one following space.
+func docText(comment string) string {
+	text := strings.TrimPrefix(comment, "///")
+	text = strings.TrimPrefix(text, " ")
+	return strings.TrimRight(text, "\r")
+}
+
 func (l *Lexer) string() {
 	for l.peek() != '"' && l.peek() != '\n' && !l.isAtEnd() {
 		l.readChar()
@@ -214,12 +344,12 @@ func (l *Lexer) rawString() {
 
 	if l.isAtEnd() {
 		// Error points to line at start of string not end of string.
-		l.tokens = append(l.tokens, NewToken(UTString, l.input[l.start:l.current], l.input[l.start:l.current], line))
+		l.emit(NewToken(UTString, l.input[l.start:l.current], l.input[l.start:l.current], line))
 		return
 	}
 
 	l.readChar()
-	l.tokens = append(l.tokens, NewToken(String, l.input[l.start:l.current], l.input[l.start+1:l.current-1], line))
+	l.emit(NewToken(String, l.input[l.start:l.current], l.input[l.start+1:l.current-1], line))
 }
 
 func (l *Lexer) number() {
@@ -19,6 +19,7 @@ func TestLexer_ScanTokensCount(t *testing.T) {
 		{`"something`, 2},  // Unterminated String
 		{"`A fancy\nMultiline\nString`", 2},
 		{`2342.2323`, 2},
+		{`...a..`, 5}, // Ellipsis, Ident, Dot, Dot, EOF
 	}
 
 	for i, tt := range tests {
@@ -118,3 +119,110 @@ String`, 7},
 		}
 	}
 }
+
+func TestLexer_PeekToken(t *testing.T) {
+	l := New(`outer: for`)
+	l.ScanTokens()
+
+	if tok := l.PeekToken(); tok == nil || tok.Type != Ident {
+		t.Fatalf("unexpected peeked token. expected=%q, got=%+v", Ident, tok)
+	}
+	l.NextToken()
+	if tok := l.PeekToken(); tok == nil || tok.Type != Colon {
+		t.Fatalf("unexpected peeked token. expected=%q, got=%+v", Colon, tok)
+	}
+	if tok := l.NextToken(); tok.Type != Colon {
+		t.Errorf("peek consumed a token. expected=%q, got=%q", Colon, tok.Type)
+	}
+}
+
+func TestLexer_Comments(t *testing.T) {
+	l := NewWithComments("a // one\n// two\nb")
+	l.ScanTokens()
+
+	expected := []struct {
+		ty     TokenType
+		lexeme string
+		line   int
+	}{
+		{Ident, "a", 1},
+		{Comment, "// one", 1},
+		{Comment, "// two", 2},
+		{Ident, "b", 3},
+		{EOF, "", 3},
+	}
+	if len(l.tokens) != len(expected) {
+		t.Fatalf("unexpected token count. expected=%d, got=%d", len(expected), len(l.tokens))
+	}
+	for i, tt := range expected {
+		tok := l.tokens[i]
+		if tok.Type != tt.ty || tok.Lexeme != tt.lexeme || tok.Line != tt.line {
+			t.Errorf("token %d: expected=%v %q line %d, got=%v %q line %d", i, tt.ty, tt.lexeme, tt.line, tok.Type, tok.Lexeme, tok.Line)
+		}
+	}
+}
+
+func TestLexer_Trivia(t *testing.T) {
+	input := "  a = 1; // one\n\n// two\nb\n"
+	l := NewWithTrivia(input)
+	l.ScanTokens()
+
+	expected := []struct {
+		lexeme   string
+		leading  string
+		trailing string
+	}{
+		{"a", "  ", " "},
+		{"=", "", " "},
+		{"1", "", ""},
+		{";", "", " // one"},
+		{"b", "\n\n// two\n", ""},
+		{"", "\n", ""},
+	}
+	if len(l.tokens) != len(expected) {
+		t.Fatalf("unexpected token count. expected=%d, got=%d", len(expected), len(l.tokens))
+	}
+
+	var rebuilt string
+	for i, tt := range expected {
+		tok := l.tokens[i]
+		if tok.Lexeme != tt.lexeme || tok.Leading != tt.leading || tok.Trailing != tt.trailing {
+			t.Errorf("token %d: expected=%q %q %q, got=%q %q %q", i, tt.leading, tt.lexeme, tt.trailing, tok.Leading, tok.Lexeme, tok.Trailing)
+		}
+		rebuilt += tok.Leading + tok.Lexeme + tok.Trailing
+	}
+	if rebuilt != input {
+		t.Errorf("trivia does not rebuild the input. expected=%q, got=%q", input, rebuilt)
+	}
+}
+
+func TestLexer_BlockComments(t *testing.T) {
+	tests := []struct {
+		input string
+		types []TokenType
+		line  int // line of the last token before EOF
+	}{
+		{"/* one */ a", []TokenType{Ident, EOF}, 1},
+		{"/* a\n /* nested\n */ still\n */\nb", []TokenType{Ident, EOF}, 5},
+		{"a /* open\n", []TokenType{Ident, UTComment, EOF}, 1},
+		{"/// doc\n// plain\nc", []TokenType{DocComment, Ident, EOF}, 3},
+	}
+
+	for i, tt := range tests {
+		l := New(tt.input)
+		l.ScanTokens()
+
+		if len(l.tokens) != len(tt.types) {
+			t.Errorf("test %d: unexpected token count. expected=%d, got=%d", i+1, len(tt.types), len(l.tokens))
+			continue
+		}
+		for j, ty := range tt.types {
+			if l.tokens[j].Type != ty {
+				t.Errorf("test %d: token %d has wrong type. expected=%q, got=%q", i+1, j, ty, l.tokens[j].Type)
+			}
+		}
+		if last := l.tokens[len(l.tokens)-2]; last.Line != tt.line {
+			t.Errorf("test %d: wrong line. expected=%d, got=%d", i+1, tt.line, last.Line)
+		}
+	}
+}
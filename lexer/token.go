@@ -7,6 +7,11 @@ type Token struct {
 	Lexeme  string
 	Literal interface{}
 	Line    int
+
+	// Trivia is only kept by a Lexer created with NewWithTrivia. Concatenating the Leading text,
+	// Lexeme and Trailing text of every token reproduces the source exactly.
+	Leading  string // Whitespace and comments between the previous token's trivia and this one
+	Trailing string // Whitespace and any comment after this token on the same line
 }
 
 func NewToken(ty TokenType, lex string, lit interface{}, line int) *Token {
@@ -30,6 +35,9 @@ const (
 	Slash     = "/"
 	Star      = "*"
 
+	// Multi-character punctuation.
+	Ellipsis = "..."
+
 	// Single or two character tokens.
 	Bang      = "!"
 	BangEq    = "!="
@@ -49,23 +57,33 @@ const (
 	// Keywords
 	And      = "AND"
 	Break    = "BREAK"
+	Case     = "CASE"
 	Class    = "CLASS"
+	Const    = "CONST"
 	Continue = "CONTINUE"
+	Default  = "DEFAULT"
 	Else     = "ELSE"
 	False    = "FALSE"
 	Fun      = "FUN"
 	For      = "FOR"
 	If       = "IF"
+	In       = "IN"
+	Match    = "MATCH"
 	Null     = "NULL"
 	Or       = "OR"
 	Print    = "PRINT"
 	Return   = "RETURN"
+	Spawn    = "SPAWN"
 	Super    = "SUPER"
 	This     = "THIS"
 	True     = "TRUE"
 	Var      = "VAR"
 	While    = "WHILE"
+	Yield    = "YIELD"
 
-	Illegal = "ILLEGAL"
-	EOF     = "EOF"
+	Comment    = "COMMENT"
+	DocComment = "DOC COMMENT"
+	UTComment  = "UNTERMINATED COMMENT"
+	Illegal    = "ILLEGAL"
+	EOF        = "EOF"
 )
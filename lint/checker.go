@@ -0,0 +1,472 @@
+package lint
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/butlermatt/glox/lexer"
+	"github.com/butlermatt/glox/parser"
+)
+
+// binding is a local declaration, tracking whether it is ever read.
+type binding struct {
+	name *lexer.Token
+	kind string // variable, parameter, function or class
+	used bool
+}
+
+// checker walks the program much as the interpreter's resolver does, keeping a stack of local
+// scopes. Globals are not tracked, since other scripts and the REPL may use them.
+type checker struct {
+	p      *parser.Parser
+	diags  []Diagnostic
+	scopes [][]*binding // local scopes, each in order of declaration
+	method *method      // the method being checked, if any
+}
+
+// method records whether a method, or any function nested in it, refers to this or super.
+type method struct {
+	usesThis bool
+}
+
+func (c *checker) report(rule *Rule, line int, format string, args ...interface{}) {
+	c.diags = append(c.diags, Diagnostic{Rule: rule, Severity: rule.Severity, Line: line, Message: fmt.Sprintf(format, args...)})
+}
+
+func (c *checker) beginScope() {
+	c.scopes = append(c.scopes, nil)
+}
+
+// endScope closes the innermost scope, reporting its declarations which were never read.
+func (c *checker) endScope() {
+	for _, b := range c.scopes[len(c.scopes)-1] {
+		if b.used || strings.HasPrefix(b.name.Lexeme, "_") {
+			continue
+		}
+		if b.kind == "parameter" {
+			c.report(UnusedParameter, b.name.Line, "Parameter '%s' is never used.", b.name.Lexeme)
+		} else {
+			c.report(UnusedVariable, b.name.Line, "Local %s '%s' is never used.", b.kind, b.name.Lexeme)
+		}
+	}
+	c.scopes = c.scopes[:len(c.scopes)-1]
+}
+
+// declare adds name to the innermost scope, reporting any declaration in an enclosing scope
+// which it hides.
+func (c *checker) declare(name *lexer.Token, kind string) {
+	if len(c.scopes) == 0 || name.Lexeme == "_" {
+		return
+	}
+	for i := len(c.scopes) - 2; i >= 0; i-- {
+		if outer := lookup(c.scopes[i], name.Lexeme); outer != nil {
+			c.report(Shadowing, name.Line, "Declaration of '%s' shadows the %s declared on line %d.",
+				name.Lexeme, outer.kind, outer.name.Line)
+			break
+		}
+	}
+	c.scopes[len(c.scopes)-1] = append(c.scopes[len(c.scopes)-1], &binding{name: name, kind: kind})
+}
+
+// lookup returns the latest declaration of name in scope, or nil.
+func lookup(scope []*binding, name string) *binding {
+	for i := len(scope) - 1; i >= 0; i-- {
+		if scope[i].name.Lexeme == name {
+			return scope[i]
+		}
+	}
+	return nil
+}
+
+// use marks the innermost declaration of name as read.
+func (c *checker) use(name *lexer.Token) {
+	for i := len(c.scopes) - 1; i >= 0; i-- {
+		if b := lookup(c.scopes[i], name.Lexeme); b != nil {
+			b.used = true
+			return
+		}
+	}
+}
+
+// line returns the first line of node.
+func (c *checker) line(node interface{}) int {
+	if s, ok := c.p.Span(node); ok {
+		return s.First.Line
+	}
+	line := 0
+	consider := func(l int) {
+		if l > 0 && (line == 0 || l < line) {
+			line = l
+		}
+	}
+	for _, tok := range parser.Tokens(node) {
+		consider(tok.Line)
+	}
+	for _, child := range parser.Children(node) {
+		consider(c.line(child))
+	}
+	return line
+}
+
+// stmts checks a list of statements, reporting the first of them which can never run.
+func (c *checker) stmts(list []parser.Stmt) {
+	reported := false
+	for i, s := range list {
+		c.stmt(s)
+		if !reported && i+1 < len(list) && terminates(s) {
+			c.report(Unreachable, c.line(list[i+1]), "Unreachable code.")
+			reported = true
+		}
+	}
+}
+
+// terminates reports whether control never passes from stmt to the statement after it.
+func terminates(stmt parser.Stmt) bool {
+	switch s := stmt.(type) {
+	case *parser.ReturnStmt, *parser.BreakStmt, *parser.ContinueStmt:
+		return true
+	case *parser.BlockStmt:
+		for _, inner := range s.Statements {
+			if terminates(inner) {
+				return true
+			}
+		}
+	case *parser.IfStmt:
+		return s.Else != nil && terminates(s.Then) && terminates(s.Else)
+	}
+	return false
+}
+
+func (c *checker) stmt(stmt parser.Stmt) {
+	if stmt != nil {
+		stmt.Accept(c)
+	}
+}
+
+func (c *checker) expr(expr parser.Expr) {
+	if expr != nil {
+		expr.Accept(c)
+	}
+}
+
+func (c *checker) function(fn *parser.FunctionStmt) {
+	c.beginScope()
+	for i, param := range fn.Parameters {
+		c.expr(fn.Defaults[i])
+		c.declare(param, "parameter")
+	}
+	if fn.Rest != nil {
+		c.declare(fn.Rest, "parameter")
+	}
+	c.stmts(fn.Body)
+	c.endScope()
+}
+
+// condition checks the condition of an if or while statement. A while loop may use a literal
+// true to run until it breaks.
+func (c *checker) condition(cond parser.Expr, loop bool) {
+	c.expr(cond)
+	lit, ok := unwrap(cond).(*parser.LiteralExpr)
+	if !ok || loop && lit.Value == true {
+		return
+	}
+	truthy := lit.Value != nil && lit.Value != false
+	c.report(ConstantCondition, c.line(cond), "Condition is always %t.", truthy)
+}
+
+// unwrap removes any parentheses around expr.
+func unwrap(expr parser.Expr) parser.Expr {
+	for {
+		g, ok := expr.(*parser.GroupingExpr)
+		if !ok {
+			return expr
+		}
+		expr = g.Expression
+	}
+}
+
+// literalType returns the name of the type of expr if it is a literal.
+func literalType(expr parser.Expr) (string, bool) {
+	lit, ok := unwrap(expr).(*parser.LiteralExpr)
+	if !ok {
+		return "", false
+	}
+	switch lit.Value.(type) {
+	case nil:
+		return "null", true
+	case float64:
+		return "number", true
+	case string:
+		return "string", true
+	case bool:
+		return "boolean", true
+	}
+	return "", false
+}
+
+func (c *checker) VisitBlockStmt(stmt *parser.BlockStmt) error {
+	c.beginScope()
+	c.stmts(stmt.Statements)
+	c.endScope()
+	return nil
+}
+
+func (c *checker) VisitClassStmt(stmt *parser.ClassStmt) error {
+	c.declare(stmt.Name, "class")
+	if stmt.Superclass != nil {
+		c.expr(stmt.Superclass)
+	}
+
+	enclosing := c.method
+	for _, fn := range stmt.Methods {
+		c.method = &method{}
+		c.function(fn)
+		if fn.Name.Lexeme != "init" && !c.method.usesThis {
+			c.report(MethodWithoutThis, fn.Name.Line, "Method '%s' never uses 'this'; it could be a function.", fn.Name.Lexeme)
+		}
+	}
+	c.method = enclosing
+	return nil
+}
+
+func (c *checker) VisitExpressionStmt(stmt *parser.ExpressionStmt) error {
+	c.expr(stmt.Expression)
+	return nil
+}
+
+func (c *checker) VisitFunctionStmt(stmt *parser.FunctionStmt) error {
+	c.declare(stmt.Name, "function")
+	c.function(stmt)
+	return nil
+}
+
+func (c *checker) VisitIfStmt(stmt *parser.IfStmt) error {
+	c.condition(stmt.Condition, false)
+	c.stmt(stmt.Then)
+	c.stmt(stmt.Else)
+	return nil
+}
+
+func (c *checker) VisitPrintStmt(stmt *parser.PrintStmt) error {
+	c.expr(stmt.Expression)
+	return nil
+}
+
+func (c *checker) VisitReturnStmt(stmt *parser.ReturnStmt) error {
+	c.expr(stmt.Value)
+	return nil
+}
+
+func (c *checker) VisitVarStmt(stmt *parser.VarStmt) error {
+	c.expr(stmt.Initializer)
+	c.declare(stmt.Name, "variable")
+	return nil
+}
+
+func (c *checker) VisitForStmt(stmt *parser.ForStmt) error {
+	c.beginScope()
+	c.stmt(stmt.Initializer)
+	if stmt.Condition != nil {
+		c.condition(stmt.Condition, true)
+	}
+	c.stmt(stmt.Body)
+	c.expr(stmt.Increment)
+	c.endScope()
+	return nil
+}
+
+func (c *checker) VisitForInStmt(stmt *parser.ForInStmt) error {
+	c.expr(stmt.Iterable)
+	c.beginScope()
+	c.declare(stmt.Name, "variable")
+	c.stmt(stmt.Body)
+	c.endScope()
+	return nil
+}
+
+func (c *checker) VisitBreakStmt(stmt *parser.BreakStmt) error {
+	return nil
+}
+
+func (c *checker) VisitContinueStmt(stmt *parser.ContinueStmt) error {
+	return nil
+}
+
+func (c *checker) VisitMatchStmt(stmt *parser.MatchStmt) error {
+	c.expr(stmt.Subject)
+	for _, cs := range stmt.Cases {
+		c.stmt(cs)
+	}
+	return nil
+}
+
+func (c *checker) VisitCaseStmt(stmt *parser.CaseStmt) error {
+	c.beginScope()
+	for _, pat := range stmt.Patterns {
+		c.pattern(pat)
+	}
+	c.stmts(stmt.Body)
+	c.endScope()
+	return nil
+}
+
+// pattern declares the names bound by a case pattern and checks the expressions within it.
+func (c *checker) pattern(pattern parser.Expr) {
+	switch pat := pattern.(type) {
+	case *parser.VariableExpr:
+		c.declare(pat.Name, "variable")
+	case *parser.ArrayExpr:
+		for _, elem := range pat.Values {
+			c.pattern(elem)
+		}
+	case *parser.SpreadExpr:
+		c.pattern(pat.Expression)
+	case *parser.CallExpr:
+		c.expr(pat.Callee)
+		for _, field := range pat.Named {
+			c.pattern(field)
+		}
+	default:
+		c.expr(pattern)
+	}
+}
+
+func (c *checker) VisitArrayExpr(expr *parser.ArrayExpr) (interface{}, error) {
+	for _, v := range expr.Values {
+		c.expr(v)
+	}
+	return nil, nil
+}
+
+func (c *checker) VisitAssignExpr(expr *parser.AssignExpr) (interface{}, error) {
+	// Assigning a variable is not a use of it, so only the value is visited.
+	c.expr(expr.Value)
+	if v, ok := unwrap(expr.Value).(*parser.VariableExpr); ok && v.Name.Lexeme == expr.Name.Lexeme {
+		c.report(SelfAssignment, expr.Name.Line, "'%s' is assigned to itself.", expr.Name.Lexeme)
+	}
+	return nil, nil
+}
+
+func (c *checker) VisitBinaryExpr(expr *parser.BinaryExpr) (interface{}, error) {
+	c.expr(expr.Left)
+	c.expr(expr.Right)
+
+	left, leftOk := literalType(expr.Left)
+	right, rightOk := literalType(expr.Right)
+	switch expr.Operator.Type {
+	case lexer.EqualEq, lexer.BangEq:
+		if leftOk && rightOk && left != right {
+			c.report(IncompatibleComparison, expr.Operator.Line, "Comparing a %s with a %s is always %t.",
+				left, right, expr.Operator.Type == lexer.BangEq)
+		}
+	case lexer.Less, lexer.LessEq, lexer.Greater, lexer.GreaterEq:
+		for _, t := range []string{left, right} {
+			if t != "" && t != "number" {
+				c.report(IncompatibleComparison, expr.Operator.Line, "Operator '%s' cannot compare a %s.",
+					expr.Operator.Lexeme, t)
+				break
+			}
+		}
+	}
+	return nil, nil
+}
+
+func (c *checker) VisitCallExpr(expr *parser.CallExpr) (interface{}, error) {
+	c.expr(expr.Callee)
+	for _, a := range expr.Args {
+		c.expr(a)
+	}
+	for _, a := range expr.Named {
+		c.expr(a)
+	}
+	return nil, nil
+}
+
+func (c *checker) VisitGetExpr(expr *parser.GetExpr) (interface{}, error) {
+	c.expr(expr.Object)
+	return nil, nil
+}
+
+func (c *checker) VisitGroupingExpr(expr *parser.GroupingExpr) (interface{}, error) {
+	c.expr(expr.Expression)
+	return nil, nil
+}
+
+func (c *checker) VisitIndexExpr(expr *parser.IndexExpr) (interface{}, error) {
+	c.expr(expr.Left)
+	c.expr(expr.Right)
+	return nil, nil
+}
+
+func (c *checker) VisitLiteralExpr(expr *parser.LiteralExpr) (interface{}, error) {
+	return nil, nil
+}
+
+func (c *checker) VisitLogicalExpr(expr *parser.LogicalExpr) (interface{}, error) {
+	c.expr(expr.Left)
+	c.expr(expr.Right)
+	return nil, nil
+}
+
+func (c *checker) VisitSetExpr(expr *parser.SetExpr) (interface{}, error) {
+	c.expr(expr.Object)
+	c.expr(expr.Value)
+	// An index assignment, which has no name, is not checked.
+	if get, ok := unwrap(expr.Value).(*parser.GetExpr); ok && expr.Name != nil && get.Name.Lexeme == expr.Name.Lexeme && sameObject(get.Object, expr.Object) {
+		c.report(SelfAssignment, expr.Name.Line, "Field '%s' is assigned to itself.", expr.Name.Lexeme)
+	}
+	return nil, nil
+}
+
+// sameObject reports whether a and b certainly refer to the same object: both this, or the
+// same variable.
+func sameObject(a, b parser.Expr) bool {
+	switch a := unwrap(a).(type) {
+	case *parser.ThisExpr:
+		_, ok := unwrap(b).(*parser.ThisExpr)
+		return ok
+	case *parser.VariableExpr:
+		v, ok := unwrap(b).(*parser.VariableExpr)
+		return ok && v.Name.Lexeme == a.Name.Lexeme
+	}
+	return false
+}
+
+func (c *checker) VisitSpawnExpr(expr *parser.SpawnExpr) (interface{}, error) {
+	c.expr(expr.Call)
+	return nil, nil
+}
+
+func (c *checker) VisitSpreadExpr(expr *parser.SpreadExpr) (interface{}, error) {
+	c.expr(expr.Expression)
+	return nil, nil
+}
+
+func (c *checker) VisitSuperExpr(expr *parser.SuperExpr) (interface{}, error) {
+	if c.method != nil {
+		c.method.usesThis = true
+	}
+	return nil, nil
+}
+
+func (c *checker) VisitThisExpr(expr *parser.ThisExpr) (interface{}, error) {
+	if c.method != nil {
+		c.method.usesThis = true
+	}
+	return nil, nil
+}
+
+func (c *checker) VisitUnaryExpr(expr *parser.UnaryExpr) (interface{}, error) {
+	c.expr(expr.Right)
+	return nil, nil
+}
+
+func (c *checker) VisitVariableExpr(expr *parser.VariableExpr) (interface{}, error) {
+	c.use(expr.Name)
+	return nil, nil
+}
+
+func (c *checker) VisitYieldExpr(expr *parser.YieldExpr) (interface{}, error) {
+	c.expr(expr.Value)
+	return nil, nil
+}
@@ -0,0 +1,218 @@
+// Package lint reports suspicious code which is valid Lox, as run by "glox lint". Each finding
+// comes from a Rule with an ID and a severity, and may be suppressed with a comment:
+//
+//	// lint:ignore L001,L003      ignores the rules on this line, or the next if it has no code
+//	// lint:file-ignore L002      ignores the rule in the whole file
+//
+// Rules may be named by ID or by name, and a directive naming no rules ignores them all.
+package lint
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/butlermatt/glox/lexer"
+	"github.com/butlermatt/glox/parser"
+)
+
+// Severity ranks how serious a finding is.
+type Severity int
+
+const (
+	Info Severity = iota
+	Warning
+	Error
+)
+
+var severityNames = []string{"info", "warning", "error"}
+
+func (s Severity) String() string {
+	if int(s) < len(severityNames) {
+		return severityNames[s]
+	}
+	return fmt.Sprintf("Severity(%d)", int(s))
+}
+
+// ParseSeverity returns the severity named s: info, warning or error.
+func ParseSeverity(s string) (Severity, error) {
+	for i, name := range severityNames {
+		if s == name {
+			return Severity(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown severity %q", s)
+}
+
+// Rule is a check made by the linter, with the severity its findings have by default.
+type Rule struct {
+	ID       string
+	Name     string
+	Severity Severity
+	Doc      string
+}
+
+var (
+	UnusedVariable = &Rule{"L001", "unused-variable", Warning,
+		"A local variable, function or class is declared but never read."}
+	UnusedParameter = &Rule{"L002", "unused-parameter", Info,
+		"A function parameter is never read."}
+	Shadowing = &Rule{"L003", "shadowing", Warning,
+		"A local declaration hides a variable or parameter of an enclosing scope."}
+	Unreachable = &Rule{"L004", "unreachable", Warning,
+		"A statement follows a return, break or continue and can never run."}
+	SelfAssignment = &Rule{"L005", "self-assignment", Warning,
+		"A variable or field is assigned its own value."}
+	ConstantCondition = &Rule{"L006", "constant-condition", Warning,
+		"The condition of an if or while statement is a literal."}
+	IncompatibleComparison = &Rule{"L007", "incompatible-comparison", Warning,
+		"A comparison involves literals of types it can never accept or match."}
+	MethodWithoutThis = &Rule{"L008", "method-without-this", Info,
+		"A method never uses this or super, so it could be a function."}
+)
+
+// Rules lists every rule, in order of ID.
+var Rules = []*Rule{
+	UnusedVariable, UnusedParameter, Shadowing, Unreachable, SelfAssignment, ConstantCondition,
+	IncompatibleComparison, MethodWithoutThis,
+}
+
+// Lookup returns the rule with the given ID or name, or nil if there is none.
+func Lookup(name string) *Rule {
+	for _, r := range Rules {
+		if strings.EqualFold(name, r.ID) || name == r.Name {
+			return r
+		}
+	}
+	return nil
+}
+
+// Diagnostic is a finding of the linter.
+type Diagnostic struct {
+	Rule     *Rule
+	Severity Severity
+	Line     int
+	Message  string
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%d: %s %s: %s (%s)", d.Line, d.Severity, d.Rule.ID, d.Message, d.Rule.Name)
+}
+
+// Config chooses which rules are run and overrides their severity.
+type Config struct {
+	Disabled map[*Rule]bool
+	Severity map[*Rule]Severity
+}
+
+// Check lints a Lox program, returning its findings in line order. A program with syntax errors
+// is not checked, and the errors are returned instead. cfg may be nil to run every rule.
+func Check(src string, cfg *Config) ([]Diagnostic, error) {
+	p := parser.New(lexer.New(src))
+	stmts := p.Parse()
+	if errs := p.Errors(); len(errs) > 0 {
+		var msgs []string
+		for _, e := range errs {
+			msgs = append(msgs, fmt.Sprintf("[Syntax Error line %d] Error %s: %s", e.Line, e.Where, e.Msg))
+		}
+		return nil, fmt.Errorf("%s", strings.Join(msgs, "\n"))
+	}
+	if cfg == nil {
+		cfg = &Config{}
+	}
+
+	c := &checker{p: p}
+	c.stmts(stmts)
+
+	ignored := directives(src)
+	var diags []Diagnostic
+	for _, d := range c.diags {
+		if cfg.Disabled[d.Rule] || ignored.covers(d) {
+			continue
+		}
+		if s, ok := cfg.Severity[d.Rule]; ok {
+			d.Severity = s
+		}
+		diags = append(diags, d)
+	}
+	sort.SliceStable(diags, func(i, j int) bool {
+		if diags[i].Line != diags[j].Line {
+			return diags[i].Line < diags[j].Line
+		}
+		return diags[i].Rule.ID < diags[j].Rule.ID
+	})
+	return diags, nil
+}
+
+// suppressions holds the rules ignored by comments, by line. Line 0 holds those ignored in the
+// whole file, and a nil rule stands for every rule.
+type suppressions map[int][]*Rule
+
+func (s suppressions) covers(d Diagnostic) bool {
+	for _, line := range []int{0, d.Line} {
+		for _, r := range s[line] {
+			if r == nil || r == d.Rule {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// directives finds the lint:ignore and lint:file-ignore comments in src.
+func directives(src string) suppressions {
+	l := lexer.NewWithComments(src)
+	l.ScanTokens()
+
+	// The lines holding code, which a comment on the same line applies to.
+	code := make(map[int]bool)
+	for _, tok := range l.Tokens() {
+		if tok.Type != lexer.Comment && tok.Type != lexer.EOF {
+			for line := tok.Line; line <= tok.Line+strings.Count(tok.Lexeme, "\n"); line++ {
+				code[line] = true
+			}
+		}
+	}
+
+	s := make(suppressions)
+	for _, tok := range l.Tokens() {
+		if tok.Type != lexer.Comment {
+			continue
+		}
+		text := strings.TrimPrefix(tok.Lexeme, "//")
+		if strings.HasPrefix(tok.Lexeme, "/*") {
+			text = strings.TrimSuffix(strings.TrimPrefix(tok.Lexeme, "/*"), "*/")
+		}
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+
+		rules := []*Rule{nil}
+		if len(fields) > 1 {
+			rules = nil
+			for _, name := range strings.Split(fields[1], ",") {
+				if r := Lookup(name); r != nil {
+					rules = append(rules, r)
+				}
+			}
+		}
+
+		switch fields[0] {
+		case "lint:ignore":
+			end := tok.Line + strings.Count(tok.Lexeme, "\n")
+			switch {
+			case code[tok.Line] || code[end]:
+				s[tok.Line] = append(s[tok.Line], rules...)
+				if end != tok.Line {
+					s[end] = append(s[end], rules...)
+				}
+			default:
+				s[end+1] = append(s[end+1], rules...)
+			}
+		case "lint:file-ignore":
+			s[0] = append(s[0], rules...)
+		}
+	}
+	return s
+}
@@ -0,0 +1,209 @@
+package lint
+
+import (
+	"strings"
+	"testing"
+)
+
+func check(t *testing.T, src string, cfg *Config) []string {
+	t.Helper()
+	diags, err := Check(src, cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var got []string
+	for _, d := range diags {
+		got = append(got, d.String())
+	}
+	return got
+}
+
+func TestCheck_Rules(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      string
+		expected []string
+	}{
+		{"unused variable", `
+fun f() {
+  var used = 1;
+  var unused = 2;
+  var _ignored = 3;
+  var assigned;
+  assigned = used;
+}`, []string{
+			"4: warning L001: Local variable 'unused' is never used. (unused-variable)",
+			"6: warning L001: Local variable 'assigned' is never used. (unused-variable)",
+		}},
+		{"globals are not reported", `var g = 1;`, nil},
+		{"closures use outer locals", `
+fun f() {
+  var n = 0;
+  fun inc() { n = n + 1; return n; }
+  return inc;
+}`, nil},
+		{"unused parameter", `
+fun f(a, b, _c, ...rest) { return a; }`, []string{
+			"2: info L002: Parameter 'b' is never used. (unused-parameter)",
+			"2: info L002: Parameter 'rest' is never used. (unused-parameter)",
+		}},
+		{"shadowing", `
+fun f(x) {
+  print x;
+  { var x = 2; print x; }
+  for (var i in x) {
+    fun g(i) { return i; }
+    print g;
+  }
+}`, []string{
+			"4: warning L003: Declaration of 'x' shadows the parameter declared on line 2. (shadowing)",
+			"5: warning L001: Local variable 'i' is never used. (unused-variable)",
+			"6: warning L003: Declaration of 'i' shadows the variable declared on line 5. (shadowing)",
+		}},
+		{"unreachable", `
+fun f(x) {
+  if (x) {
+    return 1;
+  } else {
+    return 2;
+  }
+  print "never";
+  print "not reported twice";
+}
+while (true) {
+  break;
+  print "never";
+}`, []string{
+			"8: warning L004: Unreachable code. (unreachable)",
+			"13: warning L004: Unreachable code. (unreachable)",
+		}},
+		{"self assignment", `
+class A {
+  init(x) {
+    this.x = this.x;
+    x = (x);
+  }
+}
+var l = [1];
+l[0] = l.x;`, []string{
+			"4: warning L005: Field 'x' is assigned to itself. (self-assignment)",
+			"5: warning L005: 'x' is assigned to itself. (self-assignment)",
+		}},
+		{"constant condition", `
+if ("yes") print 1;
+if ((null)) print 2;
+while (false) print 3;
+while (true) break;
+for (;;) break;`, []string{
+			"2: warning L006: Condition is always true. (constant-condition)",
+			"3: warning L006: Condition is always false. (constant-condition)",
+			"4: warning L006: Condition is always false. (constant-condition)",
+		}},
+		{"incompatible comparison", `
+print 1 == "1";
+print null != false;
+print "a" < 2;
+print 1 < 2;
+print "a" == "b";`, []string{
+			"2: warning L007: Comparing a number with a string is always false. (incompatible-comparison)",
+			"3: warning L007: Comparing a null with a boolean is always true. (incompatible-comparison)",
+			"4: warning L007: Operator '<' cannot compare a string. (incompatible-comparison)",
+		}},
+		{"method without this", `
+class A < B {
+  init() {}
+  plain() { return 1; }
+  field() { return this.x; }
+  parent() { return super.parent(); }
+  closure() { fun f() { return this; } return f; }
+}`, []string{
+			"4: info L008: Method 'plain' never uses 'this'; it could be a function. (method-without-this)",
+		}},
+		{"match bindings", `
+match ([1, 2]) {
+  case [a, b]: print a;
+}`, []string{
+			"3: warning L001: Local variable 'b' is never used. (unused-variable)",
+		}},
+	}
+
+	for _, tt := range tests {
+		got := check(t, tt.src, nil)
+		if strings.Join(got, "\n") != strings.Join(tt.expected, "\n") {
+			t.Errorf("%s: unexpected diagnostics.\nexpected:\n%s\ngot:\n%s", tt.name,
+				strings.Join(tt.expected, "\n"), strings.Join(got, "\n"))
+		}
+	}
+}
+
+func TestCheck_Suppression(t *testing.T) {
+	src := `
+// lint:file-ignore unused-parameter
+fun f(a) {
+  var x = 1; // lint:ignore L001
+  // lint:ignore L001,L003 x is reported below
+  var y = 2;
+  /* lint:ignore */ var z = 3;
+  // lint:ignore L006
+  var w = 4;
+}`
+	expected := []string{"9: warning L001: Local variable 'w' is never used. (unused-variable)"}
+	if got := check(t, src, nil); strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("unexpected diagnostics.\nexpected:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(got, "\n"))
+	}
+}
+
+func TestCheck_SuppressionScope(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      string
+		expected []string
+	}{
+		{
+			name: "trailing comment covers only its line",
+			src: `fun f() {
+  var x = 1; // lint:ignore L001
+  var y = 2;
+}`,
+			expected: []string{"3: warning L001: Local variable 'y' is never used. (unused-variable)"},
+		},
+		{
+			name: "own-line comment covers only the next line",
+			src: `fun f() {
+  var x = 1;
+  // lint:ignore L001
+  var y = 2;
+  var z = 3;
+}`,
+			expected: []string{
+				"2: warning L001: Local variable 'x' is never used. (unused-variable)",
+				"5: warning L001: Local variable 'z' is never used. (unused-variable)",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		if got := check(t, tt.src, nil); strings.Join(got, "\n") != strings.Join(tt.expected, "\n") {
+			t.Errorf("%s: unexpected diagnostics.\nexpected:\n%s\ngot:\n%s", tt.name, strings.Join(tt.expected, "\n"), strings.Join(got, "\n"))
+		}
+	}
+}
+
+func TestCheck_Config(t *testing.T) {
+	src := `fun f(a) { var b; }`
+	cfg := &Config{
+		Disabled: map[*Rule]bool{UnusedVariable: true},
+		Severity: map[*Rule]Severity{UnusedParameter: Error},
+	}
+	expected := []string{"1: error L002: Parameter 'a' is never used. (unused-parameter)"}
+	if got := check(t, src, cfg); strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("unexpected diagnostics.\nexpected:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(got, "\n"))
+	}
+
+	if _, err := Check("var = ;", nil); err == nil || !strings.Contains(err.Error(), "Syntax Error") {
+		t.Errorf("expected a syntax error, got %v", err)
+	}
+	if Lookup("l004") != Unreachable || Lookup("self-assignment") != SelfAssignment || Lookup("nope") != nil {
+		t.Errorf("Lookup did not find rules by ID and name")
+	}
+}
@@ -0,0 +1,505 @@
+package lsp
+
+import (
+	"fmt"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/butlermatt/glox/interpreter"
+	"github.com/butlermatt/glox/lexer"
+	"github.com/butlermatt/glox/lint"
+	"github.com/butlermatt/glox/parser"
+)
+
+// decl describes a declared name.
+type decl struct {
+	tok    *lexer.Token
+	kind   string // variable, constant, parameter, function, class or method
+	detail string // the declaration as written, such as "fun add(a, b)"
+	doc    string
+	global bool
+	start  int // the offsets between which the name is visible, for locals
+	end    int
+}
+
+// document is the analysis of one open source file. Names are resolved with the interpreter's
+// resolver, so they link to the same declarations as when the script runs. Properties and
+// methods cannot be resolved statically and are linked by name instead.
+type document struct {
+	uri   string
+	text  string
+	lines []int // the offset at which each line starts
+
+	p       *parser.Parser
+	stmts   []parser.Stmt
+	offsets map[*lexer.Token]int
+
+	names   []*lexer.Token // every identifier naming a variable, property or method
+	defs    map[*lexer.Token]*lexer.Token
+	decls   map[*lexer.Token]*decl
+	globals map[string]*lexer.Token
+	members map[*lexer.Token]bool // names of properties and methods rather than variables
+
+	diagnostics []Diagnostic
+	symbols     []DocumentSymbol
+	failure     string // the panic and stack trace of an analysis which failed, to be logged
+}
+
+func newDocument(uri, text string) *document {
+	d := &document{
+		uri:     uri,
+		text:    text,
+		lines:   []int{0},
+		offsets: make(map[*lexer.Token]int),
+		decls:   make(map[*lexer.Token]*decl),
+		globals: make(map[string]*lexer.Token),
+		members: make(map[*lexer.Token]bool),
+	}
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\n' {
+			d.lines = append(d.lines, i+1)
+		}
+	}
+
+	l := lexer.NewWithTrivia(text)
+	d.p = parser.New(l)
+	d.stmts = d.p.Parse()
+
+	// The trivia of the tokens covers the text between them, which gives their offsets.
+	offset := 0
+	for _, tok := range l.Tokens() {
+		offset += len(tok.Leading)
+		d.offsets[tok] = offset
+		offset += len(tok.Lexeme) + len(tok.Trailing)
+	}
+
+	d.analyze()
+	sort.Slice(d.names, func(i, j int) bool { return d.offsets[d.names[i]] < d.offsets[d.names[j]] })
+	return d
+}
+
+func (d *document) analyze() {
+	for _, e := range d.p.Errors() {
+		d.diagnostics = append(d.diagnostics, Diagnostic{
+			Range:    d.lineRange(e.Line),
+			Severity: SeverityError,
+			Source:   "glox",
+			Message:  fmt.Sprintf("Error %s: %s", e.Where, e.Msg),
+		})
+	}
+
+	defer func() {
+		// The analysis copes with the partial trees of a document with syntax errors, so this is
+		// a bug. Report it rather than stop the server, keeping whatever was found before it.
+		if r := recover(); r != nil {
+			d.failure = fmt.Sprintf("%v\n%s", r, debug.Stack())
+			d.diagnostics = append(d.diagnostics, Diagnostic{
+				Range:    d.lineRange(1),
+				Severity: SeverityError,
+				Source:   "glox",
+				Message:  fmt.Sprintf("Internal error analyzing the document: %v", r),
+			})
+		}
+	}()
+
+	defs, err := interpreter.Definitions(d.stmts)
+	d.defs = defs
+	if rerr, ok := err.(*interpreter.RuntimeError); ok && len(d.p.Errors()) == 0 {
+		r := d.lineRange(1)
+		if rerr.Token != nil {
+			r = d.tokenRange(rerr.Token)
+		}
+		d.diagnostics = append(d.diagnostics, Diagnostic{Range: r, Severity: SeverityError, Source: "glox", Message: rerr.Message})
+	}
+
+	if len(d.p.Errors()) == 0 {
+		findings, _ := lint.Check(d.text, nil)
+		for _, f := range findings {
+			d.diagnostics = append(d.diagnostics, Diagnostic{
+				Range:    d.lineRange(f.Line),
+				Severity: lintSeverity[f.Severity],
+				Code:     f.Rule.ID,
+				Source:   "glox lint",
+				Message:  f.Message,
+			})
+		}
+	}
+
+	for _, s := range d.stmts {
+		if s != nil {
+			d.walk(s, len(d.text), true)
+			if sym, ok := d.symbol(s); ok {
+				d.symbols = append(d.symbols, sym)
+			}
+		}
+	}
+}
+
+var lintSeverity = map[lint.Severity]int{
+	lint.Info:    SeverityInformation,
+	lint.Warning: SeverityWarning,
+	lint.Error:   SeverityError,
+}
+
+// walk records the names and declarations beneath node, whose enclosing scope ends at end.
+func (d *document) walk(node interface{}, end int, global bool) {
+	inner := end
+	switch n := node.(type) {
+	case *parser.VarStmt:
+		kind, keyword := "variable", "var "
+		if n.Constant {
+			kind, keyword = "constant", "const "
+		}
+		d.declare(n.Name, kind, keyword+n.Name.Lexeme, n.Doc, end, global)
+	case *parser.FunctionStmt:
+		d.declare(n.Name, "function", signature(n, false), n.Doc, end, global)
+		d.function(n)
+		return
+	case *parser.ClassStmt:
+		detail := "class " + n.Name.Lexeme
+		if n.Superclass != nil {
+			detail += " < " + n.Superclass.Name.Lexeme
+			d.walk(n.Superclass, end, global)
+		}
+		d.declare(n.Name, "class", detail, n.Doc, end, global)
+		for _, m := range n.Methods {
+			d.declare(m.Name, "method", n.Name.Lexeme+"."+signature(m, true), m.Doc, end, false)
+			d.members[m.Name] = true
+			d.function(m)
+		}
+		return
+	case *parser.BlockStmt, *parser.ForStmt:
+		inner, global = d.end(n, end), false
+	case *parser.ForInStmt:
+		d.walk(n.Iterable, end, global)
+		inner = d.end(n, end)
+		d.declare(n.Name, "variable", "var "+n.Name.Lexeme, "", inner, false)
+		d.walk(n.Body, inner, false)
+		return
+	case *parser.CaseStmt:
+		inner, global = d.end(n, end), false
+		for _, pat := range n.Patterns {
+			parser.Inspect(pat, func(node interface{}) bool {
+				// The resolver declares the names bound by the pattern.
+				if v, ok := node.(*parser.VariableExpr); ok && d.defs[v.Name] == v.Name {
+					d.declare(v.Name, "variable", "var "+v.Name.Lexeme, "", inner, false)
+				}
+				return true
+			})
+		}
+	case *parser.VariableExpr:
+		d.name(n.Name)
+	case *parser.AssignExpr:
+		d.name(n.Name)
+	case *parser.GetExpr:
+		d.member(n.Name)
+	case *parser.SetExpr:
+		if n.Name != nil { // An index assignment has no name.
+			d.member(n.Name)
+		}
+	case *parser.SuperExpr:
+		d.member(n.Method)
+	}
+
+	for _, c := range parser.Children(node) {
+		d.walk(c, inner, global)
+	}
+}
+
+// function records the parameters and body of a function or method.
+func (d *document) function(fn *parser.FunctionStmt) {
+	inner := d.end(fn, len(d.text))
+	for i, param := range fn.Parameters {
+		if fn.Defaults[i] != nil {
+			d.walk(fn.Defaults[i], inner, false)
+		}
+		d.declare(param, "parameter", param.Lexeme, "", inner, false)
+	}
+	if fn.Rest != nil {
+		d.declare(fn.Rest, "parameter", "..."+fn.Rest.Lexeme, "", inner, false)
+	}
+	for _, s := range fn.Body {
+		d.walk(s, inner, false)
+	}
+}
+
+func (d *document) declare(tok *lexer.Token, kind, detail, doc string, end int, global bool) {
+	d.decls[tok] = &decl{tok: tok, kind: kind, detail: detail, doc: doc, global: global, start: d.offsets[tok], end: end}
+	d.names = append(d.names, tok)
+	if global {
+		if _, ok := d.globals[tok.Lexeme]; !ok {
+			d.globals[tok.Lexeme] = tok
+		}
+	}
+}
+
+func (d *document) name(tok *lexer.Token) {
+	if _, ok := d.decls[tok]; !ok { // Names bound by a pattern are already declared.
+		d.names = append(d.names, tok)
+	}
+}
+
+func (d *document) member(tok *lexer.Token) {
+	d.names = append(d.names, tok)
+	d.members[tok] = true
+}
+
+// end returns the offset at which node ends, or def if it is unknown.
+func (d *document) end(node interface{}, def int) int {
+	if s, ok := d.p.Span(node); ok {
+		if off, ok := d.offsets[s.Last]; ok {
+			return off + len(s.Last.Lexeme)
+		}
+	}
+	return def
+}
+
+// declaration returns the declaration of the variable named by tok, which may be a declaration
+// itself, or nil if it is undeclared or names a property.
+func (d *document) declaration(tok *lexer.Token) *decl {
+	if d.members[tok] {
+		return nil
+	}
+	if dt, ok := d.defs[tok]; ok {
+		return d.decls[dt]
+	}
+	if dc, ok := d.decls[tok]; ok {
+		return dc
+	}
+	if g, ok := d.globals[tok.Lexeme]; ok {
+		return d.decls[g]
+	}
+	return nil
+}
+
+// nameAt returns the name at pos, if any.
+func (d *document) nameAt(pos Position) *lexer.Token {
+	off := d.offset(pos)
+	for _, tok := range d.names {
+		start := d.offsets[tok]
+		if start <= off && off <= start+len(tok.Lexeme) {
+			return tok
+		}
+	}
+	return nil
+}
+
+// references returns the occurrences of the variable or member named by tok, in source order.
+func (d *document) references(tok *lexer.Token, includeDecl bool) []*lexer.Token {
+	var refs []*lexer.Token
+	if d.members[tok] {
+		for _, n := range d.names {
+			if d.members[n] && n.Lexeme == tok.Lexeme && (includeDecl || d.decls[n] == nil) {
+				refs = append(refs, n)
+			}
+		}
+		return refs
+	}
+
+	target := d.declaration(tok)
+	if target == nil {
+		return nil
+	}
+	for _, n := range d.names {
+		if d.declaration(n) == target && (includeDecl || n != target.tok) {
+			refs = append(refs, n)
+		}
+	}
+	return refs
+}
+
+// definitions returns the declarations of the variable or member named by tok. A property has
+// no declaration, so its assignments are returned instead.
+func (d *document) definitions(tok *lexer.Token) []*lexer.Token {
+	if !d.members[tok] {
+		if dc := d.declaration(tok); dc != nil {
+			return []*lexer.Token{dc.tok}
+		}
+		return nil
+	}
+
+	var methods, fields []*lexer.Token
+	for _, n := range d.references(tok, true) {
+		if d.decls[n] != nil {
+			methods = append(methods, n)
+		}
+	}
+	if len(methods) > 0 {
+		return methods
+	}
+	parser.Inspect(stmtList(d.stmts), func(node interface{}) bool {
+		if set, ok := node.(*parser.SetExpr); ok && set.Name != nil && set.Name.Lexeme == tok.Lexeme {
+			fields = append(fields, set.Name)
+		}
+		return true
+	})
+	return fields
+}
+
+// stmtList wraps statements in a block so that they can be inspected together.
+func stmtList(stmts []parser.Stmt) parser.Stmt {
+	var list []parser.Stmt
+	for _, s := range stmts {
+		if s != nil {
+			list = append(list, s)
+		}
+	}
+	return &parser.BlockStmt{Statements: list}
+}
+
+// visible returns the declarations in scope at offset off, innermost first.
+func (d *document) visible(off int) []*decl {
+	var locals, globals []*decl
+	for _, dc := range d.decls {
+		switch {
+		case dc.kind == "method":
+		case dc.global:
+			globals = append(globals, dc)
+		case dc.start < off && off <= dc.end:
+			locals = append(locals, dc)
+		}
+	}
+	sort.Slice(locals, func(i, j int) bool { return locals[i].start > locals[j].start })
+	sort.Slice(globals, func(i, j int) bool { return globals[i].start < globals[j].start })
+	return append(locals, globals...)
+}
+
+// memberNames returns the names of every method and property, with the declarations of methods.
+func (d *document) memberNames() map[string]*decl {
+	names := make(map[string]*decl)
+	for _, n := range d.names {
+		if d.members[n] {
+			if dc := d.decls[n]; dc != nil || names[n.Lexeme] == nil {
+				names[n.Lexeme] = dc
+			}
+		}
+	}
+	return names
+}
+
+// symbol returns the outline entry for a top level declaration.
+func (d *document) symbol(stmt parser.Stmt) (DocumentSymbol, bool) {
+	switch s := stmt.(type) {
+	case *parser.ClassStmt:
+		sym := d.newSymbol(s, s.Name, SymbolClass)
+		for _, m := range s.Methods {
+			sym.Children = append(sym.Children, d.newSymbol(m, m.Name, SymbolMethod))
+		}
+		return sym, true
+	case *parser.FunctionStmt:
+		return d.newSymbol(s, s.Name, SymbolFunction), true
+	case *parser.VarStmt:
+		kind := SymbolVariable
+		if s.Constant {
+			kind = SymbolConstant
+		}
+		return d.newSymbol(s, s.Name, kind), true
+	}
+	return DocumentSymbol{}, false
+}
+
+func (d *document) newSymbol(node interface{}, name *lexer.Token, kind int) DocumentSymbol {
+	sel := d.tokenRange(name)
+	full := sel
+	if s, ok := d.p.Span(node); ok {
+		full = Range{Start: d.tokenRange(s.First).Start, End: d.tokenRange(s.Last).End}
+	}
+	sym := DocumentSymbol{Name: name.Lexeme, Kind: kind, Range: full, SelectionRange: sel}
+	if dc := d.decls[name]; dc != nil {
+		sym.Detail = dc.detail
+	}
+	return sym
+}
+
+// signature returns the declaration of fn as written, such as "fun* range(start, end)".
+func signature(fn *parser.FunctionStmt, method bool) string {
+	var params []string
+	for _, p := range fn.Parameters {
+		params = append(params, p.Lexeme)
+	}
+	if fn.Rest != nil {
+		params = append(params, "..."+fn.Rest.Lexeme)
+	}
+
+	var prefix string
+	switch {
+	case method && fn.Generator:
+		prefix = "*"
+	case fn.Generator:
+		prefix = "fun* "
+	case !method:
+		prefix = "fun "
+	}
+	return prefix + fn.Name.Lexeme + "(" + strings.Join(params, ", ") + ")"
+}
+
+// position converts a byte offset to a position.
+func (d *document) position(off int) Position {
+	line := sort.Search(len(d.lines), func(i int) bool { return d.lines[i] > off }) - 1
+	if line < 0 {
+		line = 0
+	}
+	return Position{Line: line, Character: utf16Len(d.text[d.lines[line]:off])}
+}
+
+// offset converts a position to a byte offset, clamped to the text.
+func (d *document) offset(pos Position) int {
+	if pos.Line >= len(d.lines) {
+		return len(d.text)
+	}
+	if pos.Line < 0 {
+		return 0
+	}
+	off := d.lines[pos.Line]
+	for units := 0; off < len(d.text) && d.text[off] != '\n'; {
+		r, size := utf8.DecodeRuneInString(d.text[off:])
+		n := 1
+		if r >= 0x10000 {
+			n = 2
+		}
+		if units+n > pos.Character {
+			break
+		}
+		units += n
+		off += size
+	}
+	return off
+}
+
+func utf16Len(s string) int {
+	n := 0
+	for _, r := range s {
+		n++
+		if r >= 0x10000 {
+			n++
+		}
+	}
+	return n
+}
+
+func (d *document) tokenRange(tok *lexer.Token) Range {
+	off, ok := d.offsets[tok]
+	if !ok {
+		return d.lineRange(tok.Line)
+	}
+	return Range{Start: d.position(off), End: d.position(off + len(tok.Lexeme))}
+}
+
+// lineRange returns the range of a line, numbered from 1, without its indentation.
+func (d *document) lineRange(line int) Range {
+	i := line - 1
+	if i < 0 || i >= len(d.lines) {
+		i = len(d.lines) - 1
+	}
+	start, end := d.lines[i], len(d.text)
+	if i+1 < len(d.lines) {
+		end = d.lines[i+1] - 1
+	}
+	text := d.text[start:end]
+	trimmed := strings.TrimLeft(text, " \t")
+	start += len(text) - len(trimmed)
+	end = start + len(strings.TrimRight(trimmed, " \t\r"))
+	return Range{Start: d.position(start), End: d.position(end)}
+}
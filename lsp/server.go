@@ -0,0 +1,343 @@
+// Package lsp implements a Language Server Protocol server for Lox, as run by "glox lsp". It
+// speaks JSON-RPC over a pair of streams, usually stdin and stdout, analyzing each open
+// document as it changes.
+package lsp
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/butlermatt/glox/interpreter"
+	"github.com/butlermatt/glox/lexer"
+)
+
+// Server answers the requests of one client.
+type Server struct {
+	conn     *conn
+	docs     map[string]*document
+	builtins []string
+	shutdown bool
+}
+
+// NewServer returns a Server which reads requests from r and writes responses to w.
+func NewServer(r io.Reader, w io.Writer) *Server {
+	return &Server{
+		conn:     newConn(r, w),
+		docs:     make(map[string]*document),
+		builtins: interpreter.GlobalNames(interpreter.AllCapabilities...),
+	}
+}
+
+// Run serves requests until the client sends exit or closes the input. Exiting without first
+// requesting shutdown is an error, as the protocol requires.
+func (s *Server) Run() error {
+	for {
+		msg, err := s.conn.read()
+		if err == io.EOF {
+			return nil
+		}
+		if rerr, ok := err.(*responseError); ok {
+			s.conn.reply(nil, nil, rerr)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if msg.Method == "exit" {
+			if !s.shutdown {
+				return errors.New("exit requested before shutdown")
+			}
+			return nil
+		}
+		result, err := s.handle(msg)
+		if msg.ID == nil {
+			continue // Notifications have no reply.
+		}
+		if err := s.conn.reply(msg.ID, result, err); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) handle(msg *message) (interface{}, error) {
+	switch msg.Method {
+	case "initialize":
+		return map[string]interface{}{
+			"capabilities": map[string]interface{}{
+				"textDocumentSync":       1, // Full
+				"definitionProvider":     true,
+				"referencesProvider":     true,
+				"hoverProvider":          true,
+				"documentSymbolProvider": true,
+				"renameProvider":         true,
+				"completionProvider":     map[string]interface{}{"triggerCharacters": []string{"."}},
+			},
+			"serverInfo": map[string]string{"name": "glox"},
+		}, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/didOpen":
+		var params DidOpenTextDocumentParams
+		if err := unmarshal(msg, &params); err != nil {
+			return nil, err
+		}
+		return nil, s.update(params.TextDocument.URI, params.TextDocument.Text)
+	case "textDocument/didChange":
+		var params DidChangeTextDocumentParams
+		if err := unmarshal(msg, &params); err != nil {
+			return nil, err
+		}
+		if n := len(params.ContentChanges); n > 0 {
+			return nil, s.update(params.TextDocument.URI, params.ContentChanges[n-1].Text)
+		}
+		return nil, nil
+	case "textDocument/didClose":
+		var params DidCloseTextDocumentParams
+		if err := unmarshal(msg, &params); err != nil {
+			return nil, err
+		}
+		delete(s.docs, params.TextDocument.URI)
+		return nil, s.conn.notify("textDocument/publishDiagnostics",
+			PublishDiagnosticsParams{URI: params.TextDocument.URI, Diagnostics: []Diagnostic{}})
+	case "textDocument/definition":
+		var params TextDocumentPositionParams
+		if err := unmarshal(msg, &params); err != nil {
+			return nil, err
+		}
+		return s.definition(params)
+	case "textDocument/references":
+		var params ReferenceParams
+		if err := unmarshal(msg, &params); err != nil {
+			return nil, err
+		}
+		return s.references(params)
+	case "textDocument/hover":
+		var params TextDocumentPositionParams
+		if err := unmarshal(msg, &params); err != nil {
+			return nil, err
+		}
+		return s.hover(params)
+	case "textDocument/documentSymbol":
+		var params DocumentSymbolParams
+		if err := unmarshal(msg, &params); err != nil {
+			return nil, err
+		}
+		d := s.docs[params.TextDocument.URI]
+		if d == nil || d.symbols == nil {
+			return []DocumentSymbol{}, nil
+		}
+		return d.symbols, nil
+	case "textDocument/completion":
+		var params TextDocumentPositionParams
+		if err := unmarshal(msg, &params); err != nil {
+			return nil, err
+		}
+		return s.completion(params), nil
+	case "textDocument/rename":
+		var params RenameParams
+		if err := unmarshal(msg, &params); err != nil {
+			return nil, err
+		}
+		return s.rename(params)
+	}
+
+	if msg.ID == nil {
+		return nil, nil // Unknown notifications, such as initialized, are ignored.
+	}
+	return nil, &responseError{Code: codeMethodNotFound, Message: "method not supported: " + msg.Method}
+}
+
+func unmarshal(msg *message, v interface{}) error {
+	if err := json.Unmarshal(msg.Params, v); err != nil {
+		return &responseError{Code: codeInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
+
+// update analyzes the new text of a document and publishes its diagnostics, logging the panic
+// of an analysis which failed.
+func (s *Server) update(uri, text string) error {
+	d := newDocument(uri, text)
+	s.docs[uri] = d
+	if d.failure != "" {
+		msg := LogMessageParams{Type: MessageError, Message: "analyzing " + uri + ": " + d.failure}
+		if err := s.conn.notify("window/logMessage", msg); err != nil {
+			return err
+		}
+	}
+	diags := d.diagnostics
+	if diags == nil {
+		diags = []Diagnostic{}
+	}
+	return s.conn.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{URI: uri, Diagnostics: diags})
+}
+
+// lookup returns the document and the name at the position of a request.
+func (s *Server) lookup(params TextDocumentPositionParams) (*document, *lexer.Token) {
+	d := s.docs[params.TextDocument.URI]
+	if d == nil {
+		return nil, nil
+	}
+	return d, d.nameAt(params.Position)
+}
+
+func (s *Server) locations(d *document, toks []*lexer.Token) []Location {
+	locs := []Location{}
+	for _, tok := range toks {
+		locs = append(locs, Location{URI: d.uri, Range: d.tokenRange(tok)})
+	}
+	return locs
+}
+
+func (s *Server) definition(params TextDocumentPositionParams) (interface{}, error) {
+	d, tok := s.lookup(params)
+	if tok == nil {
+		return nil, nil
+	}
+	return s.locations(d, d.definitions(tok)), nil
+}
+
+func (s *Server) references(params ReferenceParams) (interface{}, error) {
+	d, tok := s.lookup(params.TextDocumentPositionParams)
+	if tok == nil {
+		return nil, nil
+	}
+	return s.locations(d, d.references(tok, params.Context.IncludeDeclaration)), nil
+}
+
+func (s *Server) hover(params TextDocumentPositionParams) (interface{}, error) {
+	d, tok := s.lookup(params)
+	if tok == nil {
+		return nil, nil
+	}
+
+	var sections []string
+	switch {
+	case d.members[tok]:
+		for _, def := range d.definitions(tok) {
+			if dc := d.decls[def]; dc != nil {
+				sections = append(sections, describe(dc))
+			}
+		}
+		if len(sections) == 0 {
+			sections = append(sections, "property `"+tok.Lexeme+"`")
+		}
+	case d.declaration(tok) != nil:
+		sections = append(sections, describe(d.declaration(tok)))
+	case s.isBuiltin(tok.Lexeme):
+		sections = append(sections, "built-in `"+tok.Lexeme+"`")
+	default:
+		return nil, nil
+	}
+
+	r := d.tokenRange(tok)
+	return Hover{Contents: MarkupContent{Kind: "markdown", Value: strings.Join(sections, "\n\n---\n\n")}, Range: &r}, nil
+}
+
+// describe returns the Markdown hover text for a declaration.
+func describe(dc *decl) string {
+	text := "```lox\n" + dc.detail + "\n```"
+	if dc.kind == "parameter" {
+		text = "parameter `" + dc.detail + "`"
+	}
+	if dc.doc != "" {
+		text += "\n\n" + dc.doc
+	}
+	return text
+}
+
+func (s *Server) isBuiltin(name string) bool {
+	i := sort.SearchStrings(s.builtins, name)
+	return i < len(s.builtins) && s.builtins[i] == name
+}
+
+var completionKinds = map[string]int{
+	"variable":  CompletionVariable,
+	"constant":  CompletionConstant,
+	"parameter": CompletionVariable,
+	"function":  CompletionFunction,
+	"class":     CompletionClass,
+}
+
+// completion offers the methods and properties after a dot, or else the variables in scope and
+// the built-ins.
+func (s *Server) completion(params TextDocumentPositionParams) []CompletionItem {
+	items := []CompletionItem{}
+	d := s.docs[params.TextDocument.URI]
+	if d == nil {
+		return items
+	}
+
+	off := d.offset(params.Position)
+	start := off
+	for start > 0 && isIdent(d.text[start-1]) {
+		start--
+	}
+	if start > 0 && d.text[start-1] == '.' {
+		members := d.memberNames()
+		var names []string
+		for name := range members {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if dc := members[name]; dc != nil {
+				items = append(items, CompletionItem{Label: name, Kind: CompletionMethod, Detail: dc.detail})
+			} else {
+				items = append(items, CompletionItem{Label: name, Kind: CompletionField})
+			}
+		}
+		return items
+	}
+
+	seen := make(map[string]bool)
+	for _, dc := range d.visible(start) {
+		if !seen[dc.tok.Lexeme] {
+			seen[dc.tok.Lexeme] = true
+			items = append(items, CompletionItem{Label: dc.tok.Lexeme, Kind: completionKinds[dc.kind], Detail: dc.detail})
+		}
+	}
+	for _, name := range s.builtins {
+		if !seen[name] {
+			items = append(items, CompletionItem{Label: name, Kind: CompletionFunction, Detail: "built-in"})
+		}
+	}
+	return items
+}
+
+func isIdent(ch byte) bool {
+	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || '0' <= ch && ch <= '9' || ch == '_'
+}
+
+func (s *Server) rename(params RenameParams) (interface{}, error) {
+	d, tok := s.lookup(params.TextDocumentPositionParams)
+	if tok == nil {
+		return nil, &responseError{Code: codeRequestFailed, Message: "no name to rename here"}
+	}
+	if !isName(params.NewName) {
+		return nil, &responseError{Code: codeRequestFailed, Message: "'" + params.NewName + "' is not a valid name"}
+	}
+	refs := d.references(tok, true)
+	if len(refs) == 0 {
+		return nil, &responseError{Code: codeRequestFailed, Message: "'" + tok.Lexeme + "' is not declared in this file"}
+	}
+
+	edits := []TextEdit{}
+	for _, ref := range refs {
+		edits = append(edits, TextEdit{Range: d.tokenRange(ref), NewText: params.NewName})
+	}
+	return WorkspaceEdit{Changes: map[string][]TextEdit{d.uri: edits}}, nil
+}
+
+// isName reports whether s is an identifier, and not a keyword.
+func isName(s string) bool {
+	l := lexer.New(s)
+	l.ScanTokens()
+	toks := l.Tokens()
+	return len(toks) == 2 && toks[0].Type == lexer.Ident && toks[0].Lexeme == s
+}
@@ -0,0 +1,294 @@
+package lsp
+
+import (
+	"encoding/json"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/butlermatt/glox/lexer"
+)
+
+// client drives a Server over pipes, as an editor would.
+type client struct {
+	t    *testing.T
+	conn *conn
+	id   int
+	done chan error
+}
+
+func newClient(t *testing.T) *client {
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	c := &client{t: t, conn: newConn(outR, inW), done: make(chan error, 1)}
+	go func() {
+		c.done <- NewServer(inR, outW).Run()
+		outW.Close()
+	}()
+	return c
+}
+
+// call sends a request and decodes its result, returning any error response.
+func (c *client) call(method string, params, result interface{}) *responseError {
+	c.t.Helper()
+	c.id++
+	id := json.RawMessage(strings.TrimSpace(string(mustMarshal(c.t, c.id))))
+	if err := c.conn.write(&message{ID: &id, Method: method, Params: mustMarshal(c.t, params)}); err != nil {
+		c.t.Fatal(err)
+	}
+
+	msg := c.next()
+	if msg.ID == nil || string(*msg.ID) != string(id) {
+		c.t.Fatalf("%s: expected the response to request %s, got %+v", method, id, msg)
+	}
+	if msg.Error != nil {
+		return msg.Error
+	}
+	if result != nil {
+		if err := json.Unmarshal(msg.Result, result); err != nil {
+			c.t.Fatalf("%s: %v", method, err)
+		}
+	}
+	return nil
+}
+
+func (c *client) notify(method string, params interface{}) {
+	c.t.Helper()
+	if err := c.conn.write(&message{Method: method, Params: mustMarshal(c.t, params)}); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+func (c *client) next() *message {
+	c.t.Helper()
+	msg, err := c.conn.read()
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	return msg
+}
+
+// diagnostics returns the diagnostics published in the next message.
+func (c *client) diagnostics() PublishDiagnosticsParams {
+	c.t.Helper()
+	msg := c.next()
+	if msg.Method != "textDocument/publishDiagnostics" {
+		c.t.Fatalf("expected diagnostics, got %+v", msg)
+	}
+	var params PublishDiagnosticsParams
+	if err := json.Unmarshal(msg.Params, &params); err != nil {
+		c.t.Fatal(err)
+	}
+	return params
+}
+
+func mustMarshal(t *testing.T, v interface{}) json.RawMessage {
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+const uri = "file:///counter.lox"
+
+const source = `/// Adds one.
+fun inc(n) {
+  return n + 1;
+}
+
+class Counter {
+  init() { this.count = 0; }
+  /// Steps the counter.
+  step() {
+    var next = inc(this.count);
+    this.count = next;
+    return next;
+  }
+}
+
+var c = Counter();
+c.step();
+print inc(c.count);
+`
+
+func at(line, char int) TextDocumentPositionParams {
+	return TextDocumentPositionParams{TextDocument: TextDocumentIdentifier{URI: uri}, Position: Position{line, char}}
+}
+
+func rng(line, start, end int) Range {
+	return Range{Start: Position{line, start}, End: Position{line, end}}
+}
+
+func TestServer(t *testing.T) {
+	c := newClient(t)
+	if err := c.call("initialize", map[string]interface{}{}, nil); err != nil {
+		t.Fatal(err)
+	}
+	c.notify("initialized", map[string]interface{}{})
+
+	c.notify("textDocument/didOpen", DidOpenTextDocumentParams{TextDocument: TextDocumentItem{URI: uri, LanguageID: "lox", Text: source}})
+	if diags := c.diagnostics(); diags.URI != uri || len(diags.Diagnostics) != 0 {
+		t.Errorf("expected no diagnostics, got %+v", diags)
+	}
+
+	var locs []Location
+	c.call("textDocument/definition", at(17, 7), &locs)
+	if expected := []Location{{uri, rng(1, 4, 7)}}; !reflect.DeepEqual(locs, expected) {
+		t.Errorf("definition: expected %v, got %v", expected, locs)
+	}
+	c.call("textDocument/definition", at(16, 3), &locs)
+	if expected := []Location{{uri, rng(8, 2, 6)}}; !reflect.DeepEqual(locs, expected) {
+		t.Errorf("method definition: expected %v, got %v", expected, locs)
+	}
+
+	refs := ReferenceParams{TextDocumentPositionParams: at(11, 12)}
+	refs.Context.IncludeDeclaration = true
+	c.call("textDocument/references", refs, &locs)
+	if expected := []Location{{uri, rng(9, 8, 12)}, {uri, rng(10, 17, 21)}, {uri, rng(11, 11, 15)}}; !reflect.DeepEqual(locs, expected) {
+		t.Errorf("references: expected %v, got %v", expected, locs)
+	}
+
+	var hover Hover
+	c.call("textDocument/hover", at(17, 6), &hover)
+	if v := hover.Contents.Value; !strings.Contains(v, "fun inc(n)") || !strings.Contains(v, "Adds one.") {
+		t.Errorf("unexpected hover for inc: %q", v)
+	}
+	c.call("textDocument/hover", at(16, 2), &hover)
+	if v := hover.Contents.Value; !strings.Contains(v, "Counter.step()") || !strings.Contains(v, "Steps the counter.") {
+		t.Errorf("unexpected hover for step: %q", v)
+	}
+
+	var symbols []DocumentSymbol
+	c.call("textDocument/documentSymbol", DocumentSymbolParams{TextDocument: TextDocumentIdentifier{URI: uri}}, &symbols)
+	var names []string
+	for _, s := range symbols {
+		names = append(names, s.Name)
+		for _, child := range s.Children {
+			names = append(names, s.Name+"."+child.Name)
+		}
+	}
+	if expected := "inc Counter Counter.init Counter.step c"; strings.Join(names, " ") != expected {
+		t.Errorf("symbols: expected %q, got %q", expected, strings.Join(names, " "))
+	}
+
+	var items []CompletionItem
+	c.call("textDocument/completion", at(16, 2), &items)
+	if got := labels(items); got != "count init step" {
+		t.Errorf("member completion: got %q", got)
+	}
+	c.call("textDocument/completion", at(11, 11), &items)
+	got := labels(items)
+	for _, want := range []string{"next", "inc", "Counter", "c", "freeze"} {
+		if !strings.Contains(" "+got+" ", " "+want+" ") {
+			t.Errorf("completion is missing %q: %q", want, got)
+		}
+	}
+	if strings.Contains(" "+got+" ", " n ") {
+		t.Errorf("completion offers a parameter out of scope: %q", got)
+	}
+
+	var edit WorkspaceEdit
+	c.call("textDocument/rename", RenameParams{TextDocumentPositionParams: at(2, 9), NewName: "value"}, &edit)
+	expected := []TextEdit{{rng(1, 8, 9), "value"}, {rng(2, 9, 10), "value"}}
+	if !reflect.DeepEqual(edit.Changes[uri], expected) {
+		t.Errorf("rename: expected %v, got %v", expected, edit.Changes[uri])
+	}
+	if err := c.call("textDocument/rename", RenameParams{TextDocumentPositionParams: at(2, 9), NewName: "class"}, nil); err == nil {
+		t.Errorf("expected an error renaming to a keyword")
+	}
+
+	c.notify("textDocument/didChange", DidChangeTextDocumentParams{
+		TextDocument:   TextDocumentIdentifier{URI: uri},
+		ContentChanges: []TextDocumentContentChangeEvent{{Text: "var x = ;\nreturn 1;\n"}},
+	})
+	diags := c.diagnostics().Diagnostics
+	if len(diags) != 1 || diags[0].Range != rng(0, 0, 9) || !strings.Contains(diags[0].Message, "Expect expression") {
+		t.Errorf("unexpected syntax diagnostics: %+v", diags)
+	}
+	c.notify("textDocument/didChange", DidChangeTextDocumentParams{
+		TextDocument:   TextDocumentIdentifier{URI: uri},
+		ContentChanges: []TextDocumentContentChangeEvent{{Text: "return 1;\nif (true) print 1;\n"}},
+	})
+	diags = c.diagnostics().Diagnostics
+	if len(diags) != 3 || diags[0].Range != rng(0, 0, 6) || diags[1].Code != "L004" || diags[2].Code != "L006" {
+		t.Errorf("unexpected resolver and lint diagnostics: %+v", diags)
+	}
+
+	if err := c.call("unknown/method", nil, nil); err == nil || err.Code != codeMethodNotFound {
+		t.Errorf("expected method not found, got %v", err)
+	}
+	c.call("shutdown", nil, nil)
+	c.notify("exit", nil)
+	if err := <-c.done; err != nil {
+		t.Errorf("unexpected error from Run: %v", err)
+	}
+}
+
+func labels(items []CompletionItem) string {
+	var names []string
+	for _, item := range items {
+		names = append(names, item.Label)
+	}
+	return strings.Join(names, " ")
+}
+
+func TestDocument_Positions(t *testing.T) {
+	d := newDocument(uri, "var s = \"😀\";\nprint s;\n")
+	tests := []struct {
+		pos Position
+		off int
+	}{
+		{Position{0, 0}, 0},
+		{Position{0, 9}, 9},   // Just after the opening quote
+		{Position{0, 11}, 13}, // The emoji is two UTF-16 units and four bytes
+		{Position{1, 6}, 22},
+	}
+	for _, tt := range tests {
+		if off := d.offset(tt.pos); off != tt.off {
+			t.Errorf("offset(%v): expected %d, got %d", tt.pos, tt.off, off)
+		}
+		if pos := d.position(tt.off); pos != tt.pos {
+			t.Errorf("position(%d): expected %v, got %v", tt.off, tt.pos, pos)
+		}
+	}
+}
+
+func TestDocument_PartialTrees(t *testing.T) {
+	src := `class A < B { init(a, b = 1, ...c) { this.a = a; super.init(); } *gen() { yield 1; } }
+fun f(x, y = x, ...z) { match (x) { case 1, -2: return x; case [a, ...b]: return a; case P(q: 1): return x; default: break; } }
+outer: for (var i in [1, ...z]) { while (true) break outer; }
+var o = f(1, ...[3], y: 2); const k = -1; var l = [k]; l[0] = o.a; print o.a or !k;`
+	for _, diag := range newDocument(uri, src).diagnostics {
+		if diag.Severity == SeverityError {
+			t.Fatalf("unexpected error: %+v", diag)
+		}
+	}
+	l := lexer.New(src)
+	l.ScanTokens()
+	var lexemes []string
+	for tok := l.NextToken(); tok != nil && tok.Type != lexer.EOF; tok = l.NextToken() {
+		lexemes = append(lexemes, tok.Lexeme)
+	}
+
+	// Every prefix of the source, and the source missing each of its tokens, leaves the parser
+	// with a partial tree to analyze.
+	var texts []string
+	for i := range lexemes {
+		texts = append(texts, strings.Join(lexemes[:i], " "))
+		texts = append(texts, strings.Join(append(append([]string{}, lexemes[:i]...), lexemes[i+1:]...), " "))
+	}
+	for _, text := range texts {
+		d := newDocument(uri, text)
+		if d.failure != "" {
+			t.Fatalf("%q: analysis failed: %s", text, d.failure)
+		}
+		for off := 0; off <= len(text); off++ {
+			if tok := d.nameAt(d.position(off)); tok != nil {
+				d.references(tok, true)
+				d.definitions(tok)
+			}
+		}
+	}
+}
@@ -2,54 +2,114 @@ package main
 
 import (
 	"bufio"
+	"context"
+	"flag"
 	"fmt"
 	"github.com/butlermatt/glox/interpreter"
 	"github.com/butlermatt/glox/lexer"
 	"github.com/butlermatt/glox/parser"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 )
 
+// capabilities granted to scripts, set with the -allow flag.
+var capabilities []interpreter.Capability
+
+// stdin is shared by the prompt and the scripts it runs.
+var stdin = bufio.NewReader(os.Stdin)
+
 func main() {
+	allow := flag.String("allow", "all", "comma separated capabilities to grant scripts (io, fs, time, os, net-local), \"all\" or \"none\"")
+	flag.Parse()
+
+	var err error
+	capabilities, err = interpreter.ParseCapabilities(*allow)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(64)
+	}
+
+	if cmd, ok := commands[flag.Arg(0)]; ok {
+		cmd(flag.Args()[1:])
+		return
+	}
+
 	fmt.Println("This is a simple interface for debugging GLPC.")
 
-	if len(os.Args) > 2 {
-		fmt.Fprintf(os.Stderr, "Usage: %s [script]", os.Args[0])
-	} else if len(os.Args) == 2 {
-		runFile(os.Args[1])
+	if flag.NArg() > 0 {
+		runFile(flag.Arg(0), flag.Args()[1:])
 	} else {
 		runPrompt()
 	}
 }
 
-func runFile(path string) {
+func runFile(path string, args []string) {
 	data, err := ioutil.ReadFile(path)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "error reading file: %+v", err)
 		os.Exit(1)
 	}
 
-	err = run(string(data))
-	if err != nil {
+	// A syntax tree saved by "glox parse -json" is run without parsing the script again.
+	if filepath.Ext(path) == ".json" {
+		var stmts []parser.Stmt
+		stmts, err = parser.DecodeJSON(data)
+		if err == nil {
+			err = execute(stmts, args)
+		}
+	} else {
+		err = run(string(data), args)
+	}
+	if e, ok := err.(*interpreter.ExitError); ok {
+		os.Exit(e.Code)
+	} else if err != nil {
 		fmt.Fprintf(os.Stderr, "%v\n", err)
 		os.Exit(70)
 	}
 }
 
 func runPrompt() {
-	b := bufio.NewScanner(os.Stdin)
-
 	fmt.Printf("> ")
-	for b.Scan() {
+	for {
+		line, err := stdin.ReadString('\n')
+		if line == "" && err != nil {
+			return
+		}
+
 		fmt.Printf("> ")
-		err := run(b.Text())
-		if err != nil {
+		err = run(line, nil)
+		if e, ok := err.(*interpreter.ExitError); ok {
+			os.Exit(e.Code)
+		} else if err != nil {
 			fmt.Println(err)
 		}
 	}
 }
 
-func run(input string) error {
+func run(input string, args []string) error {
+	stmts, err := parse(input)
+	if err != nil {
+		return err
+	}
+	return execute(stmts, args)
+}
+
+// execute resolves and runs stmts with the granted capabilities.
+func execute(stmts []parser.Stmt, args []string) error {
+	prog, err := interpreter.Compile(stmts)
+	if err != nil {
+		return err
+	}
+
+	interp := prog.NewInterpreter(capabilities...)
+	interp.SetArgs(args)
+	interp.SetInput(stdin)
+	return interp.Interpret(context.Background())
+}
+
+// parse parses input, printing any syntax errors.
+func parse(input string) ([]parser.Stmt, error) {
 	l := lexer.New(input)
 	p := parser.New(l)
 
@@ -59,19 +119,7 @@ func run(input string) error {
 		for _, e := range errs {
 			fmt.Printf("[Syntax Error line %d] Error %s: %s\n", e.Line, e.Where, e.Msg)
 		}
-		return fmt.Errorf("%d syntax errors", len(errs))
+		return nil, fmt.Errorf("%d syntax errors", len(errs))
 	}
-
-	interp := interpreter.New(stmts)
-	resolver := interpreter.NewResolver(interp)
-	err := resolver.Resolve(stmts)
-	if err != nil {
-		return err
-	}
-	err = interp.Interpret()
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return stmts, nil
 }
@@ -18,31 +18,37 @@ func main() {
 		"Array : Values []Expr",
 		"Assign : Name *lexer.Token, Value Expr",
 		"Binary : Left Expr, Operator *lexer.Token, Right Expr",
-		"Call : Callee Expr, Paren *lexer.Token, Args []Expr",
+		"Call : Callee Expr, Paren *lexer.Token, Args []Expr, Names []*lexer.Token, Named []Expr",
 		"Get : Object Expr, Name *lexer.Token",
 		"Grouping : Expression Expr",
 		"Index : Left Expr, Operator *lexer.Token, Right Expr",
 		"Literal : Value interface{}",
 		"Logical : Left Expr, Operator *lexer.Token, Right Expr",
 		"Set : Object Expr, Name *lexer.Token, Value Expr",
+		"Spawn : Keyword *lexer.Token, Call *CallExpr",
+		"Spread : Ellipsis *lexer.Token, Expression Expr",
 		"Super : Keyword *lexer.Token, Method *lexer.Token",
 		"This : Keyword *lexer.Token",
 		"Unary : Operator *lexer.Token, Right Expr",
 		"Variable : Name *lexer.Token",
+		"Yield : Keyword *lexer.Token, Value Expr",
 	}
 
 	statements := []string{
 		"Block : Statements []Stmt",
-		"Class : Name *lexer.Token, Superclass *VariableExpr, Methods []*FunctionStmt",
+		"Class : Name *lexer.Token, Superclass *VariableExpr, Methods []*FunctionStmt, Doc string",
 		"Expression : Expression Expr",
-		"Function : Name *lexer.Token, Parameters []*lexer.Token, Body []Stmt",
+		"Function : Name *lexer.Token, Parameters []*lexer.Token, Defaults []Expr, Rest *lexer.Token, Body []Stmt, Generator bool, Doc string",
 		"If : Condition Expr, Then Stmt, Else Stmt",
 		"Print : Expression Expr",
 		"Return : Keyword *lexer.Token, Value Expr",
-		"Var : Name *lexer.Token, Initializer Expr",
-		"For : Initializer Stmt, Condition Expr, Body Stmt, Increment Expr",
-		"Break : Keyword *lexer.Token",
-		"Continue : Keyword *lexer.Token",
+		"Var : Name *lexer.Token, Initializer Expr, Constant bool, Doc string",
+		"For : Initializer Stmt, Condition Expr, Body Stmt, Increment Expr, Label *lexer.Token",
+		"ForIn : Keyword *lexer.Token, Name *lexer.Token, Iterable Expr, Body Stmt, Label *lexer.Token",
+		"Break : Keyword *lexer.Token, Label *lexer.Token",
+		"Continue : Keyword *lexer.Token, Label *lexer.Token",
+		"Match : Keyword *lexer.Token, Subject Expr, Cases []*CaseStmt",
+		"Case : Keyword *lexer.Token, Patterns []Expr, Body []Stmt",
 	}
 
 	err := defineAst(outDir, expressions, statements)
@@ -39,6 +39,8 @@ type CallExpr struct {
 	Callee Expr
 	Paren  *lexer.Token
 	Args   []Expr
+	Names  []*lexer.Token
+	Named  []Expr
 }
 
 func (c *CallExpr) Accept(visitor ExprVisitor) (interface{}, error) { return visitor.VisitCallExpr(c) }
@@ -92,6 +94,22 @@ type SetExpr struct {
 
 func (s *SetExpr) Accept(visitor ExprVisitor) (interface{}, error) { return visitor.VisitSetExpr(s) }
 
+type SpawnExpr struct {
+	Keyword *lexer.Token
+	Call    *CallExpr
+}
+
+func (s *SpawnExpr) Accept(visitor ExprVisitor) (interface{}, error) { return visitor.VisitSpawnExpr(s) }
+
+type SpreadExpr struct {
+	Ellipsis   *lexer.Token
+	Expression Expr
+}
+
+func (s *SpreadExpr) Accept(visitor ExprVisitor) (interface{}, error) {
+	return visitor.VisitSpreadExpr(s)
+}
+
 type SuperExpr struct {
 	Keyword *lexer.Token
 	Method  *lexer.Token
@@ -120,6 +138,13 @@ func (v *VariableExpr) Accept(visitor ExprVisitor) (interface{}, error) {
 	return visitor.VisitVariableExpr(v)
 }
 
+type YieldExpr struct {
+	Keyword *lexer.Token
+	Value   Expr
+}
+
+func (y *YieldExpr) Accept(visitor ExprVisitor) (interface{}, error) { return visitor.VisitYieldExpr(y) }
+
 type ExprVisitor interface {
 	VisitArrayExpr(expr *ArrayExpr) (interface{}, error)
 	VisitAssignExpr(expr *AssignExpr) (interface{}, error)
@@ -131,10 +156,13 @@ type ExprVisitor interface {
 	VisitLiteralExpr(expr *LiteralExpr) (interface{}, error)
 	VisitLogicalExpr(expr *LogicalExpr) (interface{}, error)
 	VisitSetExpr(expr *SetExpr) (interface{}, error)
+	VisitSpawnExpr(expr *SpawnExpr) (interface{}, error)
+	VisitSpreadExpr(expr *SpreadExpr) (interface{}, error)
 	VisitSuperExpr(expr *SuperExpr) (interface{}, error)
 	VisitThisExpr(expr *ThisExpr) (interface{}, error)
 	VisitUnaryExpr(expr *UnaryExpr) (interface{}, error)
 	VisitVariableExpr(expr *VariableExpr) (interface{}, error)
+	VisitYieldExpr(expr *YieldExpr) (interface{}, error)
 }
 type BlockStmt struct {
 	Statements []Stmt
@@ -146,6 +174,7 @@ type ClassStmt struct {
 	Name       *lexer.Token
 	Superclass *VariableExpr
 	Methods    []*FunctionStmt
+	Doc        string
 }
 
 func (c *ClassStmt) Accept(visitor StmtVisitor) error { return visitor.VisitClassStmt(c) }
@@ -159,7 +188,11 @@ func (e *ExpressionStmt) Accept(visitor StmtVisitor) error { return visitor.Visi
 type FunctionStmt struct {
 	Name       *lexer.Token
 	Parameters []*lexer.Token
+	Defaults   []Expr
+	Rest       *lexer.Token
 	Body       []Stmt
+	Generator  bool
+	Doc        string
 }
 
 func (f *FunctionStmt) Accept(visitor StmtVisitor) error { return visitor.VisitFunctionStmt(f) }
@@ -188,6 +221,8 @@ func (r *ReturnStmt) Accept(visitor StmtVisitor) error { return visitor.VisitRet
 type VarStmt struct {
 	Name        *lexer.Token
 	Initializer Expr
+	Constant    bool
+	Doc         string
 }
 
 func (v *VarStmt) Accept(visitor StmtVisitor) error { return visitor.VisitVarStmt(v) }
@@ -197,22 +232,51 @@ type ForStmt struct {
 	Condition   Expr
 	Body        Stmt
 	Increment   Expr
+	Label       *lexer.Token
 }
 
 func (f *ForStmt) Accept(visitor StmtVisitor) error { return visitor.VisitForStmt(f) }
 
+type ForInStmt struct {
+	Keyword  *lexer.Token
+	Name     *lexer.Token
+	Iterable Expr
+	Body     Stmt
+	Label    *lexer.Token
+}
+
+func (f *ForInStmt) Accept(visitor StmtVisitor) error { return visitor.VisitForInStmt(f) }
+
 type BreakStmt struct {
 	Keyword *lexer.Token
+	Label   *lexer.Token
 }
 
 func (b *BreakStmt) Accept(visitor StmtVisitor) error { return visitor.VisitBreakStmt(b) }
 
 type ContinueStmt struct {
 	Keyword *lexer.Token
+	Label   *lexer.Token
 }
 
 func (c *ContinueStmt) Accept(visitor StmtVisitor) error { return visitor.VisitContinueStmt(c) }
 
+type MatchStmt struct {
+	Keyword *lexer.Token
+	Subject Expr
+	Cases   []*CaseStmt
+}
+
+func (m *MatchStmt) Accept(visitor StmtVisitor) error { return visitor.VisitMatchStmt(m) }
+
+type CaseStmt struct {
+	Keyword  *lexer.Token
+	Patterns []Expr
+	Body     []Stmt
+}
+
+func (c *CaseStmt) Accept(visitor StmtVisitor) error { return visitor.VisitCaseStmt(c) }
+
 type StmtVisitor interface {
 	VisitBlockStmt(stmt *BlockStmt) error
 	VisitClassStmt(stmt *ClassStmt) error
@@ -223,6 +287,9 @@ type StmtVisitor interface {
 	VisitReturnStmt(stmt *ReturnStmt) error
 	VisitVarStmt(stmt *VarStmt) error
 	VisitForStmt(stmt *ForStmt) error
+	VisitForInStmt(stmt *ForInStmt) error
 	VisitBreakStmt(stmt *BreakStmt) error
 	VisitContinueStmt(stmt *ContinueStmt) error
+	VisitMatchStmt(stmt *MatchStmt) error
+	VisitCaseStmt(stmt *CaseStmt) error
 }
@@ -0,0 +1,342 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/butlermatt/glox/lexer"
+)
+
+// Every node type which may appear in an encoded tree, keyed by its Go type name.
+var nodeTypes = make(map[string]reflect.Type)
+
+func init() {
+	nodes := []interface{}{
+		&ArrayExpr{}, &AssignExpr{}, &BinaryExpr{}, &CallExpr{}, &GetExpr{}, &GroupingExpr{},
+		&IndexExpr{}, &LiteralExpr{}, &LogicalExpr{}, &SetExpr{}, &SpawnExpr{}, &SpreadExpr{},
+		&SuperExpr{}, &ThisExpr{}, &UnaryExpr{}, &VariableExpr{}, &YieldExpr{},
+
+		&BlockStmt{}, &ClassStmt{}, &ExpressionStmt{}, &FunctionStmt{}, &IfStmt{}, &PrintStmt{},
+		&ReturnStmt{}, &VarStmt{}, &ForStmt{}, &ForInStmt{}, &BreakStmt{},
+		&ContinueStmt{}, &MatchStmt{}, &CaseStmt{},
+	}
+	for _, n := range nodes {
+		t := reflect.TypeOf(n).Elem()
+		nodeTypes[t.Name()] = t
+	}
+}
+
+// The node and token fields which may be null, keyed by node type and field name. Every other
+// node or token field, and every element of a list of nodes or tokens, must be present.
+var optionalFields = map[string]bool{
+	"SetExpr.Name":         true,
+	"ClassStmt.Superclass": true,
+	"FunctionStmt.Rest":    true,
+	"IfStmt.Else":          true,
+	"ReturnStmt.Value":     true,
+	"VarStmt.Initializer":  true,
+	"ForStmt.Initializer":  true,
+	"ForStmt.Condition":    true,
+	"ForStmt.Increment":    true,
+	"ForStmt.Label":        true,
+	"ForInStmt.Label":      true,
+	"BreakStmt.Label":      true,
+	"ContinueStmt.Label":   true,
+	"YieldExpr.Value":      true,
+}
+
+// optionalElements are the lists of nodes whose elements may be null: a parameter's default.
+var optionalElements = map[string]bool{
+	"FunctionStmt.Defaults": true,
+}
+
+var tokenType = reflect.TypeOf(&lexer.Token{})
+
+// jsonToken is the encoded form of a lexer.Token.
+type jsonToken struct {
+	Type    lexer.TokenType `json:"type"`
+	Lexeme  string          `json:"lexeme"`
+	Literal interface{}     `json:"literal,omitempty"`
+	Line    int             `json:"line"`
+}
+
+// EncodeJSON encodes statements as a JSON array. Each node is an object naming its type in the
+// "node" key followed by its fields, and each token keeps its type, lexeme, literal and line,
+// so that DecodeJSON can rebuild an identical tree.
+func EncodeJSON(stmts []Stmt) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := encodeValue(&buf, reflect.ValueOf(stmts)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// DecodeJSON rebuilds the statements encoded by EncodeJSON.
+// Missing children and mismatched lists are errors, so the statements returned are as complete
+// as those of a successful parse.
+func DecodeJSON(data []byte) ([]Stmt, error) {
+	var stmts []Stmt
+	v, err := decodeValue(data, reflect.TypeOf(stmts))
+	if err != nil {
+		return nil, err
+	}
+	stmts = v.Interface().([]Stmt)
+	for i, stmt := range stmts {
+		if stmt == nil {
+			return nil, fmt.Errorf("statement %d is missing", i)
+		}
+	}
+	return stmts, nil
+}
+
+func encodeValue(buf *bytes.Buffer, v reflect.Value) error {
+	switch {
+	case v.Type() == tokenType:
+		if v.IsNil() {
+			buf.WriteString("null")
+			return nil
+		}
+		tok := v.Interface().(*lexer.Token)
+		return encodeJSON(buf, jsonToken{Type: tok.Type, Lexeme: tok.Lexeme, Literal: tok.Literal, Line: tok.Line})
+	case v.Kind() == reflect.Slice:
+		if v.IsNil() {
+			buf.WriteString("null")
+			return nil
+		}
+		buf.WriteByte('[')
+		for i := 0; i < v.Len(); i++ {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := encodeValue(buf, v.Index(i)); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+		return nil
+	case isNodeType(v.Type()):
+		if v.IsNil() {
+			buf.WriteString("null")
+			return nil
+		}
+		if v.Kind() == reflect.Interface {
+			v = v.Elem()
+		}
+		return encodeNode(buf, v)
+	}
+	return encodeJSON(buf, v.Interface())
+}
+
+func encodeNode(buf *bytes.Buffer, v reflect.Value) error {
+	t := v.Elem().Type()
+	if _, ok := nodeTypes[t.Name()]; !ok {
+		return fmt.Errorf("cannot encode unknown node type %s", t.Name())
+	}
+
+	buf.WriteString(`{"node":`)
+	encodeJSON(buf, t.Name())
+	for i := 0; i < t.NumField(); i++ {
+		buf.WriteByte(',')
+		encodeJSON(buf, t.Field(i).Name)
+		buf.WriteByte(':')
+		if err := encodeValue(buf, v.Elem().Field(i)); err != nil {
+			return err
+		}
+	}
+	buf.WriteByte('}')
+	return nil
+}
+
+func encodeJSON(buf *bytes.Buffer, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	buf.Write(data)
+	return nil
+}
+
+func decodeValue(data []byte, t reflect.Type) (reflect.Value, error) {
+	if bytes.Equal(bytes.TrimSpace(data), []byte("null")) {
+		return reflect.Zero(t), nil
+	}
+
+	switch {
+	case t == tokenType:
+		var tok jsonToken
+		if err := json.Unmarshal(data, &tok); err != nil {
+			return reflect.Value{}, err
+		}
+		return reflect.ValueOf(lexer.NewToken(tok.Type, tok.Lexeme, tok.Literal, tok.Line)), nil
+	case t.Kind() == reflect.Slice:
+		var elems []json.RawMessage
+		if err := json.Unmarshal(data, &elems); err != nil {
+			return reflect.Value{}, err
+		}
+		s := reflect.MakeSlice(t, len(elems), len(elems))
+		for i, e := range elems {
+			v, err := decodeValue(e, t.Elem())
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			s.Index(i).Set(v)
+		}
+		return s, nil
+	case isNodeType(t):
+		n, err := decodeNode(data)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		if !n.Type().AssignableTo(t) {
+			return reflect.Value{}, fmt.Errorf("expected %s, got %s", t, n.Type())
+		}
+		return n, nil
+	}
+
+	v := reflect.New(t)
+	if err := json.Unmarshal(data, v.Interface()); err != nil {
+		return reflect.Value{}, err
+	}
+	return v.Elem(), nil
+}
+
+func decodeNode(data []byte) (reflect.Value, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return reflect.Value{}, err
+	}
+	var name string
+	if err := json.Unmarshal(fields["node"], &name); err != nil {
+		return reflect.Value{}, fmt.Errorf("missing node type: %v", err)
+	}
+	t, ok := nodeTypes[name]
+	if !ok {
+		return reflect.Value{}, fmt.Errorf("unknown node type %q", name)
+	}
+
+	n := reflect.New(t)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		raw, ok := fields[f.Name]
+		if !ok {
+			continue
+		}
+		v, err := decodeValue(raw, f.Type)
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("%s.%s: %v", name, f.Name, err)
+		}
+		n.Elem().Field(i).Set(v)
+	}
+	if err := validateNode(name, n.Elem()); err != nil {
+		return reflect.Value{}, err
+	}
+	return n, nil
+}
+
+// validateNode reports the first required child missing from the node v, or a list whose length
+// doesn't match the list it pairs with.
+func validateNode(name string, v reflect.Value) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f, field := v.Field(i), name+"."+t.Field(i).Name
+		switch {
+		case f.Type() == tokenType || isNodeType(f.Type()):
+			if f.IsNil() && !optionalFields[field] {
+				return fmt.Errorf("%s is missing", field)
+			}
+		case f.Kind() == reflect.Slice && (f.Type().Elem() == tokenType || isNodeType(f.Type().Elem())):
+			if optionalElements[field] {
+				continue
+			}
+			for j := 0; j < f.Len(); j++ {
+				if f.Index(j).IsNil() {
+					return fmt.Errorf("%s[%d] is missing", field, j)
+				}
+			}
+		}
+	}
+
+	switch n := v.Addr().Interface().(type) {
+	case *FunctionStmt:
+		if len(n.Defaults) != len(n.Parameters) {
+			return fmt.Errorf("%s.Defaults has %d entries for %d parameters", name, len(n.Defaults), len(n.Parameters))
+		}
+	case *SetExpr:
+		if _, ok := n.Object.(*IndexExpr); n.Name == nil && !ok {
+			return fmt.Errorf("%s.Name is missing", name)
+		}
+	case *CallExpr:
+		if len(n.Names) != len(n.Named) {
+			return fmt.Errorf("%s.Named has %d entries for %d names", name, len(n.Named), len(n.Names))
+		}
+	case *LiteralExpr:
+		switch n.Value.(type) {
+		case nil, bool, float64, string:
+		default:
+			return fmt.Errorf("%s.Value is a %T, not a number, string, boolean or null", name, n.Value)
+		}
+	case *CaseStmt:
+		for i, pat := range n.Patterns {
+			if err := validatePattern(pat); err != nil {
+				return fmt.Errorf("%s.Patterns[%d]: %v", name, i, err)
+			}
+		}
+	}
+	return nil
+}
+
+// validatePattern reports a part of a case pattern which the parser would not have produced.
+// Patterns are literals, negated numbers, names, array patterns ending in an optional
+// "...name", and class patterns whose fields are patterns.
+func validatePattern(pattern Expr) error {
+	switch pat := pattern.(type) {
+	case *LiteralExpr, *VariableExpr:
+		return nil
+	case *UnaryExpr:
+		if lit, ok := pat.Right.(*LiteralExpr); ok && pat.Operator.Type == lexer.Minus {
+			if _, ok := lit.Value.(float64); ok {
+				return nil
+			}
+		}
+		return fmt.Errorf("a negative pattern must be '-' followed by a number")
+	case *ArrayExpr:
+		for i, elem := range pat.Values {
+			if s, ok := elem.(*SpreadExpr); ok {
+				if _, ok := s.Expression.(*VariableExpr); !ok || i != len(pat.Values)-1 {
+					return fmt.Errorf("a rest pattern must be '...name' at the end of an array pattern")
+				}
+				continue
+			}
+			if err := validatePattern(elem); err != nil {
+				return err
+			}
+		}
+		return nil
+	case *CallExpr:
+		if _, ok := pat.Callee.(*VariableExpr); !ok || len(pat.Args) > 0 {
+			return fmt.Errorf("a class pattern must name a class and only have named fields")
+		}
+		for _, field := range pat.Named {
+			if err := validatePattern(field); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("%T is not a pattern", pattern)
+}
+
+// isNodeType reports whether values of t hold syntax tree nodes: Expr, Stmt, or a pointer to a
+// specific node such as *CallExpr.
+func isNodeType(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Interface:
+		return t.NumMethod() > 0
+	case reflect.Ptr:
+		_, ok := nodeTypes[t.Elem().Name()]
+		return ok
+	}
+	return false
+}
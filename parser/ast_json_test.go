@@ -0,0 +1,78 @@
+package parser
+
+import (
+	"io/ioutil"
+	"reflect"
+	"testing"
+
+	"github.com/butlermatt/glox/lexer"
+)
+
+func TestJSON_RoundTrip(t *testing.T) {
+	input, err := ioutil.ReadFile("testdata/printer.lox")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := New(lexer.New(string(input)))
+	stmts := p.Parse()
+	if errs := p.Errors(); len(errs) > 0 {
+		t.Fatalf("unexpected parse errors: %+v", errs)
+	}
+
+	data, err := EncodeJSON(stmts)
+	if err != nil {
+		t.Fatalf("unexpected encoding error: %v", err)
+	}
+	decoded, err := DecodeJSON(data)
+	if err != nil {
+		t.Fatalf("unexpected decoding error: %v", err)
+	}
+
+	if !reflect.DeepEqual(stmts, decoded) {
+		t.Errorf("decoded statements do not match the original.\nexpected:\n%s\ngot:\n%s",
+			(&AstPrinter{}).PrintStmts(stmts), (&AstPrinter{}).PrintStmts(decoded))
+	}
+}
+
+func TestJSON_DecodeErrors(t *testing.T) {
+	tests := []string{
+		`[{"node":"BogusStmt"}]`,
+		`[{"node":"PrintStmt","Expression":{"node":"PrintStmt"}}]`,
+		`{"node":"PrintStmt"}`,
+		`[null]`,
+		`[{"node":"ExpressionStmt"}]`,
+		`[{"node":"ExpressionStmt","Expression":null}]`,
+		`[{"node":"ExpressionStmt","Expression":{"node":"BinaryExpr","Left":{"node":"LiteralExpr","Value":1}}}]`,
+		`[{"node":"BlockStmt","Statements":[null]}]`,
+		`[{"node":"ExpressionStmt","Expression":{"node":"VariableExpr"}}]`,
+		`[{"node":"FunctionStmt","Name":{"type":"IDENT","lexeme":"f","line":1},"Parameters":[{"type":"IDENT","lexeme":"a","line":1}],"Defaults":[],"Body":[]}]`,
+		`[{"node":"ExpressionStmt","Expression":{"node":"SetExpr","Object":{"node":"ThisExpr","Keyword":{"type":"THIS","lexeme":"this","line":1}},"Value":{"node":"LiteralExpr","Value":1}}}]`,
+		`[{"node":"ExpressionStmt","Expression":{"node":"CallExpr","Callee":{"node":"VariableExpr","Name":{"type":"IDENT","lexeme":"f","line":1}},"Paren":{"type":")","lexeme":")","line":1},"Names":[{"type":"IDENT","lexeme":"a","line":1}]}}]`,
+	}
+
+	// pattern returns a match statement with one case, matching the pattern p.
+	pattern := func(p string) string {
+		return `[{"node":"MatchStmt","Keyword":{"type":"MATCH","lexeme":"match","line":1},"Subject":{"node":"LiteralExpr","Value":1},` +
+			`"Cases":[{"node":"CaseStmt","Keyword":{"type":"CASE","lexeme":"case","line":1},"Patterns":[` + p + `],"Body":[]}]}]`
+	}
+	minus := `{"type":"-","lexeme":"-","line":1}`
+	name := `{"node":"VariableExpr","Name":{"type":"IDENT","lexeme":"x","line":1}}`
+	if _, err := DecodeJSON([]byte(pattern(`{"node":"UnaryExpr","Operator":` + minus + `,"Right":{"node":"LiteralExpr","Value":1}}`))); err != nil {
+		t.Errorf("unexpected error decoding a negative number pattern: %v", err)
+	}
+	tests = append(tests,
+		`[{"node":"ExpressionStmt","Expression":{"node":"LiteralExpr","Value":[1]}}]`,
+		pattern(`{"node":"UnaryExpr","Operator":`+minus+`,"Right":`+name+`}`),
+		pattern(`{"node":"UnaryExpr","Operator":`+minus+`,"Right":{"node":"LiteralExpr","Value":"s"}}`),
+		pattern(`{"node":"UnaryExpr","Operator":{"type":"!","lexeme":"!","line":1},"Right":{"node":"LiteralExpr","Value":1}}`),
+		pattern(`{"node":"BinaryExpr","Left":`+name+`,"Operator":`+minus+`,"Right":`+name+`}`),
+		pattern(`{"node":"ArrayExpr","Values":[{"node":"SpreadExpr","Ellipsis":{"type":"...","lexeme":"...","line":1},"Expression":`+name+`},`+name+`]}`),
+		pattern(`{"node":"ArrayExpr","Values":[{"node":"GroupingExpr","Expression":`+name+`}]}`),
+	)
+
+	for i, tt := range tests {
+		if _, err := DecodeJSON([]byte(tt)); err == nil {
+			t.Errorf("test %d: expected an error decoding %s", i+1, tt)
+		}
+	}
+}
@@ -1,59 +1,322 @@
 package parser
 
-//
-//import (
-//	"bytes"
-//	"fmt"
-//	"log"
-//)
-//
-//type AstPrinter struct {
-//}
-//
-//func (ap *AstPrinter) Print(expr Expr) string {
-//	res := expr.Accept(ap)
-//	result, ok := res.(string)
-//	if !ok {
-//		log.Fatalf("failed to convert accepted return to string. got=%T", res)
-//	}
-//
-//	return result
-//}
-//
-//func (ap *AstPrinter) VisitBinary(bin *Binary) interface{} {
-//	return ap.parenthesize(bin.Operator.Lexeme, bin.Left, bin.Right)
-//}
-//
-//func (ap *AstPrinter) VisitGrouping(group *Grouping) interface{} {
-//	return ap.parenthesize("group", group.Expression)
-//}
-//
-//func (ap *AstPrinter) VisitLiteral(literal *Literal) interface{} {
-//	if literal.Value == nil {
-//		return "Null"
-//	}
-//	return fmt.Sprintf("%v", literal.Value)
-//}
-//
-//func (ap *AstPrinter) VisitUnary(unary *Unary) interface{} {
-//	return ap.parenthesize(unary.Operator.Lexeme, unary.Right)
-//}
-//
-//func (ap *AstPrinter) parenthesize(name string, exprs ...Expr) string {
-//	var out bytes.Buffer
-//	out.WriteByte('(')
-//	out.WriteString(name)
-//	for _, e := range exprs {
-//		out.WriteByte(' ')
-//		res := e.Accept(ap)
-//		rest, ok := res.(string)
-//		if !ok {
-//			log.Fatalf("error parsing result of accept. On lexeme %q, expected=string, got=%T", name, res)
-//		}
-//		out.WriteString(rest)
-//	}
-//
-//	out.WriteByte(')')
-//
-//	return out.String()
-//}
+import (
+	"bytes"
+	"strconv"
+	"strings"
+
+	"github.com/butlermatt/glox/lexer"
+)
+
+// AstPrinter renders the syntax tree as S-expressions, for debugging the parser. Each statement
+// begins on its own line, with nested statements indented beneath their parent.
+type AstPrinter struct {
+	out    bytes.Buffer
+	indent int
+}
+
+// Print returns the S-expression for a single expression.
+func (ap *AstPrinter) Print(expr Expr) string {
+	return ap.expr(expr)
+}
+
+// PrintStmts returns the S-expressions for a list of statements, one top level statement per line.
+func (ap *AstPrinter) PrintStmts(stmts []Stmt) string {
+	ap.out.Reset()
+	ap.indent = 0
+	for _, s := range stmts {
+		ap.stmt(s)
+		ap.out.WriteByte('\n')
+	}
+	return ap.out.String()
+}
+
+func (ap *AstPrinter) VisitArrayExpr(expr *ArrayExpr) (interface{}, error) {
+	return ap.parenthesize("array", expr.Values...), nil
+}
+
+func (ap *AstPrinter) VisitAssignExpr(expr *AssignExpr) (interface{}, error) {
+	return "(= " + expr.Name.Lexeme + " " + ap.expr(expr.Value) + ")", nil
+}
+
+func (ap *AstPrinter) VisitBinaryExpr(expr *BinaryExpr) (interface{}, error) {
+	return ap.parenthesize(expr.Operator.Lexeme, expr.Left, expr.Right), nil
+}
+
+func (ap *AstPrinter) VisitCallExpr(expr *CallExpr) (interface{}, error) {
+	parts := []string{"call", ap.expr(expr.Callee)}
+	for _, a := range expr.Args {
+		parts = append(parts, ap.expr(a))
+	}
+	for i, name := range expr.Names {
+		parts = append(parts, "(: "+name.Lexeme+" "+ap.expr(expr.Named[i])+")")
+	}
+	return "(" + strings.Join(parts, " ") + ")", nil
+}
+
+func (ap *AstPrinter) VisitGetExpr(expr *GetExpr) (interface{}, error) {
+	return "(. " + ap.expr(expr.Object) + " " + expr.Name.Lexeme + ")", nil
+}
+
+func (ap *AstPrinter) VisitGroupingExpr(expr *GroupingExpr) (interface{}, error) {
+	return ap.parenthesize("group", expr.Expression), nil
+}
+
+func (ap *AstPrinter) VisitIndexExpr(expr *IndexExpr) (interface{}, error) {
+	return ap.parenthesize("[]", expr.Left, expr.Right), nil
+}
+
+func (ap *AstPrinter) VisitLiteralExpr(expr *LiteralExpr) (interface{}, error) {
+	switch v := expr.Value.(type) {
+	case nil:
+		return "null", nil
+	case string:
+		return strconv.Quote(v), nil
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	}
+	return "?", nil
+}
+
+func (ap *AstPrinter) VisitLogicalExpr(expr *LogicalExpr) (interface{}, error) {
+	return ap.parenthesize(expr.Operator.Lexeme, expr.Left, expr.Right), nil
+}
+
+func (ap *AstPrinter) VisitSetExpr(expr *SetExpr) (interface{}, error) {
+	if expr.Name == nil {
+		return ap.parenthesize("[]=", expr.Object, expr.Value), nil
+	}
+	return "(.= " + ap.expr(expr.Object) + " " + expr.Name.Lexeme + " " + ap.expr(expr.Value) + ")", nil
+}
+
+func (ap *AstPrinter) VisitSpawnExpr(expr *SpawnExpr) (interface{}, error) {
+	return ap.parenthesize("spawn", expr.Call), nil
+}
+
+func (ap *AstPrinter) VisitSpreadExpr(expr *SpreadExpr) (interface{}, error) {
+	return ap.parenthesize("...", expr.Expression), nil
+}
+
+func (ap *AstPrinter) VisitSuperExpr(expr *SuperExpr) (interface{}, error) {
+	return "(super " + expr.Method.Lexeme + ")", nil
+}
+
+func (ap *AstPrinter) VisitThisExpr(expr *ThisExpr) (interface{}, error) {
+	return "this", nil
+}
+
+func (ap *AstPrinter) VisitUnaryExpr(expr *UnaryExpr) (interface{}, error) {
+	return ap.parenthesize(expr.Operator.Lexeme, expr.Right), nil
+}
+
+func (ap *AstPrinter) VisitVariableExpr(expr *VariableExpr) (interface{}, error) {
+	return expr.Name.Lexeme, nil
+}
+
+func (ap *AstPrinter) VisitYieldExpr(expr *YieldExpr) (interface{}, error) {
+	return ap.optional("yield", expr.Value), nil
+}
+
+func (ap *AstPrinter) VisitBlockStmt(stmt *BlockStmt) error {
+	ap.open("block")
+	ap.body(stmt.Statements)
+	return ap.close()
+}
+
+func (ap *AstPrinter) VisitClassStmt(stmt *ClassStmt) error {
+	ap.open("class " + stmt.Name.Lexeme)
+	if stmt.Superclass != nil {
+		ap.out.WriteString(" (< " + stmt.Superclass.Name.Lexeme + ")")
+	}
+	for _, m := range stmt.Methods {
+		ap.child(m)
+	}
+	return ap.close()
+}
+
+func (ap *AstPrinter) VisitExpressionStmt(stmt *ExpressionStmt) error {
+	ap.out.WriteString(ap.parenthesize("expr", stmt.Expression))
+	return nil
+}
+
+func (ap *AstPrinter) VisitFunctionStmt(stmt *FunctionStmt) error {
+	keyword := "fun"
+	if stmt.Generator {
+		keyword = "fun*"
+	}
+
+	var params []string
+	for i, p := range stmt.Parameters {
+		if i < len(stmt.Defaults) && stmt.Defaults[i] != nil {
+			params = append(params, "(= "+p.Lexeme+" "+ap.expr(stmt.Defaults[i])+")")
+		} else {
+			params = append(params, p.Lexeme)
+		}
+	}
+	if stmt.Rest != nil {
+		params = append(params, "(... "+stmt.Rest.Lexeme+")")
+	}
+
+	ap.open(keyword + " " + stmt.Name.Lexeme + " (" + strings.Join(params, " ") + ")")
+	ap.body(stmt.Body)
+	return ap.close()
+}
+
+func (ap *AstPrinter) VisitIfStmt(stmt *IfStmt) error {
+	ap.open("if " + ap.expr(stmt.Condition))
+	ap.child(stmt.Then)
+	if stmt.Else != nil {
+		ap.child(stmt.Else)
+	}
+	return ap.close()
+}
+
+func (ap *AstPrinter) VisitPrintStmt(stmt *PrintStmt) error {
+	ap.out.WriteString(ap.parenthesize("print", stmt.Expression))
+	return nil
+}
+
+func (ap *AstPrinter) VisitReturnStmt(stmt *ReturnStmt) error {
+	ap.out.WriteString(ap.optional("return", stmt.Value))
+	return nil
+}
+
+func (ap *AstPrinter) VisitVarStmt(stmt *VarStmt) error {
+	keyword := "var"
+	if stmt.Constant {
+		keyword = "const"
+	}
+	ap.out.WriteString(ap.optional(keyword+" "+stmt.Name.Lexeme, stmt.Initializer))
+	return nil
+}
+
+func (ap *AstPrinter) VisitForStmt(stmt *ForStmt) error {
+	ap.open("for" + ap.label(stmt.Label))
+	if stmt.Initializer != nil {
+		ap.child(stmt.Initializer)
+	} else {
+		ap.line("()")
+	}
+	ap.line(ap.orEmpty(stmt.Condition))
+	ap.line(ap.orEmpty(stmt.Increment))
+	ap.child(stmt.Body)
+	return ap.close()
+}
+
+func (ap *AstPrinter) VisitForInStmt(stmt *ForInStmt) error {
+	ap.open("for-in" + ap.label(stmt.Label) + " " + stmt.Name.Lexeme + " " + ap.expr(stmt.Iterable))
+	ap.child(stmt.Body)
+	return ap.close()
+}
+
+func (ap *AstPrinter) VisitBreakStmt(stmt *BreakStmt) error {
+	ap.out.WriteString("(break" + ap.label(stmt.Label) + ")")
+	return nil
+}
+
+func (ap *AstPrinter) VisitContinueStmt(stmt *ContinueStmt) error {
+	ap.out.WriteString("(continue" + ap.label(stmt.Label) + ")")
+	return nil
+}
+
+func (ap *AstPrinter) VisitMatchStmt(stmt *MatchStmt) error {
+	ap.open("match " + ap.expr(stmt.Subject))
+	for _, c := range stmt.Cases {
+		ap.child(c)
+	}
+	return ap.close()
+}
+
+func (ap *AstPrinter) VisitCaseStmt(stmt *CaseStmt) error {
+	if len(stmt.Patterns) == 0 {
+		ap.open("default")
+	} else {
+		var patterns []string
+		for _, p := range stmt.Patterns {
+			patterns = append(patterns, ap.expr(p))
+		}
+		ap.open("case (" + strings.Join(patterns, " ") + ")")
+	}
+	ap.body(stmt.Body)
+	return ap.close()
+}
+
+func (ap *AstPrinter) expr(expr Expr) string {
+	res, _ := expr.Accept(ap)
+	return res.(string)
+}
+
+func (ap *AstPrinter) stmt(stmt Stmt) {
+	stmt.Accept(ap)
+}
+
+func (ap *AstPrinter) parenthesize(name string, exprs ...Expr) string {
+	var out bytes.Buffer
+	out.WriteByte('(')
+	out.WriteString(name)
+	for _, e := range exprs {
+		out.WriteByte(' ')
+		out.WriteString(ap.expr(e))
+	}
+	out.WriteByte(')')
+
+	return out.String()
+}
+
+// optional parenthesizes name with expr, or name alone if expr is nil.
+func (ap *AstPrinter) optional(name string, expr Expr) string {
+	if expr == nil {
+		return "(" + name + ")"
+	}
+	return ap.parenthesize(name, expr)
+}
+
+// orEmpty prints expr, or an empty list in place of a missing expression.
+func (ap *AstPrinter) orEmpty(expr Expr) string {
+	if expr == nil {
+		return "()"
+	}
+	return ap.expr(expr)
+}
+
+func (ap *AstPrinter) label(label *lexer.Token) string {
+	if label == nil {
+		return ""
+	}
+	return " :" + label.Lexeme
+}
+
+// open starts a statement whose children follow on their own lines; close ends it.
+func (ap *AstPrinter) open(head string) {
+	ap.out.WriteString("(" + head)
+	ap.indent++
+}
+
+func (ap *AstPrinter) close() error {
+	ap.indent--
+	ap.out.WriteByte(')')
+	return nil
+}
+
+func (ap *AstPrinter) newline() {
+	ap.out.WriteByte('\n')
+	ap.out.WriteString(strings.Repeat("  ", ap.indent))
+}
+
+func (ap *AstPrinter) line(s string) {
+	ap.newline()
+	ap.out.WriteString(s)
+}
+
+func (ap *AstPrinter) child(stmt Stmt) {
+	ap.newline()
+	ap.stmt(stmt)
+}
+
+func (ap *AstPrinter) body(stmts []Stmt) {
+	for _, s := range stmts {
+		ap.child(s)
+	}
+}
@@ -0,0 +1,53 @@
+package parser
+
+import (
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/butlermatt/glox/lexer"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+func TestAstPrinter_Golden(t *testing.T) {
+	files, err := filepath.Glob("testdata/*.lox")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, file := range files {
+		input, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		p := New(lexer.New(string(input)))
+		stmts := p.Parse()
+		if errs := p.Errors(); len(errs) > 0 {
+			t.Errorf("%s: unexpected parse errors: %+v", file, errs)
+			continue
+		}
+
+		ap := &AstPrinter{}
+		got := ap.PrintStmts(stmts)
+
+		golden := strings.TrimSuffix(file, ".lox") + ".ast"
+		if *update {
+			if err := ioutil.WriteFile(golden, []byte(got), 0644); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+
+		expected, err := ioutil.ReadFile(golden)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != string(expected) {
+			t.Errorf("%s: output does not match %s.\nexpected:\n%s\ngot:\n%s", file, golden, expected, got)
+		}
+	}
+}
@@ -1,6 +1,8 @@
 package parser
 
 import (
+	"strings"
+
 	"github.com/butlermatt/glox/lexer"
 )
 
@@ -15,11 +17,19 @@ type Parser struct {
 	curTok  *lexer.Token
 	prevTok *lexer.Token
 	errors  []ParseError
+	spans   map[interface{}]Span
+	doc     []*lexer.Token // The doc comments immediately before curTok
+}
+
+// Span is the range of tokens from which a node was parsed, inclusive of both ends.
+type Span struct {
+	First *lexer.Token
+	Last  *lexer.Token
 }
 
 func New(lexer *lexer.Lexer) *Parser {
 	lexer.ScanTokens()
-	p := &Parser{l: lexer, errors: []ParseError{}}
+	p := &Parser{l: lexer, errors: []ParseError{}, spans: make(map[interface{}]Span)}
 	p.nextToken()
 
 	return p
@@ -38,6 +48,22 @@ func (p *Parser) Parse() []Stmt {
 	return stmts
 }
 
+// Span returns the tokens from which node was parsed. Statements, primary expressions and
+// parenthesized or indexed expressions always have a span; other expressions may not, but are
+// made up only of their tokens and child nodes.
+func (p *Parser) Span(node interface{}) (Span, bool) {
+	s, ok := p.spans[node]
+	return s, ok
+}
+
+// mark records the tokens from first to the last one consumed as the span of node, unless it
+// already has one from a nested rule.
+func (p *Parser) mark(first *lexer.Token, node interface{}) {
+	if _, ok := p.spans[node]; !ok && first != nil && p.prevTok != nil {
+		p.spans[node] = Span{First: first, Last: p.prevTok}
+	}
+}
+
 func (p *Parser) addError(token *lexer.Token, message string) {
 	if token.Type == lexer.EOF {
 		p.errors = append(p.errors, ParseError{Line: token.Line, Where: "at end", Msg: message})
@@ -47,12 +73,36 @@ func (p *Parser) addError(token *lexer.Token, message string) {
 }
 
 func (p *Parser) nextToken() {
-	if p.curTok == nil || p.curTok.Type != lexer.EOF {
-		p.prevTok = p.curTok
-		p.curTok = p.l.NextToken()
+	if p.curTok != nil && p.curTok.Type == lexer.EOF {
+		return
+	}
+
+	p.prevTok = p.curTok
+	p.doc = nil
+	for {
+		tok := p.l.NextToken()
+		switch tok.Type {
+		case lexer.DocComment:
+			p.doc = append(p.doc, tok)
+			continue
+		case lexer.UTComment:
+			p.errors = append(p.errors, ParseError{Line: tok.Line, Where: "/*", Msg: "Unterminated block comment."})
+			continue
+		}
+		p.curTok = tok
+		return
 	}
 }
 
+// docText returns the text of the doc comments before the current token, one line per comment.
+func (p *Parser) docText() string {
+	var lines []string
+	for _, tok := range p.doc {
+		lines = append(lines, tok.Literal.(string))
+	}
+	return strings.Join(lines, "\n")
+}
+
 func (p *Parser) check(t lexer.TokenType) bool {
 	if p.curTok.Type == lexer.EOF {
 		return false
@@ -83,10 +133,18 @@ func (p *Parser) consume(tt lexer.TokenType, message string) bool {
 }
 
 func (p *Parser) expression() Expr {
-	return p.assignment()
+	first := p.curTok
+	expr := p.assignment()
+	if expr != nil {
+		p.mark(first, expr)
+	}
+	return expr
 }
 
 func (p *Parser) assignment() Expr {
+	if p.match(lexer.Yield) {
+		return p.yield()
+	}
 	expr := p.or()
 
 	if p.match(lexer.Equal) {
@@ -215,6 +273,16 @@ func (p *Parser) unary() Expr {
 		return &UnaryExpr{Operator: oper, Right: right}
 	}
 
+	if p.match(lexer.Spawn) {
+		keyword := p.prevTok
+		call, ok := p.call().(*CallExpr)
+		if !ok {
+			p.addError(keyword, "Expect function call after 'spawn'.")
+			return nil
+		}
+		return &SpawnExpr{Keyword: keyword, Call: call}
+	}
+
 	return p.call()
 }
 
@@ -239,6 +307,7 @@ func (p *Parser) call() Expr {
 }
 
 func (p *Parser) index() Expr {
+	first := p.curTok
 	expr := p.primary()
 
 	for p.match(lexer.LBracket) {
@@ -253,11 +322,19 @@ func (p *Parser) index() Expr {
 		}
 
 		expr = &IndexExpr{Left: expr, Operator: oper, Right: right}
+		p.mark(first, expr)
 	}
 	return expr
 }
 
-func (p *Parser) primary() Expr {
+func (p *Parser) primary() (expr Expr) {
+	first := p.curTok
+	defer func() {
+		if expr != nil {
+			p.mark(first, expr)
+		}
+	}()
+
 	switch {
 	case p.match(lexer.False):
 		return &LiteralExpr{Value: false}
@@ -285,9 +362,9 @@ func (p *Parser) primary() Expr {
 		vals := []Expr{}
 
 		if !p.check(lexer.RBracket) {
-			vals = append(vals, p.expression())
+			vals = append(vals, p.argument())
 			for p.match(lexer.Comma) {
-				vals = append(vals, p.expression())
+				vals = append(vals, p.argument())
 			}
 		}
 
@@ -311,21 +388,54 @@ func (p *Parser) primary() Expr {
 
 func (p *Parser) finishCall(callee Expr) Expr {
 	var args []Expr
+	var names []*lexer.Token
+	var named []Expr
 
 	if !p.check(lexer.RParen) {
-		args = append(args, p.expression())
-		for p.match(lexer.Comma) {
-			if len(args) >= 32 {
+		for {
+			if len(args)+len(named) >= 32 {
 				p.addError(p.curTok, "Cannot have more than 32 arguments")
 			}
-			args = append(args, p.expression())
+
+			arg := p.argument()
+			if arg == nil {
+				return nil
+			}
+
+			if v, ok := arg.(*VariableExpr); ok && p.match(lexer.Colon) {
+				names = append(names, v.Name)
+				named = append(named, p.expression())
+			} else if len(named) > 0 {
+				p.addError(p.prevTok, "Positional argument cannot follow named arguments.")
+				return nil
+			} else {
+				args = append(args, arg)
+			}
+
+			if !p.match(lexer.Comma) {
+				break
+			}
 		}
 	}
 
 	if !p.consume(lexer.RParen, "Expect ')' after arguments.") {
 		return nil
 	}
-	return &CallExpr{Callee: callee, Paren: p.prevTok, Args: args}
+	return &CallExpr{Callee: callee, Paren: p.prevTok, Args: args, Names: names, Named: named}
+}
+
+// argument parses a single argument or array value, which may be spread with '...'.
+func (p *Parser) argument() Expr {
+	if p.match(lexer.Ellipsis) {
+		ellipsis := p.prevTok
+		expr := p.expression()
+		if expr == nil {
+			return nil
+		}
+		return &SpreadExpr{Ellipsis: ellipsis, Expression: expr}
+	}
+
+	return p.expression()
 }
 
 func (p *Parser) synchronize() {
@@ -340,8 +450,10 @@ func (p *Parser) synchronize() {
 		case lexer.Class:
 		case lexer.Fun:
 		case lexer.Var:
+		case lexer.Const:
 		case lexer.For:
 		case lexer.If:
+		case lexer.Match:
 		case lexer.While:
 		case lexer.Print:
 		case lexer.Return:
@@ -352,7 +464,20 @@ func (p *Parser) synchronize() {
 	}
 }
 
-func (p *Parser) statement() Stmt {
+func (p *Parser) statement() (stmt Stmt) {
+	first := p.curTok
+	defer func() {
+		if stmt != nil {
+			p.mark(first, stmt)
+		}
+	}()
+
+	if p.check(lexer.Ident) {
+		if next := p.l.PeekToken(); next != nil && next.Type == lexer.Colon {
+			return p.labeledStatement()
+		}
+	}
+
 	switch {
 	case p.match(lexer.Break):
 		return p.breakStatement()
@@ -368,6 +493,8 @@ func (p *Parser) statement() Stmt {
 		return p.whileStatement()
 	case p.match(lexer.For):
 		return p.forStatement()
+	case p.match(lexer.Match):
+		return p.matchStatement()
 	case p.match(lexer.LBrace):
 		return &BlockStmt{Statements: p.block()}
 	}
@@ -375,22 +502,57 @@ func (p *Parser) statement() Stmt {
 	return p.expressionStatement()
 }
 
+func (p *Parser) labeledStatement() Stmt {
+	p.nextToken()
+	label := p.prevTok
+	p.nextToken() // The ':'
+
+	switch {
+	case p.match(lexer.For):
+		stmt := p.forStatement()
+		switch s := stmt.(type) {
+		case *ForStmt:
+			s.Label = label
+		case *ForInStmt:
+			s.Label = label
+		}
+		return stmt
+	case p.match(lexer.While):
+		stmt := p.whileStatement()
+		if s, ok := stmt.(*ForStmt); ok {
+			s.Label = label
+		}
+		return stmt
+	}
+
+	p.addError(p.curTok, "Expect loop after label.")
+	return nil
+}
+
 func (p *Parser) breakStatement() Stmt {
 	keyword := p.prevTok
+	var label *lexer.Token
+	if p.match(lexer.Ident) {
+		label = p.prevTok
+	}
 	if !p.consume(lexer.Semicolon, "Expect ';' after 'break'.") {
 		return nil
 	}
 
-	return &BreakStmt{Keyword: keyword}
+	return &BreakStmt{Keyword: keyword, Label: label}
 }
 
 func (p *Parser) continueStatement() Stmt {
 	keyword := p.prevTok
+	var label *lexer.Token
+	if p.match(lexer.Ident) {
+		label = p.prevTok
+	}
 	if !p.consume(lexer.Semicolon, "Expect ';' after 'continue'.") {
 		return nil
 	}
 
-	return &ContinueStmt{Keyword: keyword}
+	return &ContinueStmt{Keyword: keyword, Label: label}
 }
 
 func (p *Parser) ifStatement() Stmt {
@@ -433,6 +595,19 @@ func (p *Parser) returnStatement() Stmt {
 	return &ReturnStmt{Keyword: keyword, Value: value}
 }
 
+// yield parses the rest of a yield expression, whose value is optional.
+func (p *Parser) yield() Expr {
+	keyword := p.prevTok
+	var value Expr
+
+	switch p.curTok.Type {
+	case lexer.Semicolon, lexer.RParen, lexer.RBracket, lexer.RBrace, lexer.Comma, lexer.Colon, lexer.EOF:
+	default:
+		value = p.assignment()
+	}
+	return &YieldExpr{Keyword: keyword, Value: value}
+}
+
 func (p *Parser) whileStatement() Stmt {
 	if !p.consume(lexer.LParen, "Expect '(' after 'while'") {
 		return nil
@@ -448,6 +623,7 @@ func (p *Parser) whileStatement() Stmt {
 }
 
 func (p *Parser) forStatement() Stmt {
+	keyword := p.prevTok
 	if !p.consume(lexer.LParen, "Expect '(' after 'for'.") {
 		return nil
 	}
@@ -456,7 +632,16 @@ func (p *Parser) forStatement() Stmt {
 	if p.match(lexer.Semicolon) {
 		initializer = nil // Redundant but easy to read
 	} else if p.match(lexer.Var) {
-		initializer = p.varDeclaration()
+		first := p.prevTok
+		if !p.consume(lexer.Ident, "Expect variable name.") {
+			return nil
+		}
+		name := p.prevTok
+		if p.match(lexer.In) {
+			return p.forInStatement(keyword, name)
+		}
+		initializer = p.finishVarDeclaration(name)
+		p.mark(first, initializer)
 	} else {
 		initializer = p.expressionStatement()
 	}
@@ -482,6 +667,166 @@ func (p *Parser) forStatement() Stmt {
 	return &ForStmt{Initializer: initializer, Condition: cond, Body: body, Increment: increment}
 }
 
+func (p *Parser) matchStatement() Stmt {
+	keyword := p.prevTok
+	if !p.consume(lexer.LParen, "Expect '(' after 'match'.") {
+		return nil
+	}
+	subject := p.expression()
+	if !p.consume(lexer.RParen, "Expect ')' after match value.") {
+		return nil
+	}
+	if !p.consume(lexer.LBrace, "Expect '{' before match cases.") {
+		return nil
+	}
+
+	var cases []*CaseStmt
+	hasDefault := false
+	for !p.check(lexer.RBrace) && p.curTok.Type != lexer.EOF {
+		first := p.curTok
+		var patterns []Expr
+		if p.match(lexer.Default) {
+			if hasDefault {
+				p.addError(p.prevTok, "Match can only have one default case.")
+				return nil
+			}
+			hasDefault = true
+		} else if p.match(lexer.Case) {
+			for {
+				pat := p.pattern()
+				if pat == nil {
+					return nil
+				}
+				patterns = append(patterns, pat)
+				if !p.match(lexer.Comma) {
+					break
+				}
+			}
+		} else {
+			p.addError(p.curTok, "Expect 'case' or 'default' in match body.")
+			return nil
+		}
+		keyword := p.prevTok
+
+		if !p.consume(lexer.Colon, "Expect ':' after case.") {
+			return nil
+		}
+
+		var body []Stmt
+		for !p.check(lexer.Case) && !p.check(lexer.Default) && !p.check(lexer.RBrace) && p.curTok.Type != lexer.EOF {
+			body = append(body, p.declaration())
+		}
+		c := &CaseStmt{Keyword: keyword, Patterns: patterns, Body: body}
+		p.mark(first, c)
+		cases = append(cases, c)
+	}
+
+	if !p.consume(lexer.RBrace, "Expect '}' after match cases.") {
+		return nil
+	}
+	return &MatchStmt{Keyword: keyword, Subject: subject, Cases: cases}
+}
+
+// pattern parses a match pattern. Patterns reuse expression nodes: literals match by equality,
+// identifiers bind the value (except '_'), arrays match element-wise with an optional trailing
+// '...rest', and Class(field, other: pattern) matches instances and destructures their fields.
+func (p *Parser) pattern() (expr Expr) {
+	first := p.curTok
+	defer func() {
+		if expr != nil {
+			p.mark(first, expr)
+		}
+	}()
+
+	switch {
+	case p.match(lexer.False):
+		return &LiteralExpr{Value: false}
+	case p.match(lexer.True):
+		return &LiteralExpr{Value: true}
+	case p.match(lexer.Null):
+		return &LiteralExpr{Value: nil}
+	case p.match(lexer.Number, lexer.String):
+		return &LiteralExpr{Value: p.prevTok.Literal}
+	case p.match(lexer.Minus):
+		oper := p.prevTok
+		if !p.consume(lexer.Number, "Expect number after '-' in pattern.") {
+			return nil
+		}
+		return &UnaryExpr{Operator: oper, Right: &LiteralExpr{Value: p.prevTok.Literal}}
+	case p.match(lexer.LBracket):
+		var elems []Expr
+		for !p.check(lexer.RBracket) {
+			if p.match(lexer.Ellipsis) {
+				ellipsis := p.prevTok
+				if !p.consume(lexer.Ident, "Expect name after '...' in pattern.") {
+					return nil
+				}
+				elems = append(elems, &SpreadExpr{Ellipsis: ellipsis, Expression: &VariableExpr{Name: p.prevTok}})
+				break
+			}
+
+			elem := p.pattern()
+			if elem == nil {
+				return nil
+			}
+			elems = append(elems, elem)
+			if !p.match(lexer.Comma) {
+				break
+			}
+		}
+		if !p.consume(lexer.RBracket, "Expect ']' after array pattern.") {
+			return nil
+		}
+		return &ArrayExpr{Values: elems}
+	case p.match(lexer.Ident):
+		name := p.prevTok
+		if !p.match(lexer.LParen) {
+			return &VariableExpr{Name: name}
+		}
+
+		var names []*lexer.Token
+		var fields []Expr
+		for !p.check(lexer.RParen) {
+			if !p.consume(lexer.Ident, "Expect field name in class pattern.") {
+				return nil
+			}
+			field := p.prevTok
+			var sub Expr = &VariableExpr{Name: field}
+			if p.match(lexer.Colon) {
+				sub = p.pattern()
+				if sub == nil {
+					return nil
+				}
+			}
+			names = append(names, field)
+			fields = append(fields, sub)
+			if !p.match(lexer.Comma) {
+				break
+			}
+		}
+		if !p.consume(lexer.RParen, "Expect ')' after class pattern.") {
+			return nil
+		}
+		return &CallExpr{Callee: &VariableExpr{Name: name}, Paren: p.prevTok, Names: names, Named: fields}
+	}
+
+	p.addError(p.curTok, "Expect pattern.")
+	return nil
+}
+
+func (p *Parser) forInStatement(keyword, name *lexer.Token) Stmt {
+	iterable := p.expression()
+	if iterable == nil {
+		return nil
+	}
+	if !p.consume(lexer.RParen, "Expect ')' after for-in clause.") {
+		return nil
+	}
+
+	body := p.statement()
+	return &ForInStmt{Keyword: keyword, Name: name, Iterable: iterable, Body: body}
+}
+
 func (p *Parser) expressionStatement() Stmt {
 	expr := p.expression()
 	p.consume(lexer.Semicolon, "Expect ';' after value.")
@@ -500,6 +845,8 @@ func (p *Parser) block() []Stmt {
 }
 
 func (p *Parser) declaration() Stmt {
+	first := p.curTok
+	doc := p.docText()
 	var stmt Stmt
 
 	switch {
@@ -509,6 +856,8 @@ func (p *Parser) declaration() Stmt {
 		stmt = p.function("function")
 	case p.match(lexer.Var):
 		stmt = p.varDeclaration()
+	case p.match(lexer.Const):
+		stmt = p.constDeclaration()
 	default:
 		stmt = p.statement()
 	}
@@ -517,6 +866,18 @@ func (p *Parser) declaration() Stmt {
 		p.synchronize()
 		return nil
 	}
+	switch s := stmt.(type) {
+	case *FunctionStmt:
+		s.Doc = doc
+	case *ClassStmt:
+		s.Doc = doc
+	case *VarStmt:
+		s.Doc = doc
+	}
+	if stmt != nil {
+		// Set rather than mark, as a function's own span does not include the 'fun' keyword.
+		p.spans[stmt] = Span{First: first, Last: p.prevTok}
+	}
 	return stmt
 }
 
@@ -541,12 +902,15 @@ func (p *Parser) classDeclaration() Stmt {
 
 	var methods []*FunctionStmt
 	for !p.check(lexer.RBrace) && p.curTok.Type != lexer.EOF {
+		doc := p.docText()
 		f := p.function("method")
 		if f == nil {
 			return nil
 		}
 
-		methods = append(methods, f.(*FunctionStmt))
+		method := f.(*FunctionStmt)
+		method.Doc = doc
+		methods = append(methods, method)
 	}
 
 	if !p.consume(lexer.RBrace, "Expect '}' after class body.") {
@@ -555,7 +919,16 @@ func (p *Parser) classDeclaration() Stmt {
 	return &ClassStmt{Name: name, Superclass: superclass, Methods: methods}
 }
 
-func (p *Parser) function(kind string) Stmt {
+func (p *Parser) function(kind string) (stmt Stmt) {
+	first := p.curTok
+	defer func() {
+		if stmt != nil {
+			p.mark(first, stmt)
+		}
+	}()
+
+	// Generators are declared with a '*' before the name: fun* gen() or *method().
+	generator := p.match(lexer.Star)
 	if !p.consume(lexer.Ident, "Expect "+kind+" name.") {
 		return nil
 	}
@@ -566,19 +939,48 @@ func (p *Parser) function(kind string) Stmt {
 	}
 
 	var params []*lexer.Token
+	var defaults []Expr
+	var rest *lexer.Token
 	if !p.check(lexer.RParen) {
-		if !p.consume(lexer.Ident, "Expect parameter name.") {
-			return nil
-		}
-		params = append(params, p.prevTok)
-		for p.match(lexer.Comma) {
-			if len(params) > 32 {
+		for {
+			if len(params) >= 32 {
 				p.addError(p.curTok, "Cannot have more than 32 parameters.")
 			}
+
+			if p.match(lexer.Ellipsis) {
+				if !p.consume(lexer.Ident, "Expect rest parameter name.") {
+					return nil
+				}
+				rest = p.prevTok
+				if p.check(lexer.Comma) {
+					p.addError(p.curTok, "Rest parameter must be the last parameter.")
+					return nil
+				}
+				break
+			}
+
 			if !p.consume(lexer.Ident, "Expect parameter name.") {
 				return nil
 			}
-			params = append(params, p.prevTok)
+			param := p.prevTok
+
+			var def Expr
+			if p.match(lexer.Equal) {
+				def = p.expression()
+				if def == nil {
+					return nil
+				}
+			} else if len(defaults) > 0 && defaults[len(defaults)-1] != nil {
+				p.addError(param, "Parameter without a default cannot follow parameters with defaults.")
+				return nil
+			}
+
+			params = append(params, param)
+			defaults = append(defaults, def)
+
+			if !p.match(lexer.Comma) {
+				break
+			}
 		}
 	}
 
@@ -589,15 +991,17 @@ func (p *Parser) function(kind string) Stmt {
 		return nil
 	}
 	body := p.block()
-	return &FunctionStmt{Name: name, Parameters: params, Body: body}
+	return &FunctionStmt{Name: name, Parameters: params, Defaults: defaults, Rest: rest, Body: body, Generator: generator}
 }
 
 func (p *Parser) varDeclaration() Stmt {
 	if !p.consume(lexer.Ident, "Expect variable name.") {
 		return nil
 	}
-	name := p.prevTok
+	return p.finishVarDeclaration(p.prevTok)
+}
 
+func (p *Parser) finishVarDeclaration(name *lexer.Token) Stmt {
 	var initializer Expr
 	if p.match(lexer.Equal) {
 		initializer = p.expression()
@@ -606,3 +1010,18 @@ func (p *Parser) varDeclaration() Stmt {
 	p.consume(lexer.Semicolon, "Expect ';' after variable declaration.")
 	return &VarStmt{Name: name, Initializer: initializer}
 }
+
+func (p *Parser) constDeclaration() Stmt {
+	if !p.consume(lexer.Ident, "Expect constant name.") {
+		return nil
+	}
+	name := p.prevTok
+
+	if !p.consume(lexer.Equal, "Expect '=' after constant name.") {
+		return nil
+	}
+	initializer := p.expression()
+
+	p.consume(lexer.Semicolon, "Expect ';' after constant declaration.")
+	return &VarStmt{Name: name, Initializer: initializer, Constant: true}
+}
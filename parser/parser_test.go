@@ -0,0 +1,48 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/butlermatt/glox/lexer"
+)
+
+func TestParser_DocComments(t *testing.T) {
+	input := `
+/// Adds two numbers.
+/// Returns the sum.
+fun add(a, b) { return a + b; }
+
+/// A point.
+class Point {
+  /// Makes a point.
+  init() {}
+}
+
+// Not a doc comment.
+var plain = 1;
+/// A constant.
+const answer = 42;
+`
+	p := New(lexer.New(input))
+	stmts := p.Parse()
+	if errs := p.Errors(); len(errs) > 0 {
+		t.Fatalf("unexpected parse errors: %+v", errs)
+	}
+
+	class := stmts[1].(*ClassStmt)
+	tests := []struct {
+		got      string
+		expected string
+	}{
+		{stmts[0].(*FunctionStmt).Doc, "Adds two numbers.\nReturns the sum."},
+		{class.Doc, "A point."},
+		{class.Methods[0].Doc, "Makes a point."},
+		{stmts[2].(*VarStmt).Doc, ""},
+		{stmts[3].(*VarStmt).Doc, "A constant."},
+	}
+	for i, tt := range tests {
+		if tt.got != tt.expected {
+			t.Errorf("test %d: unexpected doc. expected=%q, got=%q", i+1, tt.expected, tt.got)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package parser
+
+import (
+	"reflect"
+
+	"github.com/butlermatt/glox/lexer"
+)
+
+// Children returns the nodes directly beneath node, an Expr or Stmt, in the order of its fields.
+func Children(node interface{}) []interface{} {
+	var children []interface{}
+	eachField(node, func(v reflect.Value) {
+		switch {
+		case v.Kind() == reflect.Slice && isNodeType(v.Type().Elem()):
+			for i := 0; i < v.Len(); i++ {
+				if !v.Index(i).IsNil() {
+					children = append(children, v.Index(i).Interface())
+				}
+			}
+		case isNodeType(v.Type()):
+			if !v.IsNil() {
+				children = append(children, v.Interface())
+			}
+		}
+	})
+	return children
+}
+
+// Tokens returns the tokens held directly by node, an Expr or Stmt, in the order of its fields.
+func Tokens(node interface{}) []*lexer.Token {
+	var toks []*lexer.Token
+	eachField(node, func(v reflect.Value) {
+		switch {
+		case v.Type() == tokenType:
+			if !v.IsNil() {
+				toks = append(toks, v.Interface().(*lexer.Token))
+			}
+		case v.Kind() == reflect.Slice && v.Type().Elem() == tokenType:
+			for i := 0; i < v.Len(); i++ {
+				toks = append(toks, v.Index(i).Interface().(*lexer.Token))
+			}
+		}
+	})
+	return toks
+}
+
+// Inspect calls fn for node and then, if fn returns true, for each of its children in turn.
+func Inspect(node interface{}, fn func(node interface{}) bool) {
+	if node == nil || !fn(node) {
+		return
+	}
+	for _, c := range Children(node) {
+		Inspect(c, fn)
+	}
+}
+
+func eachField(node interface{}, fn func(v reflect.Value)) {
+	v := reflect.ValueOf(node)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return
+	}
+	v = v.Elem()
+	for i := 0; i < v.NumField(); i++ {
+		fn(v.Field(i))
+	}
+}